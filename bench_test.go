@@ -0,0 +1,170 @@
+package mvt_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
+	"github.com/stretchr/testify/require"
+)
+
+// benchLayers returns layers with a mix of geometry types and tags, similar in size to a busy street tile.
+func benchLayers() mvt21.Layers {
+	const n = 1000
+
+	var roads, buildings []mvt21.Feature
+	for i := 0; i < n; i++ {
+		x, y := float64(i%40)*4, float64(i/40)*3
+
+		line := make([]geojson.Position, 8)
+		for j := range line {
+			line[j] = geojson.MakePosition(y+float64(j)/4, x+float64(j*j)/16)
+		}
+
+		ring := make([]geojson.Position, 17)
+		for j := range ring[:16] {
+			a := -2 * math.Pi * float64(j) / 16
+			ring[j] = geojson.MakePosition(y+1+math.Sin(a), x+1+math.Cos(a))
+		}
+		ring[16] = ring[0]
+
+		tags := geojson.PropertyList{
+			{Name: "name", Value: fmt.Sprintf("Street %d", i%50)},
+			{Name: "lanes", Value: int64(i % 4)},
+			{Name: "oneway", Value: i%2 == 0},
+			{Name: "width", Value: float64(i%10) / 2},
+		}
+
+		roads = append(roads, mvt21.Feature{
+			ID:       mvt21.NewOptionalUint64(uint64(i)),
+			Tags:     tags,
+			Geometry: geojson.NewLineString(line[0], line[1], line[2:]...).Geometry,
+		})
+		buildings = append(buildings, mvt21.Feature{
+			ID:       mvt21.NewOptionalUint64(uint64(i)),
+			Tags:     tags[:2],
+			Geometry: geojson.NewPolygon(ring).Geometry,
+		})
+	}
+
+	return mvt21.Layers{
+		"roads":     {Extent: 4096, Features: roads},
+		"buildings": {Extent: 4096, Features: buildings},
+	}
+}
+
+// benchProject scales degrees so that features span many tile units.
+func benchProject(ll s2.LatLng) r2.Point {
+	return r2.Point{
+		X: ll.Lng.Degrees() * 16,
+		Y: ll.Lat.Degrees() * 16,
+	}
+}
+
+func benchUnproject(p r2.Point) s2.LatLng {
+	return s2.LatLngFromDegrees(p.Y/16, p.X/16)
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	layers := benchLayers()
+	opts := mvt21.MarshalOptions{Project: benchProject}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := opts.Marshal(layers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalConcurrent(b *testing.B) {
+	layers := benchLayers()
+	opts := mvt21.MarshalOptions{
+		Project:     benchProject,
+		Concurrency: -1,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := opts.Marshal(layers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data, err := mvt21.Marshal(benchLayers(), benchProject)
+	require.NoError(b, err)
+	b.SetBytes(int64(len(data)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mvt21.Unmarshal(data, benchUnproject); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalConcurrent(b *testing.B) {
+	data, err := mvt21.Marshal(benchLayers(), benchProject)
+	require.NoError(b, err)
+	b.SetBytes(int64(len(data)))
+
+	opts := mvt21.UnmarshalOptions{
+		Unproject:   benchUnproject,
+		Concurrency: -1,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := opts.Unmarshal(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	layers := benchLayers()
+
+	var buf bytes.Buffer
+	enc := mvt21.NewEncoder(&buf)
+	enc.SetOptions(mvt21.MarshalOptions{Project: benchProject})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := enc.Encode(layers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	data, err := mvt21.Marshal(benchLayers(), benchProject)
+	require.NoError(b, err)
+	b.SetBytes(int64(len(data)))
+
+	r := bytes.NewReader(data)
+	dec := mvt21.NewDecoder(r)
+	dec.SetOptions(mvt21.UnmarshalOptions{Unproject: benchUnproject})
+
+	var layers mvt21.Layers
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		if err := dec.Decode(&layers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
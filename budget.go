@@ -0,0 +1,253 @@
+package mvt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Budget limits the size of an encoded tile.
+// Layers are first coarsened, by halving their extent down to MinExtent,
+// and then the features with the lowest priority are dropped, until the tile fits.
+type Budget struct {
+	// MaxSize is the maximum size of the encoded tile in bytes, after compression.
+	MaxSize int
+
+	// MinExtent is the smallest extent that layers are coarsened to. If zero, layers are not coarsened.
+	// Layers whose extent is already at or below MinExtent are not coarsened.
+	MinExtent uint32
+
+	// Priority returns the priority of a feature. If nil, PriorityTag is used.
+	Priority func(layer LayerName, f Feature) float64
+
+	// PriorityTag is the name of a numeric tag whose value is the priority of a feature.
+	// Features without the tag have the lowest priority. If empty, every feature has the same priority.
+	PriorityTag string
+}
+
+// BudgetReport describes what was done to fit a tile into a Budget.
+type BudgetReport struct {
+	// Size of the encoded tile.
+	Size int
+
+	// Extents holds the extent of each layer that was coarsened.
+	Extents map[LayerName]uint32
+
+	// Dropped lists the features that were dropped, from the lowest priority.
+	Dropped []DroppedFeature
+}
+
+// DroppedFeature identifies a feature that was dropped to fit a tile into a Budget.
+type DroppedFeature struct {
+	Layer LayerName
+	// Index of the feature in the layer.
+	Index int
+	ID    OptionalUint64
+}
+
+// ErrBudgetExceeded is returned when a tile does not fit into a Budget, even with every feature dropped.
+var ErrBudgetExceeded = errors.New("tile exceeds size budget")
+
+// MarshalBudget returns the mvt encoding of the supplied layers, reduced to fit the budget.
+func (o MarshalOptions) MarshalBudget(layers Layers, budget Budget) ([]byte, BudgetReport, error) {
+	return o.MarshalBudgetContext(context.Background(), layers, budget)
+}
+
+// MarshalBudgetContext returns the mvt encoding of the supplied layers, reduced to fit the budget.
+// Of features with equal priority, those in later layers by name, and later in their layer, are dropped first.
+// The tile is encoded several times, so the budget is best met with a fast compression level.
+// Encoding stops between features if ctx is done, and ctx.Err() is returned.
+func (o MarshalOptions) MarshalBudgetContext(ctx context.Context, layers Layers, budget Budget) ([]byte, BudgetReport, error) {
+	if budget.MaxSize <= 0 {
+		return nil, BudgetReport{}, fmt.Errorf("budget max size must be positive")
+	}
+
+	data, err := o.MarshalContext(ctx, layers)
+	if err != nil || len(data) <= budget.MaxSize {
+		return data, BudgetReport{Size: len(data)}, err
+	}
+
+	var report BudgetReport
+	if budget.MinExtent > 0 {
+		coarse := o
+		for shift := 1; ; shift++ {
+			opts, extents, coarser := o.coarsened(layers, budget.MinExtent, shift)
+			if !coarser {
+				break
+			}
+
+			coarse, report.Extents = opts, extents
+			if data, err = coarse.MarshalContext(ctx, layers); err != nil {
+				return nil, BudgetReport{}, err
+			} else if len(data) <= budget.MaxSize {
+				report.Size = len(data)
+				return data, report, nil
+			}
+		}
+		o = coarse
+	}
+
+	// Find the fewest features to drop, assuming that the size falls as features are dropped.
+	order := o.dropOrder(layers, budget)
+	fits := func(n int) ([]byte, bool, error) {
+		data, err := o.MarshalContext(ctx, withoutFeatures(layers, order[:n]))
+		return data, err == nil && len(data) <= budget.MaxSize, err
+	}
+
+	data, ok, err := fits(len(order))
+	if err != nil {
+		return nil, BudgetReport{}, err
+	} else if !ok {
+		return nil, BudgetReport{}, ErrBudgetExceeded
+	}
+
+	low, high := 0, len(order)
+	for high-low > 1 {
+		mid := low + (high-low)/2
+		fit, ok, err := fits(mid)
+		if err != nil {
+			return nil, BudgetReport{}, err
+		} else if ok {
+			data, high = fit, mid
+		} else {
+			low = mid
+		}
+	}
+
+	report.Size = len(data)
+	report.Dropped = order[:high]
+	return data, report, nil
+}
+
+// coarsened returns options that encode each layer with its extent divided by 2^shift, but not below the minimum,
+// and the extents of the coarsened layers. It returns false if no layer is coarser than with the previous shift.
+func (o MarshalOptions) coarsened(layers Layers, minExtent uint32, shift int) (MarshalOptions, map[LayerName]uint32, bool) {
+	configs := make(LayerConfigs, len(layers))
+	extents := make(map[LayerName]uint32)
+	coarser := false
+	for name, layer := range layers {
+		cfg := o.Layers[name]
+		if extent := cfg.Extent(o.Zoom, layer.Extent); extent > minExtent {
+			coarsened := max(extent>>shift, minExtent)
+			coarser = coarser || coarsened < max(extent>>(shift-1), minExtent)
+			cfg.Extents = []ZoomExtent{{Extent: coarsened}}
+			extents[name] = coarsened
+		}
+		configs[name] = cfg
+	}
+
+	o.Layers = configs
+	return o, extents, coarser
+}
+
+// dropOrder returns every feature in a layer visible at the zoom, in the order that they are dropped.
+func (o MarshalOptions) dropOrder(layers Layers, budget Budget) []DroppedFeature {
+	priority := budget.Priority
+	if priority == nil {
+		priority = func(_ LayerName, f Feature) float64 {
+			return tagPriority(f, budget.PriorityTag)
+		}
+	}
+
+	type ranked struct {
+		DroppedFeature
+		priority float64
+	}
+
+	var features []ranked
+	for _, name := range layers.names() {
+		if cfg, ok := o.Layers[name]; ok && !cfg.Visible(o.Zoom) {
+			continue
+		}
+
+		for i, f := range layers[name].Features {
+			features = append(features, ranked{
+				DroppedFeature: DroppedFeature{Layer: name, Index: i, ID: f.ID},
+				priority:       priority(name, f),
+			})
+		}
+	}
+
+	// Features are listed in reverse, so that the stable sort drops the last of equal priority first.
+	slices.Reverse(features)
+	slices.SortStableFunc(features, func(a, b ranked) int {
+		switch {
+		case a.priority < b.priority:
+			return -1
+		case a.priority > b.priority:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	order := make([]DroppedFeature, len(features))
+	for i, f := range features {
+		order[i] = f.DroppedFeature
+	}
+	return order
+}
+
+// tagPriority returns the numeric value of the tag, or -Inf if the feature does not have it.
+func tagPriority(f Feature, name string) float64 {
+	if name == "" {
+		return 0
+	}
+
+	for _, tag := range f.Tags {
+		if tag.Name != name {
+			continue
+		}
+
+		switch v := tag.Value.(type) {
+		case int64:
+			return float64(v)
+		case uint64:
+			return float64(v)
+		case float64:
+			return v
+		case float32:
+			return float64(v)
+		case int:
+			return float64(v)
+		}
+	}
+	return math.Inf(-1)
+}
+
+// withoutFeatures returns the layers without the dropped features. Layers left without features are removed.
+func withoutFeatures(layers Layers, dropped []DroppedFeature) Layers {
+	if len(dropped) == 0 {
+		return layers
+	}
+
+	drop := make(map[LayerName]map[int]struct{})
+	for _, d := range dropped {
+		if drop[d.Layer] == nil {
+			drop[d.Layer] = make(map[int]struct{})
+		}
+		drop[d.Layer][d.Index] = struct{}{}
+	}
+
+	out := make(Layers, len(layers))
+	for name, layer := range layers {
+		indices, ok := drop[name]
+		if !ok {
+			out[name] = layer
+			continue
+		}
+
+		features := make([]Feature, 0, len(layer.Features)-len(indices))
+		for i, f := range layer.Features {
+			if _, ok := indices[i]; !ok {
+				features = append(features, f)
+			}
+		}
+		if len(features) > 0 {
+			out[name] = MakeLayer(layer.Extent, features...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package mvt_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/stretchr/testify/require"
+)
+
+// rankedLayers returns a layer of points with a "rank" tag from 0, in a random order.
+func rankedLayers(n int) mvt21.Layers {
+	features := make([]mvt21.Feature, n)
+	for i := range features {
+		rank := (i * 7) % n
+		features[i] = mvt21.Feature{
+			ID: mvt21.NewOptionalUint64(uint64(rank)),
+			Tags: geojson.PropertyList{
+				{Name: "rank", Value: int64(rank)},
+				{Name: "name", Value: fmt.Sprintf("Place number %d", rank)},
+			},
+			Geometry: &mvt21.TileGeometry{
+				GeomType: mvt21.TilePointType,
+				Parts:    [][]mvt21.TilePoint{{{X: int32(rank * 40), Y: int32(rank * 30)}}},
+			},
+		}
+	}
+	return mvt21.Layers{"places": mvt21.MakeLayer(4096, features...)}
+}
+
+func TestMarshalBudget(t *testing.T) {
+	layers := rankedLayers(100)
+
+	t.Run("fits", func(t *testing.T) {
+		expected, err := mvt21.Marshal(layers, nil)
+		require.NoError(t, err)
+
+		data, report, err := mvt21.MarshalOptions{}.MarshalBudget(layers, mvt21.Budget{MaxSize: len(expected)})
+		require.NoError(t, err)
+		require.Equal(t, expected, data)
+		require.Equal(t, mvt21.BudgetReport{Size: len(data)}, report)
+	})
+
+	for _, compression := range []mvt21.Compression{mvt21.NoCompression, mvt21.Gzip} {
+		t.Run(fmt.Sprintf("drop/%v", compression), func(t *testing.T) {
+			opts := mvt21.MarshalOptions{Compression: compression}
+
+			// The budget is the size of the tile with the top half of the features.
+			var top []mvt21.Feature
+			for _, f := range layers["places"].Features {
+				if id, _ := f.ID.Get(); id >= 50 {
+					top = append(top, f)
+				}
+			}
+			expected, err := opts.Marshal(mvt21.Layers{"places": mvt21.MakeLayer(4096, top...)})
+			require.NoError(t, err)
+
+			data, report, err := opts.MarshalBudget(layers, mvt21.Budget{
+				MaxSize:     len(expected),
+				PriorityTag: "rank",
+			})
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(data), len(expected))
+			require.Equal(t, len(data), report.Size)
+			require.Nil(t, report.Extents)
+
+			// The lowest ranks are dropped first.
+			if compression == mvt21.NoCompression {
+				require.Len(t, report.Dropped, 50)
+			}
+			require.NotEmpty(t, report.Dropped)
+			for i, d := range report.Dropped {
+				require.Equal(t, mvt21.LayerName("places"), d.Layer)
+				require.Equal(t, mvt21.NewOptionalUint64(uint64(i)), d.ID)
+				require.Equal(t, d.ID, layers["places"].Features[d.Index].ID)
+			}
+
+			decoded, err := mvt21.Unmarshal(data, SimpleUnproject)
+			require.NoError(t, err)
+			require.Len(t, decoded["places"].Features, 100-len(report.Dropped))
+			for _, f := range decoded["places"].Features {
+				id, _ := f.ID.Get()
+				require.GreaterOrEqual(t, id, uint64(len(report.Dropped)))
+			}
+		})
+	}
+
+	t.Run("priority", func(t *testing.T) {
+		full, err := mvt21.Marshal(layers, nil)
+		require.NoError(t, err)
+
+		// Features with the highest ranks are dropped first, and the rest in reverse order.
+		_, report, err := mvt21.MarshalOptions{}.MarshalBudget(layers, mvt21.Budget{
+			MaxSize: len(full) / 2,
+			Priority: func(layer mvt21.LayerName, f mvt21.Feature) float64 {
+				if id, _ := f.ID.Get(); id >= 90 {
+					return -float64(id)
+				}
+				return 0
+			},
+		})
+		require.NoError(t, err)
+		require.Greater(t, len(report.Dropped), 10)
+		for i, d := range report.Dropped {
+			if i < 10 {
+				require.Equal(t, mvt21.NewOptionalUint64(uint64(99-i)), d.ID)
+			} else if i > 10 {
+				require.Greater(t, report.Dropped[i-1].Index, d.Index)
+			}
+		}
+	})
+
+	t.Run("coarsen", func(t *testing.T) {
+		// Each delta takes two bytes at an extent of 4096, and one at 2048.
+		var line []mvt21.TilePoint
+		for i := int32(0); i < 40; i++ {
+			line = append(line, mvt21.TilePoint{X: i * 100, Y: 2000 + (i%2)*100})
+		}
+		layers := mvt21.Layers{"lines": mvt21.MakeLayer(4096, mvt21.Feature{
+			Geometry: &mvt21.TileGeometry{
+				GeomType: mvt21.TileLineStringType,
+				Parts:    [][]mvt21.TilePoint{line},
+			},
+		})}
+
+		coarse, err := mvt21.MarshalOptions{
+			Layers: mvt21.LayerConfigs{"lines": {Extents: []mvt21.ZoomExtent{{Extent: 2048}}}},
+		}.Marshal(layers)
+		require.NoError(t, err)
+
+		data, report, err := mvt21.MarshalOptions{}.MarshalBudget(layers, mvt21.Budget{
+			MaxSize:   len(coarse),
+			MinExtent: 256,
+		})
+		require.NoError(t, err)
+		require.Equal(t, coarse, data)
+		require.Equal(t, mvt21.BudgetReport{
+			Size:    len(coarse),
+			Extents: map[mvt21.LayerName]uint32{"lines": 2048},
+		}, report)
+
+		// Features are dropped once the layer is at the minimum extent.
+		_, report, err = mvt21.MarshalOptions{}.MarshalBudget(layers, mvt21.Budget{
+			MaxSize:   10,
+			MinExtent: 256,
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[mvt21.LayerName]uint32{"lines": 256}, report.Extents)
+		require.Len(t, report.Dropped, 1)
+		require.Zero(t, report.Size)
+	})
+
+	t.Run("exceeded", func(t *testing.T) {
+		_, _, err := mvt21.MarshalOptions{Compression: mvt21.Gzip}.MarshalBudget(layers, mvt21.Budget{MaxSize: 5})
+		require.True(t, errors.Is(err, mvt21.ErrBudgetExceeded))
+
+		_, _, err = mvt21.MarshalOptions{}.MarshalBudget(layers, mvt21.Budget{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,440 @@
+// Package cluster groups dense points into clusters at each zoom, in the manner of supercluster.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt "github.com/everystreet/go-mvt"
+	"github.com/everystreet/go-mvt/tiler"
+	"github.com/golang/geo/r2"
+)
+
+// Tags added to clusters.
+const (
+	ClusterTag               = "cluster"
+	ClusterIDTag             = "cluster_id"
+	PointCountTag            = "point_count"
+	PointCountAbbreviatedTag = "point_count_abbreviated"
+)
+
+// maxClusterZoom is the highest supported MaxZoom, as the zoom of a cluster is encoded in its ID.
+const maxClusterZoom = tiler.MaxZoom - 1
+
+// unclustered is the zoom of a node that has not been clustered.
+const unclustered = math.MaxInt
+
+// The zoom of a cluster is stored in the low bits of its ID.
+const (
+	clusterZoomBits        = 5
+	clusterZoomMask uint64 = 1<<clusterZoomBits - 1
+)
+
+// Options configures how points are clustered.
+type Options struct {
+	// MinZoom and MaxZoom are the lowest and highest zooms at which points are clustered.
+	// Points are not clustered in tiles beyond MaxZoom.
+	MinZoom, MaxZoom uint32
+
+	// MinPoints is the fewest points that form a cluster. If less than 2, 2 is used.
+	MinPoints int
+
+	// Radius within which points are clustered, in tile coordinates.
+	Radius float64
+
+	// Extent of the tiles. If zero, 512 is used.
+	Extent uint32
+
+	// Reducers aggregate the tags of the points in each cluster into tags of the cluster.
+	Reducers []Reducer
+}
+
+// DefaultOptions are the defaults of supercluster.
+var DefaultOptions = Options{
+	MinZoom:   0,
+	MaxZoom:   16,
+	MinPoints: 2,
+	Radius:    40,
+	Extent:    512,
+}
+
+// Index holds the clusters of a set of points at each zoom. It is safe for concurrent use.
+type Index struct {
+	opts     Options
+	features []mvt.Feature
+	// trees holds the points and clusters at each zoom, from MinZoom to MaxZoom+1,
+	// where there is a point for every feature.
+	trees [][]node
+	index []*kdTree
+}
+
+// node is a point or cluster in the index, in the unit square of the Web Mercator world.
+type node struct {
+	x, y float64
+	// zoom is the zoom at which the node was last clustered, or unclustered if it has not been.
+	zoom int
+	// feature is the index of the feature of a point, or -1 for a cluster.
+	feature int
+	// id is the ID of a cluster.
+	id uint64
+	// parent is the ID of the cluster that contains the node, or zero if none does.
+	parent uint64
+	count  int
+	values []interface{}
+}
+
+// New returns an index of the clusters of the features, whose geometries must be points.
+func New(features []mvt.Feature, opts Options) (*Index, error) {
+	return NewContext(context.Background(), features, opts)
+}
+
+// NewContext returns an index of the clusters of the features, whose geometries must be points.
+// Clustering stops between points if ctx is done, and ctx.Err() is returned.
+func NewContext(ctx context.Context, features []mvt.Feature, opts Options) (*Index, error) {
+	if opts.Extent == 0 {
+		opts.Extent = 512
+	}
+	if opts.MinPoints < 2 {
+		opts.MinPoints = 2
+	}
+
+	if opts.MaxZoom > maxClusterZoom {
+		return nil, fmt.Errorf("max zoom must be at most %d", maxClusterZoom)
+	} else if opts.MinZoom > opts.MaxZoom {
+		return nil, fmt.Errorf("min zoom must be at most max zoom")
+	} else if opts.Radius < 0 {
+		return nil, fmt.Errorf("radius must not be negative")
+	}
+
+	ix := Index{
+		opts:     opts,
+		features: features,
+		trees:    make([][]node, opts.MaxZoom+2),
+		index:    make([]*kdTree, opts.MaxZoom+2),
+	}
+
+	project := tiler.Project(tiler.TileID{}, 1)
+	points := make([]node, len(features))
+	for i, f := range features {
+		point, ok := f.Geometry.(*geojson.Point)
+		if !ok {
+			return nil, &mvt.FeatureError{Index: i, ID: f.ID, Offset: -1,
+				Err: fmt.Errorf("'%T' is not a point", f.Geometry)}
+		}
+
+		p := project(point.LatLng)
+		points[i] = node{
+			x:       p.X,
+			y:       p.Y,
+			zoom:    unclustered,
+			feature: i,
+			count:   1,
+		}
+		if len(opts.Reducers) > 0 {
+			points[i].values = ix.mapValues(f.Tags)
+		}
+	}
+
+	z := int(opts.MaxZoom) + 1
+	ix.trees[z], ix.index[z] = points, newKDTree(points)
+	for z--; z >= int(opts.MinZoom); z-- {
+		clusters, err := ix.cluster(ctx, z)
+		if err != nil {
+			return nil, err
+		}
+		ix.trees[z], ix.index[z] = clusters, newKDTree(clusters)
+	}
+	return &ix, nil
+}
+
+// cluster returns the clusters at the zoom, made from the points and clusters at the next zoom.
+func (ix *Index) cluster(ctx context.Context, zoom int) ([]node, error) {
+	points, tree := ix.trees[zoom+1], ix.index[zoom+1]
+	r := ix.opts.Radius / (float64(ix.opts.Extent) * math.Ldexp(1, zoom))
+
+	var clusters []node
+	for i := range points {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		p := &points[i]
+		if p.zoom <= zoom {
+			continue
+		}
+		p.zoom = zoom
+
+		neighbors := tree.within(p.x, p.y, r)
+		count := p.count
+		for _, n := range neighbors {
+			if points[n].zoom > zoom {
+				count += points[n].count
+			}
+		}
+
+		if count > p.count && count >= ix.opts.MinPoints {
+			// The ID identifies the origin of the cluster at the next zoom, and so its children.
+			id := uint64(i)<<clusterZoomBits + uint64(zoom+1) + uint64(len(ix.features))
+			wx, wy := p.x*float64(p.count), p.y*float64(p.count)
+
+			var values []interface{}
+			if len(ix.opts.Reducers) > 0 {
+				values = append(values, p.values...)
+			}
+
+			for _, n := range neighbors {
+				neighbor := &points[n]
+				if neighbor.zoom <= zoom {
+					continue
+				}
+				neighbor.zoom = zoom
+				neighbor.parent = id
+
+				wx += neighbor.x * float64(neighbor.count)
+				wy += neighbor.y * float64(neighbor.count)
+				ix.reduceValues(values, neighbor.values)
+			}
+
+			p.parent = id
+			clusters = append(clusters, node{
+				x:       wx / float64(count),
+				y:       wy / float64(count),
+				zoom:    unclustered,
+				feature: -1,
+				id:      id,
+				count:   count,
+				values:  values,
+			})
+			continue
+		}
+
+		clusters = append(clusters, *p)
+		if count > 1 {
+			for _, n := range neighbors {
+				neighbor := &points[n]
+				if neighbor.zoom <= zoom {
+					continue
+				}
+				neighbor.zoom = zoom
+				clusters = append(clusters, *neighbor)
+			}
+		}
+	}
+	return clusters, nil
+}
+
+// Tile returns the points and clusters in the tile, with a TileGeometry.
+// Points have the ID and tags of their feature. Clusters have no ID, and have ClusterTag, ClusterIDTag, PointCountTag
+// and PointCountAbbreviatedTag, followed by the tag of each reducer that has a value.
+// Points and clusters within the radius of the tile's edges are included, across the antimeridian too.
+// The world tile holds both a point near the antimeridian and its copy from across it, so the copy has no ID.
+func (ix *Index) Tile(id tiler.TileID) (mvt.Layer, error) {
+	if !id.Valid() {
+		return mvt.Layer{}, fmt.Errorf("invalid tile '%v'", id)
+	}
+
+	z := ix.limitZoom(id.Z)
+	points, tree := ix.trees[z], ix.index[z]
+
+	scale := math.Ldexp(1, int(id.Z))
+	x, y := float64(id.X), float64(id.Y)
+	p := ix.opts.Radius / float64(ix.opts.Extent)
+	top, bottom := (y-p)/scale, (y+1+p)/scale
+
+	layer := mvt.Layer{Extent: ix.opts.Extent}
+	add := func(ids []int, offset float64) {
+		for _, i := range ids {
+			n := points[i]
+			f := ix.feature(n)
+			if offset != 0 && scale == 1 {
+				// The world tile also holds the point that is wrapped, and IDs must be unique within a layer.
+				f.ID = mvt.OptionalUint64{}
+			}
+			f.Geometry = &mvt.TileGeometry{
+				GeomType: mvt.TilePointType,
+				Parts: [][]mvt.TilePoint{{{
+					X: int32(math.Round(float64(ix.opts.Extent) * (n.x*scale - offset - x))),
+					Y: int32(math.Round(float64(ix.opts.Extent) * (n.y*scale - y))),
+				}}},
+			}
+			layer.Features = append(layer.Features, f)
+		}
+	}
+
+	add(tree.rangeQuery((x-p)/scale, top, (x+1+p)/scale, bottom), 0)
+	// Points across the antimeridian are included in the tiles at the edges of the world.
+	if id.X == 0 {
+		add(tree.rangeQuery(1-p/scale, top, 1, bottom), scale)
+	}
+	if float64(id.X) == scale-1 {
+		add(tree.rangeQuery(0, top, p/scale, bottom), -scale)
+	}
+	return layer, nil
+}
+
+// Children returns the points and clusters that make up the cluster at the next zoom.
+// Points are returned as they were supplied, and clusters have the tags described by Tile and a geographic point geometry.
+func (ix *Index) Children(clusterID uint64) ([]mvt.Feature, error) {
+	nodes, err := ix.children(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	unproject := tiler.Unproject(tiler.TileID{}, 1)
+	features := make([]mvt.Feature, len(nodes))
+	for i, n := range nodes {
+		features[i] = ix.feature(n)
+		if n.feature < 0 {
+			features[i].Geometry = &geojson.Point{LatLng: unproject(r2.Point{X: n.x, Y: n.y})}
+		}
+	}
+	return features, nil
+}
+
+// Leaves returns the features of the points in the cluster, skipping offset points and returning at most limit.
+// If limit is negative, every point after the offset is returned.
+func (ix *Index) Leaves(clusterID uint64, limit, offset int) ([]mvt.Feature, error) {
+	var leaves []mvt.Feature
+	var skipped int
+
+	var walk func(id uint64) error
+	walk = func(id uint64) error {
+		children, err := ix.children(id)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range children {
+			if limit >= 0 && len(leaves) == limit {
+				return nil
+			}
+
+			switch {
+			case n.feature < 0 && skipped+n.count <= offset:
+				skipped += n.count
+			case n.feature < 0:
+				if err := walk(n.id); err != nil {
+					return err
+				}
+			case skipped < offset:
+				skipped++
+			default:
+				leaves = append(leaves, ix.features[n.feature])
+			}
+		}
+		return nil
+	}
+
+	if err := walk(clusterID); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+// ExpansionZoom returns the zoom at which the cluster splits into more than one point or cluster.
+func (ix *Index) ExpansionZoom(clusterID uint64) (uint32, error) {
+	zoom, _, err := ix.decodeID(clusterID)
+	if err != nil {
+		return 0, err
+	}
+
+	for zoom <= int(ix.opts.MaxZoom) {
+		children, err := ix.children(clusterID)
+		if err != nil {
+			return 0, err
+		}
+
+		zoom++
+		if len(children) != 1 || children[0].feature >= 0 {
+			break
+		}
+		clusterID = children[0].id
+	}
+	return uint32(zoom), nil
+}
+
+// clusterTags returns the tags of a cluster.
+func (ix *Index) clusterTags(n node) geojson.PropertyList {
+	tags := geojson.PropertyList{
+		{Name: ClusterTag, Value: true},
+		{Name: ClusterIDTag, Value: n.id},
+		{Name: PointCountTag, Value: int64(n.count)},
+		{Name: PointCountAbbreviatedTag, Value: abbreviate(n.count)},
+	}
+	for i, r := range ix.opts.Reducers {
+		if n.values[i] != nil {
+			tags = append(tags, geojson.Property{Name: r.Name, Value: n.values[i]})
+		}
+	}
+	return tags
+}
+
+// feature returns the feature of a point, or the tags of a cluster.
+func (ix *Index) feature(n node) mvt.Feature {
+	if n.feature >= 0 {
+		return ix.features[n.feature]
+	}
+	return mvt.Feature{Tags: ix.clusterTags(n)}
+}
+
+// children returns the nodes that make up the cluster at the next zoom.
+func (ix *Index) children(clusterID uint64) ([]node, error) {
+	zoom, origin, err := ix.decodeID(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	points, tree := ix.trees[zoom+1], ix.index[zoom+1]
+	r := ix.opts.Radius / (float64(ix.opts.Extent) * math.Ldexp(1, zoom))
+
+	var children []node
+	o := points[origin]
+	for _, i := range tree.within(o.x, o.y, r) {
+		if points[i].parent == clusterID {
+			children = append(children, points[i])
+		}
+	}
+
+	if len(children) == 0 {
+		return nil, fmt.Errorf("no cluster with ID %d", clusterID)
+	}
+	return children, nil
+}
+
+// decodeID returns the zoom of the cluster, and the index of its origin at the next zoom.
+func (ix *Index) decodeID(clusterID uint64) (zoom, origin int, err error) {
+	n := uint64(len(ix.features))
+	if clusterID < n {
+		return 0, 0, fmt.Errorf("no cluster with ID %d", clusterID)
+	}
+
+	v := clusterID - n
+	zoom = int(v&clusterZoomMask) - 1
+	origin = int(v >> clusterZoomBits)
+	if zoom < int(ix.opts.MinZoom) || zoom > int(ix.opts.MaxZoom) || origin >= len(ix.trees[zoom+1]) {
+		return 0, 0, fmt.Errorf("no cluster with ID %d", clusterID)
+	}
+	return zoom, origin, nil
+}
+
+// limitZoom returns the zoom of the clusters shown in tiles at the zoom.
+func (ix *Index) limitZoom(z uint32) int {
+	return int(max(ix.opts.MinZoom, min(z, ix.opts.MaxZoom+1)))
+}
+
+// abbreviate returns the count in a short form, such as "12k".
+func abbreviate(count int) string {
+	switch {
+	case count >= 1000000:
+		return strconv.Itoa(int(math.Round(float64(count)/1000000))) + "M"
+	case count >= 10000:
+		return strconv.Itoa(int(math.Round(float64(count)/1000))) + "k"
+	case count >= 1000:
+		return strconv.FormatFloat(math.Round(float64(count)/100)/10, 'f', -1, 64) + "k"
+	default:
+		return strconv.Itoa(count)
+	}
+}
@@ -0,0 +1,248 @@
+package cluster_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt "github.com/everystreet/go-mvt"
+	"github.com/everystreet/go-mvt/cluster"
+	"github.com/everystreet/go-mvt/tiler"
+	"github.com/golang/geo/r2"
+	"github.com/stretchr/testify/require"
+)
+
+// randomPoints returns points with a "population" tag, spread over Europe.
+func randomPoints(n int) []mvt.Feature {
+	r := rand.New(rand.NewSource(1))
+	features := make([]mvt.Feature, n)
+	for i := range features {
+		features[i] = mvt.Feature{
+			ID:       mvt.NewOptionalUint64(uint64(i)),
+			Geometry: geojson.NewPoint(40+r.Float64()*20, -10+r.Float64()*40).Geometry,
+			Tags:     geojson.PropertyList{{Name: "population", Value: int64(i)}},
+		}
+	}
+	return features
+}
+
+func tag(f mvt.Feature, name string) interface{} {
+	for _, t := range f.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return nil
+}
+
+func TestIndex(t *testing.T) {
+	features := randomPoints(1000)
+	opts := cluster.DefaultOptions
+	opts.Reducers = []cluster.Reducer{
+		cluster.Sum("population", "population"),
+		cluster.Max("largest", "population"),
+	}
+	ix, err := cluster.New(features, opts)
+	require.NoError(t, err)
+
+	layer, err := ix.Tile(tiler.TileID{})
+	require.NoError(t, err)
+	require.Equal(t, uint32(512), layer.Extent)
+
+	var points, clusters int
+	var population float64
+	for _, f := range layer.Features {
+		if tag(f, cluster.ClusterTag) == nil {
+			points++
+			population += float64(tag(f, "population").(int64))
+			continue
+		}
+
+		clusters++
+		id := tag(f, cluster.ClusterIDTag).(uint64)
+		count := int(tag(f, cluster.PointCountTag).(int64))
+		points += count
+		population += tag(f, "population").(float64)
+
+		// The leaves of the cluster are its points, and its children add up to it.
+		leaves, err := ix.Leaves(id, -1, 0)
+		require.NoError(t, err)
+		require.Len(t, leaves, count)
+
+		var largest float64
+		for _, leaf := range leaves {
+			largest = math.Max(largest, float64(tag(leaf, "population").(int64)))
+		}
+		require.Equal(t, largest, tag(f, "largest"))
+
+		if count > 15 {
+			page, err := ix.Leaves(id, 10, 5)
+			require.NoError(t, err)
+			require.Equal(t, leaves[5:15], page)
+		}
+
+		children, err := ix.Children(id)
+		require.NoError(t, err)
+		var total int
+		for _, child := range children {
+			if n, ok := tag(child, cluster.PointCountTag).(int64); ok {
+				total += int(n)
+			} else {
+				total++
+			}
+		}
+		require.Equal(t, count, total)
+
+		zoom, err := ix.ExpansionZoom(id)
+		require.NoError(t, err)
+		require.Greater(t, zoom, uint32(0))
+		require.LessOrEqual(t, zoom, opts.MaxZoom+1)
+	}
+	require.Greater(t, clusters, 0)
+	require.Equal(t, 1000, points)
+	require.Equal(t, float64(999*1000/2), population)
+
+	_, err = mvt.Marshal(mvt.Layers{"poi": layer}, nil)
+	require.NoError(t, err)
+
+	t.Run("beyond max zoom", func(t *testing.T) {
+		// Every point is in its own feature, including those in the buffer around the tile.
+		first := tiler.Project(tiler.TileID{}, 1)(features[0].Geometry.(*geojson.Point).LatLng)
+		id := tiler.TileID{Z: 18, X: uint32(math.Ldexp(first.X, 18)), Y: uint32(math.Ldexp(first.Y, 18))}
+		project := tiler.Project(id, 512)
+
+		var expected []mvt.Feature
+		for _, f := range features {
+			p := project(f.Geometry.(*geojson.Point).LatLng)
+			if p.X >= -40 && p.X <= 552 && p.Y >= -40 && p.Y <= 552 {
+				expected = append(expected, f)
+			}
+		}
+
+		for z := uint32(6); z <= 20; z++ {
+			id := tiler.TileID{Z: z, X: uint32(math.Ldexp(0.48, int(z))), Y: uint32(math.Ldexp(0.3, int(z)))}
+			layer, err := ix.Tile(id)
+			require.NoError(t, err)
+			if z > opts.MaxZoom {
+				for _, f := range layer.Features {
+					require.Nil(t, tag(f, cluster.ClusterTag))
+				}
+			}
+		}
+
+		layer, err := ix.Tile(id)
+		require.NoError(t, err)
+		require.NotEmpty(t, expected)
+		require.Len(t, layer.Features, len(expected))
+	})
+}
+
+func TestExpansionZoom(t *testing.T) {
+	// Two points are clustered up to zoom 5, where their distance is less than the radius.
+	d := 0.75 * 40 / (512 * 32)
+	unproject := tiler.Unproject(tiler.TileID{}, 1)
+	features := []mvt.Feature{
+		{Geometry: &geojson.Point{LatLng: unproject(r2.Point{X: 0.3, Y: 0.3})}},
+		{Geometry: &geojson.Point{LatLng: unproject(r2.Point{X: 0.3 + d, Y: 0.3})}},
+	}
+
+	ix, err := cluster.New(features, cluster.DefaultOptions)
+	require.NoError(t, err)
+
+	for z := uint32(0); z <= 7; z++ {
+		scale := math.Ldexp(1, int(z))
+		layer, err := ix.Tile(tiler.TileID{Z: z, X: uint32(0.3 * scale), Y: uint32(0.3 * scale)})
+		require.NoError(t, err)
+
+		if z > 5 {
+			require.Len(t, layer.Features, 2)
+			continue
+		}
+
+		require.Len(t, layer.Features, 1)
+		f := layer.Features[0]
+		require.Equal(t, int64(2), tag(f, cluster.PointCountTag))
+		require.Equal(t, "2", tag(f, cluster.PointCountAbbreviatedTag))
+
+		zoom, err := ix.ExpansionZoom(tag(f, cluster.ClusterIDTag).(uint64))
+		require.NoError(t, err)
+		require.Equal(t, uint32(6), zoom)
+
+		children, err := ix.Children(tag(f, cluster.ClusterIDTag).(uint64))
+		require.NoError(t, err)
+		require.ElementsMatch(t, features, children)
+	}
+}
+
+func TestIndexWrap(t *testing.T) {
+	ix, err := cluster.New([]mvt.Feature{
+		{ID: mvt.NewOptionalUint64(1), Geometry: geojson.NewPoint(0, 179.9).Geometry},
+		{ID: mvt.NewOptionalUint64(2), Geometry: geojson.NewPoint(0, -179.9).Geometry},
+	}, cluster.DefaultOptions)
+	require.NoError(t, err)
+
+	// Each point and its copy across the antimeridian are in the world tile, where only the point keeps its ID.
+	layer, err := ix.Tile(tiler.TileID{})
+	require.NoError(t, err)
+	require.Len(t, layer.Features, 4)
+	_, err = mvt.Marshal(mvt.Layers{"points": layer}, nil)
+	require.NoError(t, err)
+
+	var ids []uint64
+	for _, f := range layer.Features {
+		if id, ok := f.ID.Get(); ok {
+			ids = append(ids, id)
+		}
+	}
+	require.ElementsMatch(t, []uint64{1, 2}, ids)
+
+	// Away from the point it copies, a copy keeps its ID.
+	layer, err = ix.Tile(tiler.TileID{Z: 1, X: 0, Y: 0})
+	require.NoError(t, err)
+	require.Len(t, layer.Features, 2)
+	for _, f := range layer.Features {
+		require.True(t, f.ID.IsSet())
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	_, err := cluster.New([]mvt.Feature{
+		{Geometry: geojson.NewPoint(1, 1).Geometry},
+		{Geometry: geojson.NewLineString(geojson.MakePosition(1, 1), geojson.MakePosition(2, 2)).Geometry},
+	}, cluster.DefaultOptions)
+	var featureErr *mvt.FeatureError
+	require.True(t, errors.As(err, &featureErr))
+	require.Equal(t, 1, featureErr.Index)
+
+	opts := cluster.DefaultOptions
+	opts.MaxZoom = 30
+	_, err = cluster.New(nil, opts)
+	require.Error(t, err)
+
+	opts = cluster.DefaultOptions
+	opts.MinZoom = 17
+	_, err = cluster.New(nil, opts)
+	require.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cluster.NewContext(ctx, randomPoints(10), cluster.DefaultOptions)
+	require.True(t, errors.Is(err, context.Canceled))
+
+	ix, err := cluster.New(randomPoints(10), cluster.DefaultOptions)
+	require.NoError(t, err)
+	for _, id := range []uint64{0, 9, 10, 1 << 40} {
+		_, err = ix.Children(id)
+		require.Error(t, err)
+		_, err = ix.ExpansionZoom(id)
+		require.Error(t, err)
+		_, err = ix.Leaves(id, -1, 0)
+		require.Error(t, err)
+	}
+
+	_, err = ix.Tile(tiler.TileID{Z: 1, X: 2})
+	require.Error(t, err)
+}
@@ -0,0 +1,160 @@
+package cluster
+
+import "math"
+
+// kdNodeSize is the number of points in the leaves of a kdTree, which are searched linearly.
+const kdNodeSize = 64
+
+// kdTree is a static index of points for range and radius queries, in the manner of kdbush.
+type kdTree struct {
+	ids    []int
+	coords []float64
+}
+
+// newKDTree returns an index of the points, whose ids are their positions in the slice.
+func newKDTree(points []node) *kdTree {
+	t := kdTree{
+		ids:    make([]int, len(points)),
+		coords: make([]float64, 2*len(points)),
+	}
+	for i, p := range points {
+		t.ids[i] = i
+		t.coords[2*i] = p.x
+		t.coords[2*i+1] = p.y
+	}
+	t.sort(0, len(points)-1, 0)
+	return &t
+}
+
+// sort arranges the points between left and right, inclusive, so that each node splits them at its median.
+func (t *kdTree) sort(left, right, axis int) {
+	if right-left <= kdNodeSize {
+		return
+	}
+
+	m := (left + right) >> 1
+	t.selectK(m, left, right, axis)
+	t.sort(left, m-1, 1-axis)
+	t.sort(m+1, right, 1-axis)
+}
+
+// selectK arranges the points between left and right so that the kth is in its sorted position along the axis,
+// with smaller points before it and larger points after it, using Floyd-Rivest selection.
+func (t *kdTree) selectK(k, left, right, axis int) {
+	for right > left {
+		if right-left > 600 {
+			n := float64(right - left + 1)
+			m := float64(k - left + 1)
+			z := math.Log(n)
+			s := 0.5 * math.Exp(2*z/3)
+			sd := 0.5 * math.Sqrt(z*s*(n-s)/n)
+			if m-n/2 < 0 {
+				sd = -sd
+			}
+			newLeft := max(left, int(math.Floor(float64(k)-m*s/n+sd)))
+			newRight := min(right, int(math.Floor(float64(k)+(n-m)*s/n+sd)))
+			t.selectK(k, newLeft, newRight, axis)
+		}
+
+		pivot := t.coords[2*k+axis]
+		i, j := left, right
+
+		t.swap(left, k)
+		if t.coords[2*right+axis] > pivot {
+			t.swap(left, right)
+		}
+
+		for i < j {
+			t.swap(i, j)
+			i++
+			j--
+			for t.coords[2*i+axis] < pivot {
+				i++
+			}
+			for t.coords[2*j+axis] > pivot {
+				j--
+			}
+		}
+
+		if t.coords[2*left+axis] == pivot {
+			t.swap(left, j)
+		} else {
+			j++
+			t.swap(j, right)
+		}
+
+		if j <= k {
+			left = j + 1
+		}
+		if k <= j {
+			right = j - 1
+		}
+	}
+}
+
+func (t *kdTree) swap(i, j int) {
+	t.ids[i], t.ids[j] = t.ids[j], t.ids[i]
+	t.coords[2*i], t.coords[2*j] = t.coords[2*j], t.coords[2*i]
+	t.coords[2*i+1], t.coords[2*j+1] = t.coords[2*j+1], t.coords[2*i+1]
+}
+
+// rangeQuery returns the ids of the points inside the box, including its edges.
+func (t *kdTree) rangeQuery(minX, minY, maxX, maxY float64) []int {
+	return t.search(func(x, y float64) bool {
+		return x >= minX && x <= maxX && y >= minY && y <= maxY
+	}, minX, minY, maxX, maxY)
+}
+
+// within returns the ids of the points within the radius of the point, including those on the circle.
+func (t *kdTree) within(qx, qy, r float64) []int {
+	r2 := r * r
+	return t.search(func(x, y float64) bool {
+		return (x-qx)*(x-qx)+(y-qy)*(y-qy) <= r2
+	}, qx-r, qy-r, qx+r, qy+r)
+}
+
+// search returns the ids of the points inside the box that match.
+func (t *kdTree) search(match func(x, y float64) bool, minX, minY, maxX, maxY float64) []int {
+	var result []int
+	if len(t.ids) == 0 {
+		return result
+	}
+
+	type span struct {
+		left, right, axis int
+	}
+	stack := []span{{0, len(t.ids) - 1, 0}}
+
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if s.right-s.left <= kdNodeSize {
+			for i := s.left; i <= s.right; i++ {
+				if match(t.coords[2*i], t.coords[2*i+1]) {
+					result = append(result, t.ids[i])
+				}
+			}
+			continue
+		}
+
+		m := (s.left + s.right) >> 1
+		x, y := t.coords[2*m], t.coords[2*m+1]
+		if match(x, y) {
+			result = append(result, t.ids[m])
+		}
+
+		lo, hi := minX, maxX
+		c := x
+		if s.axis == 1 {
+			lo, hi, c = minY, maxY, y
+		}
+		if lo <= c {
+			stack = append(stack, span{s.left, m - 1, 1 - s.axis})
+		}
+		if hi >= c {
+			stack = append(stack, span{m + 1, s.right, 1 - s.axis})
+		}
+	}
+	return result
+}
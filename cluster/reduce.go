@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"math"
+
+	"github.com/everystreet/go-geojson/v2"
+)
+
+// Reducer aggregates the tags of the points in a cluster into a tag of the cluster.
+type Reducer struct {
+	// Name of the tag of the cluster.
+	Name string
+
+	// Map returns the value of a point, or nil if it has none.
+	Map func(tags geojson.PropertyList) interface{}
+
+	// Reduce combines the values of two points or clusters, neither of which is nil.
+	Reduce func(a, b interface{}) interface{}
+}
+
+// Sum returns a reducer that sums the numeric tag of the points in each cluster as a float64.
+func Sum(name, tag string) Reducer {
+	return numericReducer(name, tag, func(a, b float64) float64 { return a + b })
+}
+
+// Min returns a reducer that finds the least value of the numeric tag of the points in each cluster, as a float64.
+func Min(name, tag string) Reducer {
+	return numericReducer(name, tag, math.Min)
+}
+
+// Max returns a reducer that finds the greatest value of the numeric tag of the points in each cluster, as a float64.
+func Max(name, tag string) Reducer {
+	return numericReducer(name, tag, math.Max)
+}
+
+func numericReducer(name, tag string, reduce func(a, b float64) float64) Reducer {
+	return Reducer{
+		Name: name,
+		Map: func(tags geojson.PropertyList) interface{} {
+			for _, t := range tags {
+				if t.Name != tag {
+					continue
+				}
+
+				switch v := t.Value.(type) {
+				case int64:
+					return float64(v)
+				case uint64:
+					return float64(v)
+				case float64:
+					return v
+				case float32:
+					return float64(v)
+				case int:
+					return float64(v)
+				}
+			}
+			return nil
+		},
+		Reduce: func(a, b interface{}) interface{} {
+			return reduce(a.(float64), b.(float64))
+		},
+	}
+}
+
+// mapValues returns the value of each reducer for a point.
+func (ix *Index) mapValues(tags geojson.PropertyList) []interface{} {
+	values := make([]interface{}, len(ix.opts.Reducers))
+	for i, r := range ix.opts.Reducers {
+		values[i] = r.Map(tags)
+	}
+	return values
+}
+
+// reduceValues combines the values of a point or cluster into the values of a cluster.
+func (ix *Index) reduceValues(values, other []interface{}) {
+	for i, r := range ix.opts.Reducers {
+		switch {
+		case other[i] == nil:
+		case values[i] == nil:
+			values[i] = other[i]
+		default:
+			values[i] = r.Reduce(values[i], other[i])
+		}
+	}
+}
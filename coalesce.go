@@ -0,0 +1,183 @@
+package mvt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/everystreet/go-geojson/v2"
+)
+
+// Coalesce merges the features of a layer that have equal tags, in the manner of tippecanoe's coalesce,
+// to save the overhead of encoding each as a separate feature.
+// Only features with a TileGeometry are merged, and only with features of the same geometry type.
+// Points are merged into a multipoint, and polygons into a multipolygon.
+// Lines are merged into a multilinestring, and lines that touch end to start are joined into one.
+// Lines are not reversed to join them, so their direction is preserved.
+type Coalesce struct {
+	// IDs is the rule for the IDs of merged features.
+	IDs CoalesceIDs
+
+	// Dissolve merges the polygons of merged features where they overlap or share edges, as TileGeometry.MakeValid
+	// merges overlapping polygons, so that a polygon cut into pieces by tile edges is whole again.
+	// Polygons are dissolved however many points they have, which takes time quadratic in the points of a merged feature
+	// at worst. Polygons with coordinates beyond ±2^29 can't be dissolved exactly, so their features are not merged.
+	// Merged features left without polygons are removed.
+	Dissolve bool
+}
+
+// CoalesceIDs is a rule for the IDs of merged features.
+type CoalesceIDs uint8
+
+// Rules for the IDs of merged features.
+const (
+	// CoalesceFirstID gives a merged feature the ID of the first feature it was merged from.
+	CoalesceFirstID CoalesceIDs = iota
+
+	// CoalesceDropID leaves the ID of a merged feature unset.
+	CoalesceDropID
+
+	// CoalesceByID only merges features with equal IDs, or that both have no ID.
+	CoalesceByID
+)
+
+// Layer returns the layer with its features merged.
+// A merged feature takes the place of the first feature it was merged from, and has its tags.
+// The supplied layer is not modified.
+func (c Coalesce) Layer(layer Layer) Layer {
+	type group struct {
+		index int
+		ids   []OptionalUint64
+		geo   TileGeometry
+	}
+
+	features := make([]Feature, 0, len(layer.Features))
+	groups := make(map[string]*group)
+	var order []*group
+
+	for _, f := range layer.Features {
+		g, ok := f.Geometry.(*TileGeometry)
+		if !ok || g == nil || (c.Dissolve && g.GeomType == TilePolygonType && !repairable(g.Parts)) {
+			features = append(features, f)
+			continue
+		}
+
+		key := coalesceKey(g.GeomType, f.Tags)
+		if c.IDs == CoalesceByID {
+			key = f.ID.String() + "\x00" + key
+		}
+
+		gr, ok := groups[key]
+		if !ok {
+			gr = &group{
+				index: len(features),
+				geo:   TileGeometry{GeomType: g.GeomType},
+			}
+			groups[key] = gr
+			order = append(order, gr)
+			features = append(features, f)
+		}
+		gr.ids = append(gr.ids, f.ID)
+		gr.geo.Parts = append(gr.geo.Parts, g.Parts...)
+	}
+
+	for _, gr := range order {
+		if len(gr.ids) == 1 {
+			continue
+		}
+
+		f := &features[gr.index]
+		if c.IDs == CoalesceDropID {
+			f.ID = OptionalUint64{}
+		}
+
+		geo := gr.geo
+		switch {
+		case geo.GeomType == TileLineStringType:
+			geo.Parts = joinLines(geo.Parts)
+		case geo.GeomType == TilePolygonType && c.Dissolve:
+			if geo.Parts = validRings(geo.Parts, true); len(geo.Parts) == 0 {
+				f.Geometry = nil
+				continue
+			}
+		}
+		f.Geometry = &geo
+	}
+
+	if c.Dissolve {
+		features = slices.DeleteFunc(features, func(f Feature) bool {
+			return f.Geometry == nil
+		})
+	}
+	return MakeLayer(layer.Extent, features...)
+}
+
+// coalesceKey returns a key that is equal for geometries of the same type with equal tags, in any order.
+func coalesceKey(t TileGeometryType, tags geojson.PropertyList) string {
+	tags = slices.Clone(tags)
+	slices.SortStableFunc(tags, func(a, b geojson.Property) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d", t)
+	for _, tag := range tags {
+		fmt.Fprintf(&b, "\x00%q=%T:%#v", tag.Name, tag.Value, tag.Value)
+	}
+	return b.String()
+}
+
+// joinLines returns the lines with each line that ends where another starts joined to it, in order of the first line.
+func joinLines(lines [][]TilePoint) [][]TilePoint {
+	starts := make(map[TilePoint][]int)
+	ends := make(map[TilePoint][]int)
+	for i, line := range lines {
+		if len(line) < 2 {
+			continue
+		}
+		starts[line[0]] = append(starts[line[0]], i)
+		ends[line[len(line)-1]] = append(ends[line[len(line)-1]], i)
+	}
+
+	used := make([]bool, len(lines))
+	next := func(index map[TilePoint][]int, p TilePoint) (int, bool) {
+		for _, i := range index[p] {
+			if !used[i] {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	out := make([][]TilePoint, 0, len(lines))
+	for i, line := range lines {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		if len(line) < 2 {
+			out = append(out, line)
+			continue
+		}
+
+		joined := slices.Clone(line)
+		for {
+			j, ok := next(starts, joined[len(joined)-1])
+			if !ok {
+				break
+			}
+			used[j] = true
+			joined = append(joined, lines[j][1:]...)
+		}
+		for {
+			j, ok := next(ends, joined[0])
+			if !ok {
+				break
+			}
+			used[j] = true
+			joined = append(slices.Clone(lines[j][:len(lines[j])-1]), joined...)
+		}
+		out = append(out, joined)
+	}
+	return out
+}
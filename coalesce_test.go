@@ -0,0 +1,144 @@
+package mvt_test
+
+import (
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesce(t *testing.T) {
+	line := func(points ...mvt21.TilePoint) *mvt21.TileGeometry {
+		return &mvt21.TileGeometry{GeomType: mvt21.TileLineStringType, Parts: [][]mvt21.TilePoint{points}}
+	}
+	box := func(x0, y0, x1, y1 int32) *mvt21.TileGeometry {
+		return &mvt21.TileGeometry{
+			GeomType: mvt21.TilePolygonType,
+			Parts:    [][]mvt21.TilePoint{{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}},
+		}
+	}
+	primary := geojson.PropertyList{{Name: "class", Value: "primary"}, {Name: "lanes", Value: int64(2)}}
+	reordered := geojson.PropertyList{{Name: "lanes", Value: int64(2)}, {Name: "class", Value: "primary"}}
+	minor := geojson.PropertyList{{Name: "class", Value: "minor"}}
+
+	layer := mvt21.MakeLayer(4096,
+		mvt21.Feature{ID: mvt21.NewOptionalUint64(1), Tags: primary, Geometry: line(mvt21.TilePoint{X: 10, Y: 0}, mvt21.TilePoint{X: 20, Y: 0})},
+		mvt21.Feature{ID: mvt21.NewOptionalUint64(2), Tags: minor, Geometry: line(mvt21.TilePoint{X: 0, Y: 5}, mvt21.TilePoint{X: 5, Y: 5})},
+		mvt21.Feature{ID: mvt21.NewOptionalUint64(3), Tags: primary, Geometry: line(mvt21.TilePoint{X: 0, Y: 0}, mvt21.TilePoint{X: 10, Y: 0})},
+		mvt21.Feature{ID: mvt21.NewOptionalUint64(4), Tags: reordered, Geometry: line(mvt21.TilePoint{X: 20, Y: 0}, mvt21.TilePoint{X: 20, Y: 10})},
+		mvt21.Feature{ID: mvt21.NewOptionalUint64(5), Tags: primary, Geometry: line(mvt21.TilePoint{X: 50, Y: 50}, mvt21.TilePoint{X: 60, Y: 60})},
+		mvt21.Feature{Tags: primary, Geometry: box(100, 100, 200, 200)},
+		mvt21.Feature{ID: mvt21.NewOptionalUint64(7), Tags: primary, Geometry: box(300, 300, 400, 400)},
+		mvt21.Feature{Tags: primary, Geometry: geojson.NewPoint(1, 1).Geometry},
+	)
+	original := mvt21.MakeLayer(layer.Extent, append([]mvt21.Feature(nil), layer.Features...)...)
+
+	t.Run("first id", func(t *testing.T) {
+		coalesced := mvt21.Coalesce{}.Layer(layer)
+		require.Equal(t, original, layer)
+		require.Equal(t, mvt21.MakeLayer(4096,
+			mvt21.Feature{ID: mvt21.NewOptionalUint64(1), Tags: primary, Geometry: &mvt21.TileGeometry{
+				GeomType: mvt21.TileLineStringType,
+				Parts: [][]mvt21.TilePoint{
+					{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 20, Y: 0}, {X: 20, Y: 10}},
+					{{X: 50, Y: 50}, {X: 60, Y: 60}},
+				},
+			}},
+			layer.Features[1],
+			mvt21.Feature{Tags: primary, Geometry: &mvt21.TileGeometry{
+				GeomType: mvt21.TilePolygonType,
+				Parts:    append(box(100, 100, 200, 200).Parts, box(300, 300, 400, 400).Parts...),
+			}},
+			layer.Features[7],
+		), coalesced)
+		require.NoError(t, coalesced.Validate())
+
+		_, err := mvt21.Marshal(mvt21.Layers{"roads": coalesced}, SimpleProject)
+		require.NoError(t, err)
+	})
+
+	t.Run("drop id", func(t *testing.T) {
+		coalesced := mvt21.Coalesce{IDs: mvt21.CoalesceDropID}.Layer(layer)
+		require.Len(t, coalesced.Features, 4)
+		require.False(t, coalesced.Features[0].ID.IsSet())
+		require.Equal(t, layer.Features[1], coalesced.Features[1])
+	})
+
+	t.Run("by id", func(t *testing.T) {
+		coalesced := mvt21.Coalesce{IDs: mvt21.CoalesceByID}.Layer(layer)
+		require.Equal(t, layer, coalesced)
+	})
+
+	t.Run("dissolve", func(t *testing.T) {
+		halves := mvt21.MakeLayer(4096,
+			mvt21.Feature{Tags: minor, Geometry: box(0, 0, 10, 10)},
+			mvt21.Feature{Tags: minor, Geometry: box(10, 0, 20, 10)},
+			mvt21.Feature{Tags: primary, Geometry: box(100, 100, 200, 200)},
+			mvt21.Feature{Tags: primary, Geometry: box(300, 300, 400, 400)},
+		)
+
+		coalesced := mvt21.Coalesce{Dissolve: true}.Layer(halves)
+		require.Len(t, coalesced.Features, 2)
+		require.Equal(t, [][]mvt21.TilePoint{{{X: 0, Y: 10}, {X: 0, Y: 0}, {X: 20, Y: 0}, {X: 20, Y: 10}}},
+			coalesced.Features[0].Geometry.(*mvt21.TileGeometry).Parts)
+		require.Len(t, coalesced.Features[1].Geometry.(*mvt21.TileGeometry).Parts, 2)
+
+		data, err := mvt21.Marshal(mvt21.Layers{"parks": coalesced}, nil)
+		require.NoError(t, err)
+		report, err := mvt21.ValidateTile(data)
+		require.NoError(t, err)
+		require.True(t, report.Valid(), report)
+
+		// Halves with more points than the validator checks for self-intersection are still dissolved.
+		jagged := func(x0, x1 int32) *mvt21.TileGeometry {
+			ring := []mvt21.TilePoint{{X: x0, Y: 0}, {X: x1, Y: 0}}
+			for x := x1; x >= x0; x-- {
+				ring = append(ring, mvt21.TilePoint{X: x, Y: 10 + x%2})
+			}
+			return &mvt21.TileGeometry{GeomType: mvt21.TilePolygonType, Parts: [][]mvt21.TilePoint{ring}}
+		}
+		coalesced = mvt21.Coalesce{Dissolve: true}.Layer(mvt21.MakeLayer(4096,
+			mvt21.Feature{Tags: minor, Geometry: jagged(0, 10000)},
+			mvt21.Feature{Tags: minor, Geometry: jagged(10000, 20000)},
+		))
+		require.Len(t, coalesced.Features, 1)
+		require.Len(t, coalesced.Features[0].Geometry.(*mvt21.TileGeometry).Parts, 1)
+
+		// Halves too far out to dissolve exactly are not merged.
+		far := mvt21.MakeLayer(4096,
+			mvt21.Feature{Tags: minor, Geometry: box(1<<30, 0, 1<<30+10, 10)},
+			mvt21.Feature{Tags: minor, Geometry: box(1<<30+10, 0, 1<<30+20, 10)},
+		)
+		require.Equal(t, far, mvt21.Coalesce{Dissolve: true}.Layer(far))
+	})
+}
+
+func TestLayerConfigsCoalesce(t *testing.T) {
+	layers := mvt21.Layers{"roads": mvt21.MakeLayer(4096,
+		mvt21.Feature{
+			Tags: geojson.PropertyList{{Name: "class", Value: "primary"}, {Name: "name", Value: "High Street"}},
+			Geometry: &mvt21.TileGeometry{
+				GeomType: mvt21.TileLineStringType,
+				Parts:    [][]mvt21.TilePoint{{{X: 0, Y: 0}, {X: 10, Y: 0}}},
+			},
+		},
+		mvt21.Feature{
+			Tags: geojson.PropertyList{{Name: "class", Value: "primary"}, {Name: "name", Value: "Low Street"}},
+			Geometry: &mvt21.TileGeometry{
+				GeomType: mvt21.TileLineStringType,
+				Parts:    [][]mvt21.TilePoint{{{X: 10, Y: 0}, {X: 10, Y: 10}}},
+			},
+		},
+	)}
+
+	// Features are merged once their names are removed.
+	configs := mvt21.LayerConfigs{"roads": {
+		Attributes: []mvt21.ZoomAttributes{{MinZoom: 10, Keys: []string{"class"}}, {MinZoom: 14}},
+		Coalesce:   &mvt21.Coalesce{},
+	}}
+	require.Len(t, configs.Layers(layers, 12)["roads"].Features, 1)
+	require.Len(t, configs.Layers(layers, 14)["roads"].Features, 2)
+
+	require.Error(t, mvt21.LayerConfig{Coalesce: &mvt21.Coalesce{IDs: 10}}.Validate())
+}
@@ -0,0 +1,175 @@
+package mvt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies the compression format of an encoded tile.
+type Compression uint8
+
+const (
+	// NoCompression leaves the encoded tile uncompressed.
+	NoCompression Compression = iota
+	// Gzip compresses the encoded tile with gzip.
+	Gzip
+	// Zstd compresses the encoded tile with zstandard.
+	Zstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	default:
+		return "unknown"
+	}
+}
+
+// DefaultMaxDecompressedSize is the maximum size of a decompressed tile,
+// used if UnmarshalOptions.MaxDecompressedSize is not set.
+const DefaultMaxDecompressedSize = 64 << 20
+
+// ErrDecompressedSizeExceeded is returned when a compressed tile expands beyond the maximum size.
+var ErrDecompressedSizeExceeded = errors.New("decompressed tile exceeds maximum size")
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// DetectCompression returns the compression format of data, determined by its magic bytes.
+func DetectCompression(data []byte) Compression {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return Gzip
+	case bytes.HasPrefix(data, zstdMagic):
+		return Zstd
+	default:
+		return NoCompression
+	}
+}
+
+// compressor holds compression state that can be reused between tiles.
+type compressor struct {
+	gz *gzip.Writer
+	zs *zstd.Encoder
+}
+
+// compress writes data to w using the specified compression format.
+func (c *compressor) compress(w io.Writer, data []byte, format Compression) error {
+	var wc io.WriteCloser
+	switch format {
+	case NoCompression:
+		_, err := w.Write(data)
+		return err
+	case Gzip:
+		if c.gz == nil {
+			c.gz = gzip.NewWriter(w)
+		} else {
+			c.gz.Reset(w)
+		}
+		wc = c.gz
+	case Zstd:
+		if c.zs == nil {
+			zs, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				return err
+			}
+			c.zs = zs
+		} else {
+			c.zs.Reset(w)
+		}
+		wc = c.zs
+	default:
+		return fmt.Errorf("unsupported compression '%v'", format)
+	}
+
+	if _, err := wc.Write(data); err != nil {
+		return err
+	}
+	return wc.Close()
+}
+
+// decompressor holds decompression state that can be reused between tiles.
+type decompressor struct {
+	gz *gzip.Reader
+	zs *zstd.Decoder
+	// zsMax is the maximum size that zs was created with.
+	zsMax int64
+	buf   bytes.Buffer
+}
+
+// decompress returns the decompressed contents of data.
+// If data is not compressed, it is returned unchanged.
+// The returned slice is only valid until the next call to decompress.
+func (d *decompressor) decompress(data []byte, max int64) ([]byte, error) {
+	if max <= 0 {
+		max = DefaultMaxDecompressedSize
+	}
+
+	var r io.Reader
+	switch format := DetectCompression(data); format {
+	case NoCompression:
+		return data, nil
+	case Gzip:
+		if d.gz == nil {
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read gzip header: %w", err)
+			}
+			d.gz = gz
+		} else if err := d.gz.Reset(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		r = d.gz
+	case Zstd:
+		if d.zs == nil || d.zsMax != max {
+			// The window of a frame is bounded too, so that a frame can't make the decoder allocate more than max.
+			if d.zs != nil {
+				d.zs.Close()
+			}
+			window := uint64(max)
+			if window < zstd.MinWindowSize {
+				window = zstd.MinWindowSize
+			}
+			zs, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1),
+				zstd.WithDecoderMaxMemory(uint64(max)), zstd.WithDecoderMaxWindow(window))
+			if err != nil {
+				d.zs = nil
+				return nil, err
+			}
+			d.zs, d.zsMax = zs, max
+		} else if err := d.zs.Reset(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		r = d.zs
+	}
+
+	d.buf.Reset()
+	if n, err := d.buf.ReadFrom(io.LimitReader(r, max+1)); errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return nil, ErrDecompressedSizeExceeded
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to decompress tile: %w", err)
+	} else if n > max {
+		return nil, ErrDecompressedSizeExceeded
+	}
+	return d.buf.Bytes(), nil
+}
+
+// close releases resources held by the decompressor.
+func (d *decompressor) close() {
+	if d.zs != nil {
+		d.zs.Close()
+		d.zs = nil
+	}
+}
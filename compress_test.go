@@ -0,0 +1,102 @@
+package mvt_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	layers := mvt21.Layers{
+		"points": {
+			Extent: 4096,
+			Features: []mvt21.Feature{
+				{
+					Geometry: geojson.NewPoint(34, 12).Geometry,
+					Tags: geojson.PropertyList{
+						{
+							Name:  "name",
+							Value: "value",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, compression := range []mvt21.Compression{mvt21.NoCompression, mvt21.Gzip, mvt21.Zstd} {
+		t.Run(compression.String(), func(t *testing.T) {
+			data, err := mvt21.MarshalOptions{
+				Project:     SimpleProject,
+				Compression: compression,
+			}.Marshal(layers)
+			require.NoError(t, err)
+			require.Equal(t, compression, mvt21.DetectCompression(data))
+
+			decoded, err := mvt21.Unmarshal(data, SimpleUnproject)
+			require.NoError(t, err)
+			require.Equal(t, layers, decoded)
+
+			var buf bytes.Buffer
+			enc := mvt21.NewEncoder(&buf)
+			enc.SetOptions(mvt21.MarshalOptions{
+				Project:     SimpleProject,
+				Compression: compression,
+			})
+
+			dec := mvt21.NewDecoder(&buf)
+			dec.SetOptions(mvt21.UnmarshalOptions{
+				Unproject: SimpleUnproject,
+			})
+
+			for i := 0; i < 2; i++ {
+				require.NoError(t, enc.Encode(layers))
+				require.Equal(t, compression, mvt21.DetectCompression(buf.Bytes()))
+
+				dec.Reset(&buf)
+				require.NoError(t, dec.Decode(&decoded))
+				require.Equal(t, layers, decoded)
+			}
+		})
+	}
+}
+
+func TestMaxDecompressedSize(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(make([]byte, 1<<20))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = mvt21.UnmarshalOptions{
+		MaxDecompressedSize: 1 << 10,
+	}.Unmarshal(buf.Bytes())
+	require.Equal(t, mvt21.ErrDecompressedSizeExceeded, err)
+}
+
+func TestMaxDecompressedSizeZstdWindow(t *testing.T) {
+	// A streamed frame declares its window rather than its size, so the decoder would allocate the whole window.
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf, zstd.WithWindowSize(8<<20))
+	require.NoError(t, err)
+	_, err = w.Write(make([]byte, 1<<18))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = mvt21.UnmarshalOptions{
+		MaxDecompressedSize: 1 << 19,
+	}.Unmarshal(buf.Bytes())
+	require.Equal(t, mvt21.ErrDecompressedSizeExceeded, err)
+
+	data, err := mvt21.MarshalOptions{Compression: mvt21.Zstd}.Marshal(mvt21.Layers{"empty": mvt21.MakeLayer(4096)})
+	require.NoError(t, err)
+	_, err = mvt21.UnmarshalOptions{
+		MaxDecompressedSize: 1 << 19,
+	}.Unmarshal(data)
+	require.NoError(t, err)
+}
@@ -0,0 +1,147 @@
+package mvt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
+	"github.com/stretchr/testify/require"
+)
+
+// largeLayers returns layers of single point features.
+func largeLayers(layers, features int) mvt21.Layers {
+	out := make(mvt21.Layers, layers)
+	for i := 0; i < layers; i++ {
+		layer := mvt21.Layer{
+			Extent:   4096,
+			Features: make([]mvt21.Feature, features),
+		}
+		for j := range layer.Features {
+			layer.Features[j] = mvt21.Feature{
+				Geometry: geojson.NewPoint(float64(j%90), float64(j%180)).Geometry,
+			}
+		}
+		out[mvt21.LayerName(fmt.Sprintf("layer%d", i))] = layer
+	}
+	return out
+}
+
+func TestMarshalContext(t *testing.T) {
+	layers := largeLayers(4, 50000)
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var calls atomic.Int64
+		_, err := mvt21.MarshalOptions{
+			Project: func(ll s2.LatLng) r2.Point {
+				calls.Add(1)
+				return SimpleProject(ll)
+			},
+		}.MarshalContext(ctx, layers)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Zero(t, calls.Load())
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := mvt21.MarshalOptions{Project: SimpleProject}.MarshalContext(ctx, layers)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprintf("during/%d", concurrency), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Cancel part way through the first layer, as if the client disconnected.
+			const cancelAt = 1000
+			var calls atomic.Int64
+			_, err := mvt21.MarshalOptions{
+				Project: func(ll s2.LatLng) r2.Point {
+					if calls.Add(1) == cancelAt {
+						cancel()
+					}
+					return SimpleProject(ll)
+				},
+				Concurrency: concurrency,
+			}.MarshalContext(ctx, layers)
+			require.Equal(t, context.Canceled, err)
+
+			// Each worker stops after the feature it was encoding.
+			require.LessOrEqual(t, calls.Load(), int64(cancelAt+concurrency))
+		})
+	}
+
+	t.Run("encoder", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var buf bytes.Buffer
+		enc := mvt21.NewEncoder(&buf)
+		enc.SetOptions(mvt21.MarshalOptions{Project: SimpleProject})
+		require.True(t, errors.Is(enc.EncodeContext(ctx, layers), context.Canceled))
+		require.Zero(t, buf.Len())
+	})
+}
+
+func TestUnmarshalContext(t *testing.T) {
+	data, err := mvt21.Marshal(largeLayers(4, 50000), SimpleProject)
+	require.NoError(t, err)
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		layers, err := mvt21.UnmarshalOptions{Unproject: SimpleUnproject}.UnmarshalContext(ctx, data)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Nil(t, layers)
+	})
+
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprintf("during/%d", concurrency), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			const cancelAt = 1000
+			var calls atomic.Int64
+			layers, err := mvt21.UnmarshalOptions{
+				Unproject: func(p r2.Point) s2.LatLng {
+					if calls.Add(1) == cancelAt {
+						cancel()
+					}
+					return SimpleUnproject(p)
+				},
+				// Cancellation is not an invalid feature, so it is never skipped.
+				InvalidFeatures: mvt21.DropInvalidFeatures,
+				Concurrency:     concurrency,
+			}.UnmarshalContext(ctx, data)
+			require.Equal(t, context.Canceled, err)
+			require.Nil(t, layers)
+			require.LessOrEqual(t, calls.Load(), int64(cancelAt+concurrency))
+		})
+	}
+
+	t.Run("decoder", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		dec := mvt21.NewDecoder(bytes.NewReader(data))
+		dec.SetOptions(mvt21.UnmarshalOptions{Unproject: SimpleUnproject})
+
+		var layers mvt21.Layers
+		require.True(t, errors.Is(dec.DecodeContext(ctx, &layers), context.Canceled))
+		require.Nil(t, layers)
+	})
+}
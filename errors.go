@@ -0,0 +1,95 @@
+package mvt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/everystreet/go-mvt/internal/geometry"
+)
+
+// Sections of the vector tile specification referenced by errors.
+const (
+	SectionLayers     = "4.1"
+	SectionFeatures   = "4.2"
+	SectionGeometry   = "4.3"
+	SectionAttributes = "4.4"
+)
+
+// LayerError records an error encoding or decoding a layer.
+type LayerError struct {
+	Layer LayerName
+	// Section of the vector tile specification that was violated, if known.
+	Section string
+	Err     error
+}
+
+func (e *LayerError) Error() string {
+	return fmt.Sprintf("layer '%s': %v%s", e.Layer, e.Err, sectionSuffix(e.Section))
+}
+
+// Unwrap returns the underlying error.
+func (e *LayerError) Unwrap() error {
+	return e.Err
+}
+
+// FeatureError records an error encoding or decoding a feature, and where the feature is in the tile.
+type FeatureError struct {
+	Layer LayerName
+	// Index of the feature in the layer.
+	Index int
+	ID    OptionalUint64
+	// Offset into the encoded geometry command sequence, or -1 if the error is not in the geometry.
+	Offset int
+	// Section of the vector tile specification that was violated, if known.
+	Section string
+	Err     error
+}
+
+func (e *FeatureError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "layer '%s': feature %d", e.Layer, e.Index)
+	if id, ok := e.ID.Get(); ok {
+		fmt.Fprintf(&b, " (ID %d)", id)
+	}
+	if e.Offset >= 0 {
+		fmt.Fprintf(&b, ": geometry offset %d", e.Offset)
+	}
+	fmt.Fprintf(&b, ": %v%s", e.Err, sectionSuffix(e.Section))
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *FeatureError) Unwrap() error {
+	return e.Err
+}
+
+// newFeatureError returns a FeatureError for the feature at index in layer.
+// Geometry errors are unwrapped to record their offset and section.
+func newFeatureError(layer LayerName, index int, id OptionalUint64, section string, err error) *FeatureError {
+	featureErr := FeatureError{
+		Layer:   layer,
+		Index:   index,
+		ID:      id,
+		Offset:  -1,
+		Section: section,
+		Err:     err,
+	}
+
+	var geoErr *geometry.Error
+	if errors.As(err, &geoErr) {
+		featureErr.Offset = geoErr.Offset
+		featureErr.Err = geoErr.Err
+		if geoErr.Section != "" {
+			featureErr.Section = geoErr.Section
+		}
+	}
+	return &featureErr
+}
+
+func sectionSuffix(section string) string {
+	if section == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (spec section %s)", section)
+}
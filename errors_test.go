@@ -0,0 +1,151 @@
+package mvt_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalFeatureError(t *testing.T) {
+	for _, tt := range []struct {
+		Name    string
+		Feature *spec.Tile_Feature
+		Offset  int
+		Section string
+	}{
+		{
+			Name: "tag key",
+			Feature: &spec.Tile_Feature{
+				Id:   proto.Uint64(67),
+				Type: spec.Tile_POINT.Enum(),
+				Tags: []uint32{1, 0},
+			},
+			Offset:  -1,
+			Section: mvt21.SectionAttributes,
+		},
+		{
+			Name: "odd tags",
+			Feature: &spec.Tile_Feature{
+				Id:   proto.Uint64(67),
+				Type: spec.Tile_POINT.Enum(),
+				Tags: []uint32{0},
+			},
+			Offset:  -1,
+			Section: mvt21.SectionAttributes,
+		},
+		{
+			Name: "second linestring",
+			Feature: &spec.Tile_Feature{
+				Id:       proto.Uint64(67),
+				Type:     spec.Tile_LINESTRING.Enum(),
+				Geometry: []uint32{9, 0, 0, 10, 2, 2, 9, 0, 0, 9, 2, 2},
+			},
+			Offset:  9,
+			Section: "4.3.3",
+		},
+		{
+			Name: "missing ClosePath",
+			Feature: &spec.Tile_Feature{
+				Id:       proto.Uint64(67),
+				Type:     spec.Tile_POLYGON.Enum(),
+				Geometry: []uint32{9, 0, 0, 18, 2, 0, 0, 2, 9, 0, 0},
+			},
+			Offset:  8,
+			Section: "4.3.4.4",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			data, err := proto.Marshal(&spec.Tile{
+				Layers: []*spec.Tile_Layer{
+					{
+						Version: proto.Uint32(2),
+						Name:    proto.String("my_layer"),
+						Keys:    []string{"key"},
+						Values:  []*spec.Tile_Value{newStringValue("value")},
+						Features: []*spec.Tile_Feature{
+							{
+								Type:     spec.Tile_POINT.Enum(),
+								Geometry: []uint32{9, 0, 0},
+							},
+							tt.Feature,
+						},
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			_, err = mvt21.Unmarshal(data, SimpleUnproject)
+
+			var featureErr *mvt21.FeatureError
+			require.True(t, errors.As(err, &featureErr))
+			require.Equal(t, mvt21.LayerName("my_layer"), featureErr.Layer)
+			require.Equal(t, 1, featureErr.Index)
+			require.Equal(t, uint64(67), featureErr.ID.Value())
+			require.Equal(t, tt.Offset, featureErr.Offset)
+			require.Equal(t, tt.Section, featureErr.Section)
+		})
+	}
+}
+
+func TestUnmarshalLayerError(t *testing.T) {
+	data, err := proto.Marshal(&spec.Tile{
+		Layers: []*spec.Tile_Layer{
+			newLayer("my_layer", 1, 4096),
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = mvt21.Unmarshal(data, SimpleUnproject)
+
+	var layerErr *mvt21.LayerError
+	require.True(t, errors.As(err, &layerErr))
+	require.Equal(t, mvt21.LayerName("my_layer"), layerErr.Layer)
+	require.Equal(t, mvt21.SectionLayers, layerErr.Section)
+}
+
+func TestUnmarshalMissingLayerName(t *testing.T) {
+	// A layer with only a version field.
+	data := []byte{0x1a, 0x02, 0x78, 0x02}
+
+	_, err := mvt21.Unmarshal(data, SimpleUnproject)
+
+	var layerErr *mvt21.LayerError
+	require.True(t, errors.As(err, &layerErr))
+	require.Equal(t, mvt21.SectionLayers, layerErr.Section)
+	require.EqualError(t, layerErr.Err, "missing name")
+}
+
+func TestMarshalFeatureError(t *testing.T) {
+	_, err := mvt21.Marshal(mvt21.Layers{
+		"my_layer": {
+			Features: []mvt21.Feature{
+				{
+					Geometry: geojson.NewPoint(1, 2).Geometry,
+				},
+				{
+					ID:       mvt21.NewOptionalUint64(67),
+					Geometry: geojson.NewPoint(1, 2).Geometry,
+					Tags: geojson.PropertyList{
+						{
+							Name:  "key",
+							Value: []int{1, 2},
+						},
+					},
+				},
+			},
+		},
+	}, SimpleProject)
+
+	var featureErr *mvt21.FeatureError
+	require.True(t, errors.As(err, &featureErr))
+	require.Equal(t, mvt21.LayerName("my_layer"), featureErr.Layer)
+	require.Equal(t, 1, featureErr.Index)
+	require.Equal(t, uint64(67), featureErr.ID.Value())
+	require.Equal(t, mvt21.SectionAttributes, featureErr.Section)
+	require.Contains(t, err.Error(), "unsupported type")
+}
@@ -0,0 +1,124 @@
+package mvt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	mvt21 "github.com/everystreet/go-mvt"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzUnmarshal(f *testing.F) {
+	// The seeds are hand-written tiles, both valid and invalid.
+	paths, err := filepath.Glob(filepath.Join("testdata", "seeds", "*.mvt"))
+	require.NoError(f, err)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		opts := mvt21.UnmarshalOptions{
+			Unproject: SeedUnproject,
+			Limits: mvt21.Limits{
+				MaxVertices:   1 << 16,
+				MaxAllocation: 1 << 24,
+			},
+		}
+
+		if layers, err := opts.Unmarshal(data); err == nil {
+			_, _ = mvt21.MarshalOptions{Project: SeedProject}.Marshal(layers)
+			_, _ = mvt21.Marshal(layers, nil)
+		}
+
+		opts.InvalidFeatures = mvt21.KeepInvalidFeatures
+		_, _ = opts.Unmarshal(data)
+
+		_, _ = mvt21.Unmarshal(data, nil)
+
+		_, _ = mvt21.ValidateTile(data)
+	})
+}
+
+func FuzzUnmarshalValue(f *testing.F) {
+	f.Add(uint8(1), "value", float32(0), float64(0), int64(0), uint64(0), int64(0), false)
+	f.Add(uint8(2), "", float32(3.142), float64(0), int64(0), uint64(0), int64(0), false)
+	f.Add(uint8(4), "", float32(0), float64(3.142), int64(0), uint64(0), int64(0), false)
+	f.Add(uint8(8), "", float32(0), float64(0), int64(-95), uint64(0), int64(0), false)
+	f.Add(uint8(16), "", float32(0), float64(0), int64(0), uint64(95), int64(0), false)
+	f.Add(uint8(32), "", float32(0), float64(0), int64(0), uint64(0), int64(-95), false)
+	f.Add(uint8(64), "", float32(0), float64(0), int64(0), uint64(0), int64(0), true)
+	f.Add(uint8(0), "", float32(0), float64(0), int64(0), uint64(0), int64(0), false)
+
+	f.Fuzz(func(t *testing.T, fields uint8, s string, f32 float32, f64 float64, i int64, u uint64, sint int64, b bool) {
+		var value spec.Tile_Value
+		if fields&1 != 0 {
+			value.StringValue = &s
+		}
+		if fields&2 != 0 {
+			value.FloatValue = &f32
+		}
+		if fields&4 != 0 {
+			value.DoubleValue = &f64
+		}
+		if fields&8 != 0 {
+			value.IntValue = &i
+		}
+		if fields&16 != 0 {
+			value.UintValue = &u
+		}
+		if fields&32 != 0 {
+			value.SintValue = &sint
+		}
+		if fields&64 != 0 {
+			value.BoolValue = &b
+		}
+
+		data, err := proto.Marshal(&spec.Tile{
+			Layers: []*spec.Tile_Layer{
+				{
+					Version: proto.Uint32(2),
+					Name:    proto.String("layer"),
+					Keys:    []string{"key"},
+					Values:  []*spec.Tile_Value{&value},
+					Features: []*spec.Tile_Feature{
+						{
+							Type: spec.Tile_UNKNOWN.Enum(),
+							Tags: []uint32{0, 0},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		layers, err := mvt21.Unmarshal(data, nil)
+		if fields&0x7f == 0 {
+			require.Error(t, err)
+			return
+		}
+		require.NoError(t, err)
+		require.Len(t, layers["layer"].Features[0].Tags, 1)
+	})
+}
+
+// SeedProject converts geographic coordinates to the tile coordinates of the seed tiles.
+// Tile coordinates have the Y axis pointing down, so latitude is negated.
+var SeedProject = func(ll s2.LatLng) r2.Point {
+	return r2.Point{
+		X: ll.Lng.Degrees(),
+		Y: -ll.Lat.Degrees(),
+	}
+}
+
+// SeedUnproject is the inverse of SeedProject.
+var SeedUnproject = func(p r2.Point) s2.LatLng {
+	return s2.LatLngFromDegrees(-p.Y, p.X)
+}
@@ -0,0 +1,318 @@
+package mvt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/everystreet/go-geojson/v2"
+	"github.com/everystreet/go-mvt/internal/geometry"
+)
+
+// Default property names used to convert between layers and GeoJSON.
+const (
+	DefaultLayerProperty       = "layer"
+	DefaultIDProperty          = "id"
+	DefaultRawGeometryProperty = "mvt_geometry"
+)
+
+// GeoJSONOptions configures the conversion between layers and GeoJSON feature collections.
+//
+// GeoJSON features have no ID member in go-geojson, so a feature ID is stored as a uint64 property.
+// Tags are copied to properties unchanged. Geometries are shared, not copied.
+// go-geojson can't decode a null geometry, so a feature without a geometry is given an empty GeometryCollection.
+// An UnknownGeometry has no GeoJSON equivalent, so it is also replaced by an empty GeometryCollection,
+// and its command sequence is stored as a []uint32 property.
+// A TileGeometry is in tile coordinates rather than geographic ones, so converting it to GeoJSON fails.
+//
+// Converting properties back to tags applies the reverse mappings,
+// along with the following to values that can't be encoded in a tile:
+// whole numbers of type float64, as produced by encoding/json, become int64 (or uint64 if too large),
+// objects and arrays are stored as their JSON encoding, and null values are dropped.
+type GeoJSONOptions struct {
+	// LayerProperty is the property that holds the name of a feature's layer.
+	// If empty, DefaultLayerProperty is used.
+	LayerProperty string
+
+	// IDProperty is the property that holds a feature's ID.
+	// If empty, DefaultIDProperty is used.
+	IDProperty string
+
+	// RawGeometryProperty is the property that holds the command sequence of an UnknownGeometry.
+	// If empty, DefaultRawGeometryProperty is used.
+	RawGeometryProperty string
+
+	// Extent of the layers built from feature collections. If zero, 4096 is used.
+	Extent uint32
+}
+
+// FeatureCollection returns a single collection holding the features of every layer.
+// Features are ordered by layer name, and the name is stored in LayerProperty.
+func (o GeoJSONOptions) FeatureCollection(layers Layers) (*geojson.FeatureCollection, error) {
+	var count int
+	for _, layer := range layers {
+		count += len(layer.Features)
+	}
+
+	collection := geojson.FeatureCollection{
+		Features: make([]geojson.Feature, 0, count),
+	}
+	for _, name := range layers.names() {
+		for i, feature := range layers[name].Features {
+			f, err := o.feature(feature, o.layerProperty())
+			if err != nil {
+				return nil, newFeatureError(name, i, feature.ID, "", err)
+			}
+			f.AddProperty(o.layerProperty(), string(name))
+			collection.Features = append(collection.Features, *f)
+		}
+	}
+	return &collection, nil
+}
+
+// FeatureCollections returns a collection for each layer.
+func (o GeoJSONOptions) FeatureCollections(layers Layers) (map[LayerName]*geojson.FeatureCollection, error) {
+	collections := make(map[LayerName]*geojson.FeatureCollection, len(layers))
+	for name, layer := range layers {
+		collection := geojson.FeatureCollection{
+			Features: make([]geojson.Feature, len(layer.Features)),
+		}
+		for i, feature := range layer.Features {
+			f, err := o.feature(feature, "")
+			if err != nil {
+				return nil, newFeatureError(name, i, feature.ID, "", err)
+			}
+			collection.Features[i] = *f
+		}
+		collections[name] = &collection
+	}
+	return collections, nil
+}
+
+// Layers returns the features of the collections grouped into layers by LayerProperty.
+// Every feature must have a string LayerProperty, which is not kept as a tag.
+// Features keep their order within each layer.
+func (o GeoJSONOptions) Layers(collections ...*geojson.FeatureCollection) (Layers, error) {
+	layers := make(Layers)
+	for i, collection := range collections {
+		for j, f := range collection.Features {
+			prop, ok := f.Properties.Get(o.layerProperty())
+			if !ok {
+				return nil, fmt.Errorf("collection %d: feature %d: missing '%s' property", i, j, o.layerProperty())
+			}
+
+			name, ok := prop.Value.(string)
+			if !ok {
+				return nil, fmt.Errorf("collection %d: feature %d: '%s' property is '%T', expecting string",
+					i, j, o.layerProperty(), prop.Value)
+			}
+
+			layer, ok := layers[LayerName(name)]
+			if !ok {
+				layer = MakeLayer(o.extent())
+			}
+
+			feature, err := o.layerFeature(f, o.layerProperty())
+			if err != nil {
+				return nil, fmt.Errorf("collection %d: feature %d: %w", i, j, err)
+			}
+			layer.Features = append(layer.Features, feature)
+			layers[LayerName(name)] = layer
+		}
+	}
+	return layers, nil
+}
+
+// LayersByName returns a layer for each named collection.
+func (o GeoJSONOptions) LayersByName(collections map[LayerName]*geojson.FeatureCollection) (Layers, error) {
+	layers := make(Layers, len(collections))
+	for name, collection := range collections {
+		layer := Layer{
+			Extent:   o.extent(),
+			Features: make([]Feature, len(collection.Features)),
+		}
+		for i, f := range collection.Features {
+			feature, err := o.layerFeature(f, "")
+			if err != nil {
+				return nil, fmt.Errorf("layer '%s': feature %d: %w", name, i, err)
+			}
+			layer.Features[i] = feature
+		}
+		layers[name] = layer
+	}
+	return layers, nil
+}
+
+// feature returns the GeoJSON feature, leaving room for the layer property.
+// A tag may not use any property name reserved by the conversion, including layerProp if it is set.
+func (o GeoJSONOptions) feature(feature Feature, layerProp string) (*geojson.Feature, error) {
+	f := geojson.Feature{
+		Geometry:   feature.Geometry,
+		Properties: make(geojson.PropertyList, 0, len(feature.Tags)+3),
+	}
+
+	switch geo := feature.Geometry.(type) {
+	case nil:
+		f.Geometry = &geojson.GeometryCollection{}
+	case *UnknownGeometry:
+		f.Geometry = &geojson.GeometryCollection{}
+		f.AddProperty(o.rawGeometryProperty(), []uint32(geo.RawShape))
+	case *TileGeometry:
+		return nil, fmt.Errorf("tile geometry has no GeoJSON equivalent, as it is in tile coordinates")
+	}
+
+	if id, ok := feature.ID.Get(); ok {
+		f.AddProperty(o.idProperty(), id)
+	}
+
+	for _, tag := range feature.Tags {
+		if (layerProp != "" && tag.Name == layerProp) || tag.Name == o.idProperty() || tag.Name == o.rawGeometryProperty() {
+			return nil, fmt.Errorf("tag '%s' conflicts with a GeoJSON property", tag.Name)
+		}
+		f.Properties = append(f.Properties, tag)
+	}
+	return &f, nil
+}
+
+// layerFeature returns the layer feature, skipping layerProp if it is set.
+func (o GeoJSONOptions) layerFeature(f geojson.Feature, layerProp string) (Feature, error) {
+	feature := Feature{
+		Geometry: f.Geometry,
+		Tags:     make(geojson.PropertyList, 0, len(f.Properties)),
+	}
+
+	if collection, ok := f.Geometry.(*geojson.GeometryCollection); ok {
+		if len(*collection) > 0 {
+			return Feature{}, fmt.Errorf("geometry collections are not supported")
+		}
+		feature.Geometry = nil
+	}
+
+	for _, prop := range f.Properties {
+		if layerProp != "" && prop.Name == layerProp {
+			continue
+		}
+
+		switch prop.Name {
+		case o.idProperty():
+			id, ok := wholeUint64(prop.Value)
+			if !ok {
+				return Feature{}, fmt.Errorf("'%s' property %v is not an unsigned integer", prop.Name, prop.Value)
+			}
+			feature.ID = NewOptionalUint64(id)
+		case o.rawGeometryProperty():
+			if feature.Geometry != nil {
+				return Feature{}, fmt.Errorf("'%s' property is set on a feature with a geometry", prop.Name)
+			}
+
+			raw, err := rawGeometry(prop.Value)
+			if err != nil {
+				return Feature{}, fmt.Errorf("'%s' property: %w", prop.Name, err)
+			}
+			feature.Geometry = &UnknownGeometry{RawShape: raw}
+		default:
+			value, ok, err := tagValue(prop.Value)
+			if err != nil {
+				return Feature{}, fmt.Errorf("property '%s': %w", prop.Name, err)
+			} else if ok {
+				feature.Tags = append(feature.Tags, geojson.Property{Name: prop.Name, Value: value})
+			}
+		}
+	}
+	return feature, nil
+}
+
+// tagValue returns the value of a property as it will be stored in a tag.
+// ok is false if the property should be dropped.
+func tagValue(value interface{}) (_ interface{}, ok bool, _ error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return v, true, nil
+		} else if v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v), true, nil
+		} else if v >= 0 && v < math.MaxUint64 {
+			return uint64(v), true, nil
+		}
+		return v, true, nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, false, err
+		}
+		return string(data), true, nil
+	default:
+		return v, true, nil
+	}
+}
+
+// wholeUint64 returns the value as a uint64 if it is a non-negative whole number.
+func wholeUint64(value interface{}) (uint64, bool) {
+	switch v := value.(type) {
+	case uint64:
+		return v, true
+	case uint32:
+		return uint64(v), true
+	case uint:
+		return uint64(v), true
+	case int64:
+		return uint64(v), v >= 0
+	case int:
+		return uint64(v), v >= 0
+	case float64:
+		return uint64(v), v >= 0 && v < math.MaxUint64 && v == math.Trunc(v)
+	default:
+		return 0, false
+	}
+}
+
+// rawGeometry returns the command sequence stored in a property,
+// which is a []uint32, or a []interface{} of numbers if the property was decoded from JSON.
+func rawGeometry(value interface{}) (geometry.RawShape, error) {
+	switch v := value.(type) {
+	case []uint32:
+		return append(geometry.RawShape(nil), v...), nil
+	case []interface{}:
+		raw := make(geometry.RawShape, len(v))
+		for i, n := range v {
+			u, ok := wholeUint64(n)
+			if !ok || u > math.MaxUint32 {
+				return nil, fmt.Errorf("element %d is not a uint32", i)
+			}
+			raw[i] = uint32(u)
+		}
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("'%T' is not a command sequence", value)
+	}
+}
+
+func (o GeoJSONOptions) layerProperty() string {
+	if o.LayerProperty == "" {
+		return DefaultLayerProperty
+	}
+	return o.LayerProperty
+}
+
+func (o GeoJSONOptions) idProperty() string {
+	if o.IDProperty == "" {
+		return DefaultIDProperty
+	}
+	return o.IDProperty
+}
+
+func (o GeoJSONOptions) rawGeometryProperty() string {
+	if o.RawGeometryProperty == "" {
+		return DefaultRawGeometryProperty
+	}
+	return o.RawGeometryProperty
+}
+
+func (o GeoJSONOptions) extent() uint32 {
+	if o.Extent == 0 {
+		return 4096
+	}
+	return o.Extent
+}
@@ -0,0 +1,198 @@
+package mvt_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/stretchr/testify/require"
+)
+
+func geoJSONLayers(t *testing.T) mvt21.Layers {
+	data, err := mvt21.Marshal(mvt21.Layers{
+		"roads": mvt21.MakeLayer(4096,
+			mvt21.Feature{
+				ID:       mvt21.NewOptionalUint64(7),
+				Geometry: geojson.NewLineString(geojson.MakePosition(1, 2), geojson.MakePosition(3, 4)).Geometry,
+				Tags: geojson.PropertyList{
+					{Name: "name", Value: "High Street"},
+					{Name: "lanes", Value: int64(-2)},
+					{Name: "width", Value: 7.5},
+					{Name: "oneway", Value: true},
+					{Name: "ref", Value: uint64(1 << 63)},
+				},
+			},
+		),
+		"places": mvt21.MakeLayer(4096,
+			mvt21.Feature{
+				Geometry: geojson.NewPoint(12, 34).Geometry,
+				Tags:     geojson.PropertyList{{Name: "id", Value: "not an ID"}},
+			},
+			mvt21.Feature{
+				ID:       mvt21.NewOptionalUint64(1),
+				Geometry: &mvt21.UnknownGeometry{RawShape: []uint32{9, 50, 34}},
+				Tags:     geojson.PropertyList{{Name: "layer", Value: "places"}},
+			},
+		),
+	}, SimpleProject)
+	require.NoError(t, err)
+
+	layers, err := mvt21.Unmarshal(data, SimpleUnproject)
+	require.NoError(t, err)
+	return layers
+}
+
+func TestFeatureCollection(t *testing.T) {
+	layers := geoJSONLayers(t)
+	opts := mvt21.GeoJSONOptions{IDProperty: "@id", LayerProperty: "@layer"}
+
+	collection, err := opts.FeatureCollection(layers)
+	require.NoError(t, err)
+
+	// Features are ordered by layer name.
+	require.Len(t, collection.Features, 3)
+	for i, name := range []string{"places", "places", "roads"} {
+		var layer string
+		require.NoError(t, collection.Features[i].Properties.GetValue("@layer", &layer))
+		require.Equal(t, name, layer)
+	}
+
+	// Unknown geometries are stored as a property.
+	unknown := collection.Features[1]
+	require.Equal(t, &geojson.GeometryCollection{}, unknown.Geometry)
+	require.Equal(t, geojson.PropertyList{
+		{Name: mvt21.DefaultRawGeometryProperty, Value: []uint32{9, 50, 34}},
+		{Name: "@id", Value: uint64(1)},
+		{Name: "layer", Value: "places"},
+		{Name: "@layer", Value: "places"},
+	}, unknown.Properties)
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(collection)
+		require.NoError(t, err)
+
+		var decoded geojson.FeatureCollection
+		require.NoError(t, json.Unmarshal(data, &decoded))
+
+		actual, err := opts.Layers(&decoded)
+		require.NoError(t, err)
+		requireLayersMatch(t, layers, actual)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := mvt21.GeoJSONOptions{}.FeatureCollection(layers)
+		var featureErr *mvt21.FeatureError
+		require.True(t, errors.As(err, &featureErr))
+		require.Equal(t, mvt21.LayerName("places"), featureErr.Layer)
+		require.Equal(t, 0, featureErr.Index)
+	})
+
+	t.Run("tile geometry", func(t *testing.T) {
+		_, err := opts.FeatureCollection(mvt21.Layers{"places": mvt21.MakeLayer(4096, mvt21.Feature{
+			Geometry: &mvt21.TileGeometry{GeomType: mvt21.TilePointType, Parts: [][]mvt21.TilePoint{{{X: 1, Y: 2}}}},
+		})})
+		var featureErr *mvt21.FeatureError
+		require.True(t, errors.As(err, &featureErr))
+		require.Contains(t, err.Error(), "tile coordinates")
+	})
+}
+
+func TestFeatureCollections(t *testing.T) {
+	layers := geoJSONLayers(t)
+	opts := mvt21.GeoJSONOptions{IDProperty: "@id"}
+
+	collections, err := opts.FeatureCollections(layers)
+	require.NoError(t, err)
+	require.Len(t, collections, 2)
+	require.Len(t, collections["roads"].Features, 1)
+	require.Len(t, collections["places"].Features, 2)
+
+	actual, err := opts.LayersByName(collections)
+	require.NoError(t, err)
+	require.Equal(t, layers, actual)
+}
+
+func TestGeoJSONLayers(t *testing.T) {
+	decode := func(t *testing.T, data string) *geojson.FeatureCollection {
+		var collection geojson.FeatureCollection
+		require.NoError(t, json.Unmarshal([]byte(data), &collection))
+		return &collection
+	}
+
+	t.Run("grouped", func(t *testing.T) {
+		layers, err := mvt21.GeoJSONOptions{Extent: 512}.Layers(
+			decode(t, `{"type": "FeatureCollection", "features": [
+				{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}, "properties": {"layer": "a", "id": 3}},
+				{"type": "Feature", "geometry": {"type": "Point", "coordinates": [3, 4]}, "properties": {"layer": "b"}}
+			]}`),
+			decode(t, `{"type": "FeatureCollection", "features": [
+				{"type": "Feature", "geometry": {"type": "Point", "coordinates": [5, 6]}, "properties": {"layer": "a"}}
+			]}`),
+		)
+		require.NoError(t, err)
+		require.Len(t, layers, 2)
+
+		a := layers["a"]
+		require.Equal(t, uint32(512), a.Extent)
+		require.Len(t, a.Features, 2)
+		require.Equal(t, mvt21.NewOptionalUint64(3), a.Features[0].ID)
+		require.Equal(t, geojson.NewPoint(2, 1).Geometry, a.Features[0].Geometry)
+		require.Equal(t, geojson.NewPoint(6, 5).Geometry, a.Features[1].Geometry)
+		require.Len(t, layers["b"].Features, 1)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		layers, err := mvt21.GeoJSONOptions{}.Layers(decode(t, `{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": []}, "properties": {
+				"layer": "a", "int": -3, "big": 18446744073709549568, "float": 1.5,
+				"null": null, "object": {"x": [1, "y"]}, "raw": "raw"
+			}}
+		]}`))
+		require.NoError(t, err)
+
+		feature := layers["a"].Features[0]
+		require.Nil(t, feature.Geometry)
+		require.ElementsMatch(t, geojson.PropertyList{
+			{Name: "int", Value: int64(-3)},
+			{Name: "big", Value: uint64(18446744073709549568)},
+			{Name: "float", Value: 1.5},
+			{Name: "object", Value: `{"x":[1,"y"]}`},
+			{Name: "raw", Value: "raw"},
+		}, feature.Tags)
+	})
+
+	for name, data := range map[string]string{
+		"missing layer": `{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": []}, "properties": {}}`,
+		"layer type":    `{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": []}, "properties": {"layer": 1}}`,
+		"negative ID":   `{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": []}, "properties": {"layer": "a", "id": -1}}`,
+		"fractional ID": `{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": []}, "properties": {"layer": "a", "id": 1.5}}`,
+		"raw geometry":  `{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": []}, "properties": {"layer": "a", "mvt_geometry": [-1]}}`,
+		"collection": `{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": [
+			{"type": "Point", "coordinates": [1, 2]}]}, "properties": {"layer": "a"}}`,
+		"raw and geometry": `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]},
+			"properties": {"layer": "a", "mvt_geometry": [9, 0, 0]}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := mvt21.GeoJSONOptions{}.Layers(decode(t, `{"type": "FeatureCollection", "features": [`+data+`]}`))
+			require.Error(t, err)
+		})
+	}
+}
+
+// requireLayersMatch checks that the layers are equal, ignoring the order of tags.
+func requireLayersMatch(t *testing.T, expected, actual mvt21.Layers) {
+	require.Len(t, actual, len(expected))
+	for name, layer := range expected {
+		require.Contains(t, actual, name)
+		require.Equal(t, layer.Extent, actual[name].Extent)
+		require.Len(t, actual[name].Features, len(layer.Features))
+
+		for i, feature := range layer.Features {
+			require.Equal(t, feature.ID, actual[name].Features[i].ID)
+			require.Equal(t, feature.Geometry, actual[name].Features[i].Geometry)
+			require.ElementsMatch(t, feature.Tags, actual[name].Features[i].Tags)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package geometry
+
+// CountVertices returns the number of vertices encoded in data, without decoding them.
+// It checks that each command is followed by enough parameter integers,
+// so it can be used to bound allocations before the geometry is decoded.
+func CountVertices(data []uint32) (int, error) {
+	var n int
+	for i := 0; i < len(data); {
+		cmd := CommandInteger(data[i])
+		if err := cmd.Validate(); err != nil {
+			return 0, errorAt(i, SectionCommands, "invalid command '%d': %w", data[i], err)
+		}
+		i++
+
+		switch cmd.ID() {
+		case MoveTo, LineTo:
+			count := int(cmd.Count())
+			if remaining := len(data) - i; remaining < count*2 {
+				return 0, errorAt(i-1, SectionCommands, "'%v' command expects %d integers, have %d",
+					cmd.ID(), count*2, remaining)
+			}
+			n += count
+			i += count * 2
+		}
+	}
+	return n, nil
+}
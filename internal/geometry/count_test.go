@@ -0,0 +1,29 @@
+package geometry_test
+
+import (
+	"testing"
+
+	"github.com/everystreet/go-mvt/internal/geometry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountVertices(t *testing.T) {
+	t.Run("polygon", func(t *testing.T) {
+		n, err := geometry.CountVertices([]uint32{9, 6, 12, 18, 10, 12, 24, 44, 15})
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		cmd, err := geometry.MakeCommandInteger(geometry.LineTo, 1<<29-1)
+		require.NoError(t, err)
+
+		_, err = geometry.CountVertices([]uint32{9, 0, 0, uint32(cmd), 2, 2})
+		require.Error(t, err)
+	})
+
+	t.Run("invalid command", func(t *testing.T) {
+		_, err := geometry.CountVertices([]uint32{3})
+		require.Error(t, err)
+	})
+}
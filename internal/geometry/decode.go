@@ -0,0 +1,58 @@
+package geometry
+
+import "github.com/golang/geo/r2"
+
+// Command is a single decoded command from a geometry command sequence.
+type Command struct {
+	ID CommandID
+	// Offset of the command integer in the command sequence.
+	Offset int
+	// Points are the absolute tile coordinates visited by a MoveTo or LineTo command.
+	Points []r2.Point
+}
+
+// Commands decodes the command sequence in data.
+// Parameters are resolved to absolute tile coordinates by applying them to the cursor,
+// which starts at (0,0) and is kept across the whole sequence.
+// Only the structure of the sequence is checked, not whether it forms a valid geometry.
+func Commands(data []uint32) ([]Command, error) {
+	var cmds []Command
+	var cursor r2.Point
+
+	for i := 0; i < len(data); {
+		cmd := CommandInteger(data[i])
+		if err := cmd.Validate(); err != nil {
+			return nil, errorAt(i, SectionCommands, "invalid command '%d': %w", data[i], err)
+		}
+
+		c := Command{
+			ID:     cmd.ID(),
+			Offset: i,
+		}
+		i++
+
+		switch c.ID {
+		case MoveTo, LineTo:
+			count := int(cmd.Count())
+			if remaining := len(data) - i; remaining < count*2 {
+				return nil, errorAt(c.Offset, SectionCommands, "'%v' command expects %d integers, have %d",
+					c.ID, count*2, remaining)
+			}
+
+			c.Points = make([]r2.Point, count)
+			for j := range c.Points {
+				cursor.X += float64(ParameterInteger(data[i]).Value())
+				cursor.Y += float64(ParameterInteger(data[i+1]).Value())
+				c.Points[j] = cursor
+				i += 2
+			}
+		case ClosePath:
+			if n := cmd.Count(); n != 1 {
+				return nil, errorAt(c.Offset, SectionCommands, "'ClosePath' command count must be 1, received '%d'", n)
+			}
+		}
+
+		cmds = append(cmds, c)
+	}
+	return cmds, nil
+}
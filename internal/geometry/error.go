@@ -0,0 +1,60 @@
+package geometry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sections of the vector tile specification referenced by geometry errors.
+const (
+	SectionCommands   = "4.3.3"
+	SectionParameters = "4.3.2"
+	SectionPoint      = "4.3.4.2"
+	SectionLineString = "4.3.4.3"
+	SectionPolygon    = "4.3.4.4"
+)
+
+// Error records an error in an encoded geometry and the offset at which it occurred.
+type Error struct {
+	// Offset into the command sequence.
+	Offset int
+	// Section of the vector tile specification that was violated, if known.
+	Section string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("offset %d: %v", e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorAt returns an Error at offset in the specified section.
+func errorAt(offset int, section string, format string, args ...interface{}) error {
+	return &Error{
+		Offset:  offset,
+		Section: section,
+		Err:     fmt.Errorf(format, args...),
+	}
+}
+
+// withOffset shifts the offset of err by base.
+// If err is not an Error, it becomes one located at base.
+func withOffset(base int, section string, err error) error {
+	var geoErr *Error
+	if errors.As(err, &geoErr) {
+		return &Error{
+			Offset:  base + geoErr.Offset,
+			Section: geoErr.Section,
+			Err:     geoErr.Err,
+		}
+	}
+	return &Error{
+		Offset:  base,
+		Section: section,
+		Err:     err,
+	}
+}
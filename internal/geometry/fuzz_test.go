@@ -0,0 +1,98 @@
+package geometry_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	"github.com/everystreet/go-mvt/internal/geometry"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, seed := range []struct {
+		Type spec.Tile_GeomType
+		Data []uint32
+	}{
+		{spec.Tile_POINT, []uint32{9, 50, 34}},
+		{spec.Tile_POINT, []uint32{17, 10, 14, 3, 9}},
+		{spec.Tile_LINESTRING, []uint32{9, 4, 4, 18, 0, 16, 16, 0}},
+		{spec.Tile_LINESTRING, []uint32{9, 4, 4, 18, 0, 16, 16, 0, 9, 17, 17, 10, 4, 8}},
+		{spec.Tile_POLYGON, []uint32{9, 6, 12, 18, 10, 12, 24, 44, 15}},
+		{spec.Tile_POLYGON, []uint32{9, 0, 0, 26, 20, 0, 0, 20, 19, 0, 15, 9, 22, 2, 26, 18, 0, 0, 18, 17, 0, 15,
+			9, 4, 13, 26, 0, 8, 8, 0, 0, 7, 15}},
+	} {
+		f.Add(uint8(seed.Type), encodeUint32s(seed.Data))
+	}
+
+	f.Fuzz(func(t *testing.T, typ uint8, b []byte) {
+		data := decodeUint32s(b)
+
+		n, countErr := geometry.CountVertices(data)
+		_, cmdsErr := geometry.Commands(data)
+		if cmdsErr == nil {
+			require.NoError(t, countErr)
+		}
+
+		var geo geojson.Geometry
+		typ %= 4
+		if err := geometry.Unmarshal(data, spec.Tile_GeomType(typ), SimpleUnproject, &geo); err != nil {
+			return
+		}
+
+		if spec.Tile_GeomType(typ) != spec.Tile_UNKNOWN {
+			require.NoError(t, countErr)
+			require.Equal(t, n, countPositions(geo))
+		}
+	})
+}
+
+func countPositions(geo geojson.Geometry) int {
+	switch geo := geo.(type) {
+	case *geojson.Point:
+		return 1
+	case *geojson.MultiPoint:
+		return len(*geo)
+	case *geojson.LineString:
+		return len(*geo)
+	case *geojson.MultiLineString:
+		var n int
+		for _, l := range *geo {
+			n += len(l)
+		}
+		return n
+	case *geojson.Polygon:
+		var n int
+		for _, l := range *geo {
+			n += len(l) - 1
+		}
+		return n
+	case *geojson.MultiPolygon:
+		var n int
+		for _, p := range *geo {
+			for _, l := range p {
+				n += len(l) - 1
+			}
+		}
+		return n
+	default:
+		return 0
+	}
+}
+
+func encodeUint32s(data []uint32) []byte {
+	b := make([]byte, len(data)*4)
+	for i, v := range data {
+		binary.LittleEndian.PutUint32(b[i*4:], v)
+	}
+	return b
+}
+
+func decodeUint32s(b []byte) []uint32 {
+	data := make([]uint32, len(b)/4)
+	for i := range data {
+		data[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return data
+}
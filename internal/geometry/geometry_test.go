@@ -1,6 +1,8 @@
 package geometry_test
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 
 	"github.com/everystreet/go-geojson/v2"
@@ -175,3 +177,123 @@ var SimpleProject = func(ll s2.LatLng) r2.Point {
 var SimpleUnproject = func(p r2.Point) s2.LatLng {
 	return s2.LatLngFromDegrees(p.Y+10, p.X+10)
 }
+
+func TestPolygonTooFewPoints(t *testing.T) {
+	var polygon geojson.Geometry
+	err := geometry.Unmarshal([]uint32{9, 0, 0, 10, 2, 2, 15}, spec.Tile_POLYGON, SimpleUnproject, &polygon)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "at least 3 points")
+}
+
+func TestEncoder(t *testing.T) {
+	line := geojson.NewLineString(
+		geojson.MakePosition(34, 12),
+		geojson.MakePosition(78, 56),
+		geojson.MakePosition(12, 90)).Geometry
+	points := geojson.NewMultiPoint(
+		geojson.MakePosition(34, 12),
+		geojson.MakePosition(78, 56)).Geometry
+
+	e := geometry.Encoder{Project: SimpleProject}
+
+	var buf []uint32
+	for _, geo := range []geojson.Geometry{line, points, line} {
+		start := len(buf)
+
+		var err error
+		buf, err = e.Append(buf, geo)
+		require.NoError(t, err)
+
+		// Each geometry is encoded independently of the previous one.
+		expected, err := geometry.Marshal(geo, SimpleProject)
+		require.NoError(t, err)
+		require.Equal(t, expected, buf[start:])
+	}
+
+	t.Run("error", func(t *testing.T) {
+		far := geojson.NewLineString(
+			geojson.MakePosition(0, 0),
+			geojson.MakePosition(0, 1)).Geometry
+
+		e := geometry.Encoder{Project: func(ll s2.LatLng) r2.Point {
+			return r2.Point{X: ll.Lng.Degrees() * 1e10}
+		}}
+
+		data, err := e.Append(buf, far)
+		require.Error(t, err)
+		require.Equal(t, buf, data)
+	})
+
+	t.Run("allocs", func(t *testing.T) {
+		buf = buf[:0]
+		allocs := testing.AllocsPerRun(100, func() {
+			buf, _ = e.Append(buf[:0], line)
+		})
+		require.Zero(t, allocs)
+	})
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	line := make([]geojson.Position, 64)
+	for i := range line {
+		line[i] = geojson.MakePosition(float64(i%7), float64(i))
+	}
+
+	ring := make([]geojson.Position, 65)
+	for i := range ring[:64] {
+		angle := -2 * math.Pi * float64(i) / 64
+		ring[i] = geojson.MakePosition(math.Sin(angle), math.Cos(angle))
+	}
+	ring[64] = ring[0]
+
+	for _, tt := range []struct {
+		Name     string
+		Geometry geojson.Geometry
+	}{
+		{"point", geojson.NewPoint(34, 12).Geometry},
+		{"linestring", geojson.NewLineString(line[0], line[1], line[2:]...).Geometry},
+		{"multilinestring", geojson.NewMultiLineString(line, line, line).Geometry},
+		{"polygon", geojson.NewPolygon(ring).Geometry},
+	} {
+		b.Run(tt.Name, func(b *testing.B) {
+			e := geometry.Encoder{Project: SimpleProject}
+
+			var buf []uint32
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var err error
+				if buf, err = e.Append(buf[:0], tt.Geometry); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEncoderWinding(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	position := func() geojson.Position {
+		// Small integer coordinates make collinear and repeated points likely.
+		return geojson.MakePosition(float64(rnd.Intn(5)), float64(rnd.Intn(5)))
+	}
+
+	e := geometry.Encoder{Project: SimpleProject}
+	for i := 0; i < 5000; i++ {
+		polygon := make(geojson.Polygon, 1+rnd.Intn(2))
+		for j := range polygon {
+			ring := make([]geojson.Position, 3+rnd.Intn(5))
+			for k := range ring {
+				ring[k] = position()
+			}
+			polygon[j] = append(ring, ring[0])
+		}
+
+		// The encoder checks winding without the allocations of the library check, but must agree with it.
+		_, err := e.Append(nil, &polygon)
+		if expected := polygon.Validate(); expected != nil {
+			require.EqualError(t, err, expected.Error())
+		} else if err != nil {
+			require.NotContains(t, err.Error(), "clockwise")
+		}
+	}
+}
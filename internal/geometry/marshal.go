@@ -2,6 +2,7 @@ package geometry
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/everystreet/go-geojson/v2"
 	"github.com/golang/geo/r2"
@@ -13,127 +14,125 @@ type Project func(s2.LatLng) r2.Point
 
 // Marshal returns the encoded sequence of a GeoJSON geometry.
 func Marshal(v geojson.Geometry, project Project) ([]uint32, error) {
-	if err := v.Validate(); err != nil {
-		return nil, err
+	if raw, ok := v.(*RawShape); ok {
+		return *raw, nil
 	}
 
-	switch v := v.(type) {
-	case *RawShape:
-		return marshalRawShape(*v)
-	case *geojson.Point:
-		return marshalPoint(*v, project)
-	case *geojson.MultiPoint:
-		return marshalMultiPoint(*v, project)
-	case *geojson.LineString:
-		return marshalLineString(*v, project)
-	case *geojson.MultiLineString:
-		return marshalMultiLineString(*v, project)
-	case *geojson.Polygon:
-		return marshalPolygon(*v, project)
-	case *geojson.MultiPolygon:
-		return marshalMultiPolygon(*v, project)
-	default:
-		return nil, fmt.Errorf("unknown type '%t'", v)
-	}
+	e := Encoder{Project: project}
+	return e.Append(nil, v)
 }
 
-func marshalRawShape(v RawShape) ([]uint32, error) {
-	return v, nil
+// Encoder encodes geometries into a caller-owned buffer.
+// The zero value is ready to use once Project is set, and an Encoder may be reused for any number of geometries.
+type Encoder struct {
+	Project Project
+
+	// cursor is the position that the next point is encoded relative to.
+	cursor r2.Point
+	// points is scratch space for checking the winding of polygon rings.
+	points []s2.Point
 }
 
-func marshalPoint(v geojson.Point, project Project) ([]uint32, error) {
-	cmd, err := MakeCommandInteger(MoveTo, 1)
-	if err != nil {
-		return nil, err
+// Append appends the encoded sequence of a GeoJSON geometry to b.
+// If an error is returned, b is returned unchanged apart from its capacity.
+func (e *Encoder) Append(b []uint32, v geojson.Geometry) ([]uint32, error) {
+	if err := e.validate(v); err != nil {
+		return b, err
 	}
 
-	positions, err := marshalPositions(project, geojson.Position(v))
+	if _, ok := v.(*RawShape); !ok && e.Project == nil {
+		return b, fmt.Errorf("missing project function")
+	}
+
+	// The cursor is reset at the start of every geometry, but not between the parts of a multi geometry.
+	e.cursor = r2.Point{}
+
+	n := len(b)
+	data, err := e.append(b, v)
 	if err != nil {
-		return nil, err
+		return b[:n], err
 	}
-	return append([]uint32{uint32(cmd)}, positions...), nil
+	return data, nil
 }
 
-func marshalMultiPoint(v geojson.MultiPoint, project Project) ([]uint32, error) {
-	cmd, err := MakeCommandInteger(MoveTo, uint32(len(v)))
-	if err != nil {
-		return nil, err
+func (e *Encoder) append(b []uint32, v geojson.Geometry) ([]uint32, error) {
+	switch v := v.(type) {
+	case *RawShape:
+		return append(b, *v...), nil
+	case *geojson.Point:
+		return e.appendPoints(b, geojson.Position(*v))
+	case *geojson.MultiPoint:
+		return e.appendPoints(b, *v...)
+	case *geojson.LineString:
+		return e.appendLineString(b, *v)
+	case *geojson.MultiLineString:
+		for _, line := range *v {
+			var err error
+			if b, err = e.appendLineString(b, line); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case *geojson.Polygon:
+		return e.appendPolygon(b, *v)
+	case *geojson.MultiPolygon:
+		for i, polygon := range *v {
+			var err error
+			if b, err = e.appendPolygon(b, polygon); err != nil {
+				return nil, fmt.Errorf("failed to marshal polygon '%d': %w", i, err)
+			}
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unknown type '%T'", v)
 	}
+}
 
-	positions, err := marshalPositions(project, v...)
+func (e *Encoder) appendPoints(b []uint32, positions ...geojson.Position) ([]uint32, error) {
+	cmd, err := MakeCommandInteger(MoveTo, uint32(len(positions)))
 	if err != nil {
 		return nil, err
 	}
-	return append([]uint32{uint32(cmd)}, positions...), nil
+	return e.appendPositions(append(b, uint32(cmd)), positions)
 }
 
-func marshalLineString(v geojson.LineString, project Project) ([]uint32, error) {
+func (e *Encoder) appendLineString(b []uint32, v geojson.LineString) ([]uint32, error) {
 	if len(v) < 2 {
 		return nil, fmt.Errorf("linestring must consist of at least 2 points")
 	}
 
-	points := make([]r2.Point, len(v))
-	for i, p := range v {
-		points[i] = project(p.LatLng)
-	}
-
 	// MoveTo with command count == 1
 	cmd, err := MakeCommandInteger(MoveTo, 1)
 	if err != nil {
 		return nil, err
 	}
-	data := []uint32{uint32(cmd)}
 
 	// first point
-	ints, err := marshalInteger(points[0])
+	b, err = e.appendPositions(append(b, uint32(cmd)), v[:1])
 	if err != nil {
 		return nil, err
 	}
-	data = append(data, ints...)
 
 	// LineTo with command count == remaining points
-	cmd, err = MakeCommandInteger(LineTo, uint32(len(points)-1))
+	cmd, err = MakeCommandInteger(LineTo, uint32(len(v)-1))
 	if err != nil {
 		return nil, err
 	}
-	data = append(data, uint32(cmd))
 
 	// remaining points
-	for i := 1; i < len(points); i++ {
-		// points are relative to the previous point
-		prev := points[i-1]
-		ints, err := marshalInteger(r2.Point{
-			X: points[i].X - prev.X,
-			Y: points[i].Y - prev.Y,
-		})
-
-		if err != nil {
-			return nil, err
-		}
-		data = append(data, ints...)
-	}
-
-	return data, nil
-}
-
-func marshalMultiLineString(v geojson.MultiLineString, project Project) ([]uint32, error) {
-	var linestrings []uint32
-	for _, line := range v {
-		data, err := marshalLineString(line, project)
-		if err != nil {
-			return nil, err
-		}
-		linestrings = append(linestrings, data...)
-	}
-	return linestrings, nil
+	return e.appendPositions(append(b, uint32(cmd)), v[1:])
 }
 
-func marshalPolygon(v geojson.Polygon, project Project) ([]uint32, error) {
+func (e *Encoder) appendPolygon(b []uint32, v geojson.Polygon) ([]uint32, error) {
 	if len(v) < 1 {
 		return nil, fmt.Errorf("polygon must consist of at least an exterior ring")
 	}
 
-	var data []uint32
+	closePath, err := MakeCommandInteger(ClosePath, 1)
+	if err != nil {
+		return nil, err
+	}
+
 	for i, loop := range v {
 		// The first and last points of a GeoJSON polygon loop are the same,
 		// but vector tiles implicitly connect the first and last points.
@@ -143,61 +142,46 @@ func marshalPolygon(v geojson.Polygon, project Project) ([]uint32, error) {
 		}
 
 		// A polygon loop is a linestring with a trailing ClosePath command.
-		linestring, err := marshalLineString(loop[:len(loop)-1], project)
-		if err != nil {
+		if b, err = e.appendLineString(b, loop[:len(loop)-1]); err != nil {
 			return nil, fmt.Errorf("failed to marshal loop '%d': %w", i, err)
 		}
-		data = append(data, linestring...)
-
-		cmd, err := MakeCommandInteger(ClosePath, 1)
-		if err != nil {
-			return nil, err
-		}
-		data = append(data, uint32(cmd))
-	}
-	return data, nil
-}
-
-func marshalMultiPolygon(v geojson.MultiPolygon, project Project) ([]uint32, error) {
-	var data []uint32
-	for i, polygon := range v {
-		p, err := marshalPolygon(polygon, project)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal polygon '%d': %w", i, err)
-		}
-		data = append(data, p...)
+		b = append(b, uint32(closePath))
 	}
-	return data, nil
+	return b, nil
 }
 
-func marshalPositions(project Project, positions ...geojson.Position) ([]uint32, error) {
-	var data []uint32
+// appendPositions encodes each position relative to the previous one, starting from the cursor.
+// The cursor is left at the last position.
+func (e *Encoder) appendPositions(b []uint32, positions []geojson.Position) ([]uint32, error) {
 	for _, pos := range positions {
-		point := project(pos.LatLng)
-
-		integers, err := marshalInteger(point)
+		x, y, err := e.move(e.Project(pos.LatLng))
 		if err != nil {
 			return nil, err
 		}
-		data = append(data, integers...)
+		b = append(b, uint32(x), uint32(y))
 	}
-	return data, nil
+	return b, nil
 }
 
-func marshalInteger(point r2.Point) ([]uint32, error) {
-	data := make([]uint32, 2)
+// move returns the parameters of the point, truncated to integer coordinates, relative to the cursor.
+// The cursor is moved to the truncated point.
+func (e *Encoder) move(point r2.Point) (x, y ParameterInteger, err error) {
+	point = r2.Point{
+		X: math.Trunc(point.X),
+		Y: math.Trunc(point.Y),
+	}
 
-	v, err := MakeParameterInteger(int32(point.X))
-	if err != nil {
-		return nil, err
+	dx, dy := point.X-e.cursor.X, point.Y-e.cursor.Y
+	if !(math.Abs(dx) <= math.MaxInt32 && math.Abs(dy) <= math.MaxInt32) {
+		return 0, 0, fmt.Errorf("point (%g, %g) is too far from previous point", point.X, point.Y)
 	}
-	data[0] = uint32(v)
 
-	v, err = MakeParameterInteger(int32(point.Y))
-	if err != nil {
-		return nil, err
+	if x, err = MakeParameterInteger(int32(dx)); err != nil {
+		return 0, 0, err
+	} else if y, err = MakeParameterInteger(int32(dy)); err != nil {
+		return 0, 0, err
 	}
-	data[1] = uint32(v)
 
-	return data, nil
+	e.cursor = point
+	return x, y, nil
 }
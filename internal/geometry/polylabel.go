@@ -0,0 +1,165 @@
+package geometry
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// PoleOfInaccessibility returns the point inside the implicitly closed rings that is farthest from their edges,
+// and its distance from them, in the manner of polylabel. A point is inside the rings by the even-odd rule,
+// so the rings may be the exterior rings and holes of several polygons.
+// The result is within precision of the optimum. If the rings enclose no area, the first point is returned.
+func PoleOfInaccessibility(rings [][]r2.Point, precision float64) (r2.Point, float64) {
+	var first r2.Point
+	bounds := r2.EmptyRect()
+	for _, ring := range rings {
+		for _, p := range ring {
+			if bounds.IsEmpty() {
+				first = p
+			}
+			bounds = bounds.AddPoint(p)
+		}
+	}
+
+	size := bounds.Size()
+	cellSize := math.Min(size.X, size.Y)
+	if cellSize == 0 || math.IsNaN(cellSize) {
+		return first, 0
+	}
+	if precision <= 0 {
+		precision = cellSize / 1000
+	}
+
+	// The initial best guess is the centroid, or the centre of the bounds if it is better.
+	best := newLabelCell(centroid(rings), 0, rings)
+	if c := newLabelCell(bounds.Center(), 0, rings); c.d > best.d {
+		best = c
+	}
+
+	h := cellSize / 2
+	var cells labelCells
+	for x := bounds.X.Lo; x < bounds.X.Hi; x += cellSize {
+		for y := bounds.Y.Lo; y < bounds.Y.Hi; y += cellSize {
+			cells = append(cells, newLabelCell(r2.Point{X: x + h, Y: y + h}, h, rings))
+		}
+	}
+	heap.Init(&cells)
+
+	for cells.Len() > 0 {
+		c := heap.Pop(&cells).(labelCell)
+		if c.d > best.d {
+			best = c
+		}
+
+		// Cells that cannot contain a better point by more than the precision are not split.
+		if c.max-best.d <= precision {
+			continue
+		}
+
+		h := c.h / 2
+		for _, d := range []r2.Point{{X: -h, Y: -h}, {X: h, Y: -h}, {X: -h, Y: h}, {X: h, Y: h}} {
+			heap.Push(&cells, newLabelCell(c.p.Add(d), h, rings))
+		}
+	}
+	return best.p, best.d
+}
+
+// labelCell is a square cell searched for the pole of inaccessibility.
+type labelCell struct {
+	p r2.Point
+	// h is half the size of the cell.
+	h float64
+	// d is the signed distance from the centre of the cell to the rings, which is negative outside them.
+	d float64
+	// max is the greatest distance of any point in the cell.
+	max float64
+}
+
+func newLabelCell(p r2.Point, h float64, rings [][]r2.Point) labelCell {
+	d := ringsDistance(p, rings)
+	return labelCell{
+		p:   p,
+		h:   h,
+		d:   d,
+		max: d + h*math.Sqrt2,
+	}
+}
+
+// labelCells is a max-heap of cells by the greatest distance they could contain.
+type labelCells []labelCell
+
+func (c labelCells) Len() int            { return len(c) }
+func (c labelCells) Less(i, j int) bool  { return c[i].max > c[j].max }
+func (c labelCells) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *labelCells) Push(v interface{}) { *c = append(*c, v.(labelCell)) }
+
+func (c *labelCells) Pop() interface{} {
+	old := *c
+	v := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return v
+}
+
+// ringsDistance returns the distance from the point to the nearest edge of the rings,
+// which is negative if the point is outside them.
+func ringsDistance(p r2.Point, rings [][]r2.Point) float64 {
+	inside := false
+	minSq := math.Inf(1)
+
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+				inside = !inside
+			}
+			minSq = math.Min(minSq, segmentDistanceSq(p, a, b))
+		}
+	}
+
+	d := math.Sqrt(minSq)
+	if !inside {
+		return -d
+	}
+	return d
+}
+
+// segmentDistanceSq returns the squared distance from p to the segment a-b.
+func segmentDistanceSq(p, a, b r2.Point) float64 {
+	d := b.Sub(a)
+	if d.X != 0 || d.Y != 0 {
+		t := p.Sub(a).Dot(d) / d.Dot(d)
+		if t > 1 {
+			a = b
+		} else if t > 0 {
+			a = a.Add(d.Mul(t))
+		}
+	}
+	v := p.Sub(a)
+	return v.Dot(v)
+}
+
+// centroid returns the area-weighted centroid of the rings, or the first point if they have no area.
+func centroid(rings [][]r2.Point) r2.Point {
+	var c r2.Point
+	var area float64
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			f := a.X*b.Y - b.X*a.Y
+			c = c.Add(a.Add(b).Mul(f))
+			area += f * 3
+		}
+	}
+
+	if area == 0 {
+		for _, ring := range rings {
+			if len(ring) > 0 {
+				return ring[0]
+			}
+		}
+		return r2.Point{}
+	}
+	return c.Mul(1 / area)
+}
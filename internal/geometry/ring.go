@@ -0,0 +1,69 @@
+package geometry
+
+import "github.com/golang/geo/r2"
+
+// Area returns the signed area of the ring, calculated by the surveyor's formula.
+// The ring is implicitly closed. In tile coordinates, where the Y axis points down,
+// a positive area means the ring is clockwise, which is the winding of an exterior ring.
+func Area(ring []r2.Point) float64 {
+	var sum float64
+	for i := range ring {
+		p, q := ring[i], ring[(i+1)%len(ring)]
+		sum += p.X*q.Y - q.X*p.Y
+	}
+	return sum / 2
+}
+
+// SelfIntersects returns true if any two non-adjacent edges of the implicitly closed ring
+// intersect or touch, or if any vertex is repeated.
+func SelfIntersects(ring []r2.Point) bool {
+	n := len(ring)
+	for i := 0; i < n; i++ {
+		a1, a2 := ring[i], ring[(i+1)%n]
+		if a1 == a2 {
+			return true
+		}
+
+		for j := i + 1; j < n; j++ {
+			// adjacent edges share a vertex
+			if j == i+1 || (i == 0 && j == n-1) {
+				continue
+			}
+
+			b1, b2 := ring[j], ring[(j+1)%n]
+			if SegmentsIntersect(a1, a2, b1, b2) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SegmentsIntersect returns true if segment p1-p2 intersects or touches segment q1-q2.
+func SegmentsIntersect(p1, p2, q1, q2 r2.Point) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+
+	return (d1 == 0 && onSegment(q1, q2, p1)) ||
+		(d2 == 0 && onSegment(q1, q2, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, q1)) ||
+		(d4 == 0 && onSegment(p1, p2, q2))
+}
+
+// orientation returns the cross product of a->b and a->c.
+// It is positive if c is to one side of a->b, negative if on the other, and zero if collinear.
+func orientation(a, b, c r2.Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// onSegment returns true if p, which is collinear with a-b, lies on the segment a-b.
+func onSegment(a, b, p r2.Point) bool {
+	return p.X >= min(a.X, b.X) && p.X <= max(a.X, b.X) &&
+		p.Y >= min(a.Y, b.Y) && p.Y <= max(a.Y, b.Y)
+}
@@ -44,6 +44,10 @@ func indirect(v interface{}) (*reflect.Value, error) {
 }
 
 func unmarshal(data []uint32, typ spec.Tile_GeomType, unproject Unproject) (geojson.Geometry, error) {
+	if typ != spec.Tile_UNKNOWN && unproject == nil {
+		return nil, fmt.Errorf("missing unproject function")
+	}
+
 	switch typ {
 	case spec.Tile_UNKNOWN:
 		return (*RawShape)(&data), nil
@@ -61,42 +65,47 @@ func unmarshal(data []uint32, typ spec.Tile_GeomType, unproject Unproject) (geoj
 func unmarshalPoints(data []uint32, unproject Unproject) (geojson.Geometry, error) {
 	n := len(data)
 	if n == 0 {
-		return nil, fmt.Errorf("data len must be >= 1")
+		return nil, errorAt(0, SectionPoint, "data len must be >= 1")
 	}
 
-	cmd := CommandInteger(data[0])
-	if err := cmd.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid command: %w", err)
-	} else if id := cmd.ID(); id != MoveTo {
-		return nil, fmt.Errorf("expecting MoveTo command, received '%v'", id)
+	cmd, err := unmarshalCommand(data[0], MoveTo)
+	if err != nil {
+		return nil, withOffset(0, SectionPoint, err)
 	}
 
+	// points are relative to the previous point
+	var cursor r2.Point
+
 	count := cmd.Count()
 	switch {
 	case count == 1 && n == 3:
-		p, err := unmarshalPosition(data[1:], unproject)
+		p, err := unmarshalPosition(data[1:], unproject, &cursor)
 		if err != nil {
-			return nil, err
+			return nil, withOffset(1, SectionParameters, err)
 		}
 		return (*geojson.Point)(p), nil
 	case count > 1 && n == 1+int(count)*2:
-		p, err := unmarshalPositions(data[1:], unproject)
+		p, err := unmarshalPositions(data[1:], unproject, &cursor)
 		if err != nil {
-			return nil, err
+			return nil, withOffset(1, SectionParameters, err)
 		}
 		return (*geojson.MultiPoint)(&p), nil
 	default:
-		return nil, fmt.Errorf("MoveTo must be followed by at least one pair of ParameterIntegers: %d, %d", count, n)
+		return nil, errorAt(0, SectionPoint,
+			"MoveTo must be followed by at least one pair of ParameterIntegers: %d, %d", count, n)
 	}
 }
 
 func unmarshalLinestrings(data []uint32, unproject Unproject) (geojson.Geometry, error) {
 	var linestrings geojson.MultiLineString
 
-	for len(data) != 0 {
-		ls, err := unmarshalLineString(&data, unproject)
+	// The cursor is not reset between linestrings.
+	var cursor r2.Point
+	for n := len(data); len(data) != 0; {
+		offset := n - len(data)
+		ls, err := unmarshalLineString(&data, unproject, &cursor)
 		if err != nil {
-			return nil, err
+			return nil, withOffset(offset, SectionLineString, err)
 		}
 		linestrings = append(linestrings, *ls)
 	}
@@ -110,21 +119,27 @@ func unmarshalLinestrings(data []uint32, unproject Unproject) (geojson.Geometry,
 func unmarshalPolygons(data []uint32, unproject Unproject) (geojson.Geometry, error) {
 	var polygons geojson.MultiPolygon
 
-	for len(data) != 0 {
+	// The cursor is not reset between rings.
+	var cursor r2.Point
+	for n := len(data); len(data) != 0; {
+		offset := n - len(data)
+
 		// A polygon loop is a linestring with a trailing ClosePath command.
-		loop, err := unmarshalLineString(&data, unproject)
+		loop, err := unmarshalLineString(&data, unproject, &cursor)
 		if err != nil {
-			return nil, err
+			return nil, withOffset(offset, SectionPolygon, err)
 		}
 
-		if len(data) < 1 {
-			return nil, fmt.Errorf("unexpected end")
+		if len(*loop) < 3 {
+			return nil, errorAt(offset, SectionPolygon, "loop must consist of at least 3 points, have %d", len(*loop))
+		} else if len(data) < 1 {
+			return nil, errorAt(n, SectionPolygon, "unexpected end")
 		}
 
 		// Consume the ClosePath.
 		_, err = unmarshalCommand(data[0], ClosePath)
 		if err != nil {
-			return nil, err
+			return nil, withOffset(n-len(data), SectionPolygon, err)
 		}
 		data = data[1:]
 
@@ -139,7 +154,7 @@ func unmarshalPolygons(data []uint32, unproject Unproject) (geojson.Geometry, er
 			polygons = append(polygons, geojson.Polygon{*loop})
 		} else if angle >= 0 { // CCW interior
 			if len(polygons) == 0 {
-				return nil, fmt.Errorf("missing exterior loop (%d)", len(*loop))
+				return nil, errorAt(offset, SectionPolygon, "missing exterior loop (%d)", len(*loop))
 			}
 			polygon := &polygons[len(polygons)-1]
 			*polygon = append(*polygon, *loop)
@@ -152,50 +167,52 @@ func unmarshalPolygons(data []uint32, unproject Unproject) (geojson.Geometry, er
 	return (*geojson.MultiPolygon)(&polygons), nil
 }
 
-func unmarshalLineString(data *[]uint32, unproject Unproject) (*geojson.LineString, error) {
+// unmarshalLineString decodes a linestring relative to the cursor,
+// which is left at the last point of the linestring.
+func unmarshalLineString(data *[]uint32, unproject Unproject, cursor *r2.Point) (*geojson.LineString, error) {
 	if n := len(*data); n < 4 {
-		return nil, fmt.Errorf("data len must be >= 4, have %d", n)
+		return nil, errorAt(0, SectionLineString, "data len must be >= 4, have %d", n)
 	}
 
 	// MoveTo with command count == 1
 	cmd, err := unmarshalCommand((*data)[0], MoveTo)
 	if err != nil {
-		return nil, err
+		return nil, withOffset(0, SectionCommands, err)
 	} else if n := cmd.Count(); n != 1 {
-		return nil, fmt.Errorf("expecting command count of 1, received '%d'", n)
+		return nil, errorAt(0, SectionLineString, "expecting command count of 1, received '%d'", n)
 	}
 
 	// single pair for integers forms first coordinate
 	x, y, err := unmarshalIntegers((*data)[1:3])
 	if err != nil {
-		return nil, err
+		return nil, withOffset(1, SectionParameters, err)
 	}
 
 	// LineTo with command count >= 1
 	cmd, err = unmarshalCommand((*data)[3], LineTo)
 	if err != nil {
-		return nil, err
+		return nil, withOffset(3, SectionCommands, err)
 	} else if n := cmd.Count(); n < 1 {
-		return nil, fmt.Errorf("expecting command count >= 1, received '%d'", n)
+		return nil, errorAt(3, SectionLineString, "expecting command count >= 1, received '%d'", n)
 	}
 
 	// length of data for linestring
 	lineDataLen := 4 + (2 * int(cmd.Count()))
 	if n := len(*data); n < lineDataLen {
-		return nil, fmt.Errorf("data len must be >= %d, have %d", lineDataLen, n)
+		return nil, errorAt(3, SectionLineString, "data len must be >= %d, have %d", lineDataLen, n)
 	}
 
 	points := make([]r2.Point, cmd.Count()+1)
 	points[0] = r2.Point{
-		X: float64(x.Value()),
-		Y: float64(y.Value()),
+		X: cursor.X + float64(x.Value()),
+		Y: cursor.Y + float64(y.Value()),
 	}
 
 	// remaining coordinates make up the rest of the line
 	for i := uint32(0); i < cmd.Count(); i++ {
 		x, y, err := unmarshalIntegers((*data)[4+2*i : 6+2*i])
 		if err != nil {
-			return nil, err
+			return nil, withOffset(4+2*int(i), SectionParameters, err)
 		}
 
 		// each coordinate is relative to the previous
@@ -211,20 +228,23 @@ func unmarshalLineString(data *[]uint32, unproject Unproject) (*geojson.LineStri
 		}
 	}
 
+	*cursor = points[len(points)-1]
 	*data = (*data)[lineDataLen:]
 	return &linestring, nil
 }
 
-func unmarshalPositions(data []uint32, unproject Unproject) ([]geojson.Position, error) {
+// unmarshalPositions decodes each position relative to the previous one, starting from the cursor.
+// The cursor is left at the last position.
+func unmarshalPositions(data []uint32, unproject Unproject, cursor *r2.Point) ([]geojson.Position, error) {
 	if n := len(data); n%2 != 0 {
 		return nil, fmt.Errorf("expecting even number of integers, have %d", n)
 	}
 
 	positions := make([]geojson.Position, len(data)/2)
 	for i := 0; i < len(positions); i++ {
-		pos, err := unmarshalPosition(data[i*2:i*2+2], unproject)
+		pos, err := unmarshalPosition(data[i*2:i*2+2], unproject, cursor)
 		if err != nil {
-			return nil, err
+			return nil, withOffset(i*2, SectionParameters, err)
 		}
 		positions[i] = *pos
 	}
@@ -248,7 +268,8 @@ func unmarshalIntegers(data []uint32) (x, y ParameterInteger, err error) {
 	return
 }
 
-func unmarshalPosition(data []uint32, unproject Unproject) (*geojson.Position, error) {
+// unmarshalPosition decodes a position relative to the cursor, and moves the cursor to it.
+func unmarshalPosition(data []uint32, unproject Unproject, cursor *r2.Point) (*geojson.Position, error) {
 	if n := len(data); n != 2 {
 		return nil, fmt.Errorf("expecting 2 integers, have %d", n)
 	}
@@ -263,11 +284,10 @@ func unmarshalPosition(data []uint32, unproject Unproject) (*geojson.Position, e
 		return nil, err
 	}
 
+	cursor.X += float64(x.Value())
+	cursor.Y += float64(y.Value())
 	return &geojson.Position{
-		LatLng: unproject(r2.Point{
-			X: float64(x.Value()),
-			Y: float64(y.Value()),
-		}),
+		LatLng: unproject(*cursor),
 	}, nil
 }
 
@@ -0,0 +1,85 @@
+package geometry
+
+import (
+	"github.com/everystreet/go-geojson/v2"
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+// validate is equivalent to v.Validate, but checks the winding of polygon rings without allocating.
+// The library check builds an s2.Loop for every ring, so it is only used to report an invalid polygon.
+func (e *Encoder) validate(v geojson.Geometry) error {
+	switch v := v.(type) {
+	case *geojson.Polygon:
+		if e.validPolygon(*v) {
+			return nil
+		}
+	case *geojson.MultiPolygon:
+		valid := true
+		for _, polygon := range *v {
+			valid = valid && e.validPolygon(polygon)
+		}
+		if valid {
+			return nil
+		}
+	}
+	return v.Validate()
+}
+
+// validPolygon returns true if geojson.Polygon.Validate would return nil.
+func (e *Encoder) validPolygon(v geojson.Polygon) bool {
+	for i, ring := range v {
+		if len(ring) < 4 || ring[len(ring)-1] != ring[0] {
+			return false
+		}
+
+		if angle := e.turningAngle(ring); i == 0 && angle >= 0 {
+			return false
+		} else if i > 0 && angle <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// turningAngle returns the same result as geojson.LoopToS2(ring).TurningAngle(),
+// reusing the encoder's point buffer. The ring must have at least 4 positions.
+//
+// It is a copy of s2.Loop.TurningAngle and s2.Loop.CanonicalFirstVertex from
+// github.com/golang/geo v0.0.0-20190916061304-5b978397cfec, as building an s2.Loop allocates its vertices and index,
+// and doubles the time taken to encode a polygon. TestEncoderWinding checks that it agrees with the library,
+// so it must be checked against the library again when that dependency is updated.
+func (e *Encoder) turningAngle(ring []geojson.Position) float64 {
+	n := len(ring) - 1
+	e.points = e.points[:0]
+	for _, pos := range ring[:n] {
+		e.points = append(e.points, s2.PointFromLatLng(pos.LatLng))
+	}
+
+	vertex := func(i int) s2.Point {
+		return e.points[i%n]
+	}
+
+	// Sum the turn angles from the canonical first vertex with Kahan summation, as s2.Loop does.
+	i, dir := 0, 1
+	for j := 1; j < n; j++ {
+		if vertex(j).Cmp(vertex(i).Vector) == -1 {
+			i = j
+		}
+	}
+	if vertex(i+1).Cmp(vertex(i+n-1).Vector) != -1 {
+		i, dir = i+n, -1
+	}
+
+	sum := s2.TurnAngle(vertex((i+n-dir)%n), vertex(i), vertex((i+dir)%n))
+	var compensation s1.Angle
+	for k := n - 1; k > 0; k-- {
+		i += dir
+		angle := s2.TurnAngle(vertex(i-dir), vertex(i), vertex(i+dir))
+		oldSum := sum
+		angle += compensation
+		sum += angle
+		compensation = (oldSum - sum) + angle
+	}
+	return float64(dir) * float64(sum+compensation)
+}
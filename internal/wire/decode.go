@@ -0,0 +1,390 @@
+package wire
+
+import (
+	"fmt"
+	"math"
+
+	spec "github.com/everystreet/go-mvt/internal/spec"
+)
+
+// Layer is a decoded layer message.
+// A Layer is reused by DecodeLayer, which keeps its slices for the next layer.
+type Layer struct {
+	Name    string
+	Version uint32
+	Extent  uint32
+
+	// HasName, HasVersion and HasExtent are true if the corresponding field is present.
+	// Missing fields take the schema default.
+	HasName    bool
+	HasVersion bool
+	HasExtent  bool
+
+	Keys     []string
+	Values   []Value
+	Features []Feature
+
+	// ints backs the tags and geometry of every feature in the layer,
+	// and spans holds the range of each, two per feature.
+	ints  []uint32
+	spans []span
+}
+
+// Feature is a decoded feature message.
+// Tags and Geometry are only valid until the layer is decoded into again.
+type Feature struct {
+	ID       uint64
+	HasID    bool
+	Type     spec.Tile_GeomType
+	HasType  bool
+	Tags     []uint32
+	Geometry []uint32
+}
+
+// Value is a decoded value message.
+type Value struct {
+	// Fields has bit n set if field n is present.
+	Fields uint8
+
+	String string
+	Float  float32
+	Double float64
+	Int    int64
+	Uint   uint64
+	Sint   int64
+	Bool   bool
+}
+
+// Has returns true if field number n is present.
+func (v Value) Has(n int) bool {
+	return v.Fields&(1<<n) != 0
+}
+
+// Value field numbers, for use with Has.
+const (
+	StringField = valueString
+	FloatField  = valueFloat
+	DoubleField = valueDouble
+	IntField    = valueInt
+	UintField   = valueUint
+	SintField   = valueSint
+	BoolField   = valueBool
+)
+
+// Layers calls fn with the encoded message of each layer in the tile.
+// Unknown fields are skipped.
+func Layers(data []byte, fn func(layer []byte) error) error {
+	r := reader{data: data}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return err
+		}
+
+		if field != tileLayers {
+			if err := r.skip(wireType); err != nil {
+				return err
+			}
+			continue
+		}
+
+		layer, err := lengthDelimited(&r, field, wireType)
+		if err != nil {
+			return err
+		} else if err := fn(layer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountLayers returns the number of layers in the tile.
+func CountLayers(data []byte) (int, error) {
+	var n int
+	err := Layers(data, func([]byte) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// Limits bounds the number of features, keys and values that DecodeLayerLimits decodes in a layer.
+// A zero value for any field means that field is not limited.
+type Limits struct {
+	Features int
+	Keys     int
+	Values   int
+}
+
+// LimitError is returned by DecodeLayerLimits when a layer has more features, keys or values than its limit.
+type LimitError struct {
+	// Field is FeaturesField, KeysField or ValuesField.
+	Field int
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("more than %d of field %d", e.Max, e.Field)
+}
+
+// Layer field numbers, for use with LimitError.
+const (
+	FeaturesField = layerFeatures
+	KeysField     = layerKeys
+	ValuesField   = layerValues
+)
+
+// DecodeLayer decodes the layer message in data into l.
+// Strings are copied, so data may be reused once DecodeLayer returns.
+func DecodeLayer(data []byte, l *Layer) error {
+	return DecodeLayerLimits(data, l, Limits{})
+}
+
+// DecodeLayerLimits decodes the layer message in data into l, as DecodeLayer does.
+// Decoding stops with a LimitError as soon as the layer has more features, keys or values than lim allows,
+// before they are decoded.
+func DecodeLayerLimits(data []byte, l *Layer, lim Limits) error {
+	*l = Layer{
+		Version:  1,
+		Extent:   4096,
+		Keys:     l.Keys[:0],
+		Values:   l.Values[:0],
+		Features: l.Features[:0],
+		ints:     l.ints[:0],
+		spans:    l.spans[:0],
+	}
+
+	r := reader{data: data}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case layerName:
+			var b []byte
+			if b, err = lengthDelimited(&r, field, wireType); err == nil {
+				l.Name = string(b)
+				l.HasName = true
+			}
+		case layerFeatures:
+			var b []byte
+			if lim.Features > 0 && len(l.Features) >= lim.Features {
+				err = &LimitError{Field: field, Max: lim.Features}
+			} else if b, err = lengthDelimited(&r, field, wireType); err == nil {
+				err = l.decodeFeature(b)
+			}
+		case layerKeys:
+			var b []byte
+			if lim.Keys > 0 && len(l.Keys) >= lim.Keys {
+				err = &LimitError{Field: field, Max: lim.Keys}
+			} else if b, err = lengthDelimited(&r, field, wireType); err == nil {
+				l.Keys = append(l.Keys, string(b))
+			}
+		case layerValues:
+			var b []byte
+			if lim.Values > 0 && len(l.Values) >= lim.Values {
+				err = &LimitError{Field: field, Max: lim.Values}
+			} else if b, err = lengthDelimited(&r, field, wireType); err == nil {
+				var v Value
+				if err = decodeValue(b, &v); err == nil {
+					l.Values = append(l.Values, v)
+				}
+			}
+		case layerExtent:
+			var v uint64
+			if v, err = varint(&r, field, wireType); err == nil {
+				l.Extent = uint32(v)
+				l.HasExtent = true
+			}
+		case layerVersion:
+			var v uint64
+			if v, err = varint(&r, field, wireType); err == nil {
+				l.Version = uint32(v)
+				l.HasVersion = true
+			}
+		default:
+			err = r.skip(wireType)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	l.sliceInts()
+	return nil
+}
+
+// decodeFeature appends the feature message in data to the layer.
+// Tags and geometry are appended to the shared ints slice.
+func (l *Layer) decodeFeature(data []byte) error {
+	var f Feature
+
+	tagsStart := len(l.ints)
+	if err := l.decodeInts(data, featureTags); err != nil {
+		return err
+	}
+
+	geomStart := len(l.ints)
+	if err := l.decodeInts(data, featureGeometry); err != nil {
+		return err
+	}
+
+	r := reader{data: data}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case featureID:
+			var v uint64
+			if v, err = varint(&r, field, wireType); err == nil {
+				f.ID = v
+				f.HasID = true
+			}
+		case featureType:
+			var v uint64
+			if v, err = varint(&r, field, wireType); err == nil {
+				f.Type = spec.Tile_GeomType(int32(v))
+				f.HasType = true
+			}
+		default:
+			err = r.skip(wireType)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// The shared slice may grow, so it is only sliced once every feature has been decoded.
+	l.spans = append(l.spans, span{tagsStart, geomStart}, span{geomStart, len(l.ints)})
+	l.Features = append(l.Features, f)
+	return nil
+}
+
+// decodeInts appends every value of the repeated uint32 field to the shared ints slice.
+func (l *Layer) decodeInts(data []byte, want int) error {
+	r := reader{data: data}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return err
+		}
+
+		if field != want {
+			err = r.skip(wireType)
+		} else {
+			l.ints, err = r.uint32s(wireType, l.ints)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// span is a range of the shared ints slice.
+type span struct {
+	start, end int
+}
+
+// sliceInts points the tags and geometry of each feature into the shared ints slice.
+func (l *Layer) sliceInts() {
+	for i := range l.Features {
+		tags, geom := l.spans[i*2], l.spans[i*2+1]
+		l.Features[i].Tags = l.slice(tags)
+		l.Features[i].Geometry = l.slice(geom)
+	}
+}
+
+func (l *Layer) slice(s span) []uint32 {
+	if s.start == s.end {
+		return nil
+	}
+	return l.ints[s.start:s.end:s.end]
+}
+
+func decodeValue(data []byte, v *Value) error {
+	r := reader{data: data}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case valueString:
+			var b []byte
+			if b, err = lengthDelimited(&r, field, wireType); err == nil {
+				v.String = string(b)
+			}
+		case valueFloat:
+			err = expect(field, wireType, fixed32Type)
+			if err == nil {
+				var n uint32
+				if n, err = r.fixed32(); err == nil {
+					v.Float = math.Float32frombits(n)
+				}
+			}
+		case valueDouble:
+			err = expect(field, wireType, fixed64Type)
+			if err == nil {
+				var n uint64
+				if n, err = r.fixed64(); err == nil {
+					v.Double = math.Float64frombits(n)
+				}
+			}
+		case valueInt:
+			var n uint64
+			if n, err = varint(&r, field, wireType); err == nil {
+				v.Int = int64(n)
+			}
+		case valueUint:
+			v.Uint, err = varint(&r, field, wireType)
+		case valueSint:
+			var n uint64
+			if n, err = varint(&r, field, wireType); err == nil {
+				v.Sint = int64(n>>1) ^ -int64(n&1)
+			}
+		case valueBool:
+			var n uint64
+			if n, err = varint(&r, field, wireType); err == nil {
+				v.Bool = n != 0
+			}
+		default:
+			err = r.skip(wireType)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+		v.Fields |= 1 << field
+	}
+	return nil
+}
+
+// varint reads the value of a varint field.
+func varint(r *reader, field, wireType int) (uint64, error) {
+	if err := expect(field, wireType, varintType); err != nil {
+		return 0, err
+	}
+	return r.varint()
+}
+
+// lengthDelimited reads the value of a length-delimited field.
+func lengthDelimited(r *reader, field, wireType int) ([]byte, error) {
+	if err := expect(field, wireType, bytesType); err != nil {
+		return nil, err
+	}
+	return r.bytes()
+}
@@ -0,0 +1,182 @@
+package wire
+
+import (
+	"math"
+	"slices"
+)
+
+// AppendLayer appends the layer, as a field of a tile message, to b.
+// Fields are written in field number order, and fields that aren't present are omitted.
+func AppendLayer(b []byte, l *Layer) []byte {
+	n := l.size()
+	b = slices.Grow(b, sizeBytes(tileLayers, n))
+	b = appendKey(b, tileLayers, bytesType)
+	b = appendVarint(b, uint64(n))
+
+	if l.HasName {
+		b = appendString(b, layerName, l.Name)
+	}
+
+	for i := range l.Features {
+		f := &l.Features[i]
+		b = appendKey(b, layerFeatures, bytesType)
+		b = appendVarint(b, uint64(f.size()))
+		b = f.append(b)
+	}
+
+	for _, key := range l.Keys {
+		b = appendString(b, layerKeys, key)
+	}
+
+	for i := range l.Values {
+		v := &l.Values[i]
+		b = appendKey(b, layerValues, bytesType)
+		b = appendVarint(b, uint64(v.size()))
+		b = v.append(b)
+	}
+
+	if l.HasExtent {
+		b = appendKey(b, layerExtent, varintType)
+		b = appendVarint(b, uint64(l.Extent))
+	}
+
+	if l.HasVersion {
+		b = appendKey(b, layerVersion, varintType)
+		b = appendVarint(b, uint64(l.Version))
+	}
+	return b
+}
+
+func (l *Layer) size() int {
+	var n int
+	if l.HasName {
+		n += sizeBytes(layerName, len(l.Name))
+	}
+
+	for i := range l.Features {
+		n += sizeBytes(layerFeatures, l.Features[i].size())
+	}
+
+	for _, key := range l.Keys {
+		n += sizeBytes(layerKeys, len(key))
+	}
+
+	for i := range l.Values {
+		n += sizeBytes(layerValues, l.Values[i].size())
+	}
+
+	if l.HasExtent {
+		n += 1 + sizeVarint(uint64(l.Extent))
+	}
+
+	if l.HasVersion {
+		n += 1 + sizeVarint(uint64(l.Version))
+	}
+	return n
+}
+
+func (f *Feature) append(b []byte) []byte {
+	if f.HasID {
+		b = appendKey(b, featureID, varintType)
+		b = appendVarint(b, f.ID)
+	}
+
+	b = appendPacked(b, featureTags, f.Tags)
+
+	if f.HasType {
+		b = appendKey(b, featureType, varintType)
+		b = appendVarint(b, uint64(int64(f.Type)))
+	}
+	return appendPacked(b, featureGeometry, f.Geometry)
+}
+
+func (f *Feature) size() int {
+	var n int
+	if f.HasID {
+		n += 1 + sizeVarint(f.ID)
+	}
+
+	n += sizePacked(featureTags, f.Tags)
+
+	if f.HasType {
+		n += 1 + sizeVarint(uint64(int64(f.Type)))
+	}
+	return n + sizePacked(featureGeometry, f.Geometry)
+}
+
+func (v *Value) append(b []byte) []byte {
+	if v.Has(valueString) {
+		b = appendString(b, valueString, v.String)
+	}
+	if v.Has(valueFloat) {
+		b = appendKey(b, valueFloat, fixed32Type)
+		b = appendFixed32(b, math.Float32bits(v.Float))
+	}
+	if v.Has(valueDouble) {
+		b = appendKey(b, valueDouble, fixed64Type)
+		b = appendFixed64(b, math.Float64bits(v.Double))
+	}
+	if v.Has(valueInt) {
+		b = appendKey(b, valueInt, varintType)
+		b = appendVarint(b, uint64(v.Int))
+	}
+	if v.Has(valueUint) {
+		b = appendKey(b, valueUint, varintType)
+		b = appendVarint(b, v.Uint)
+	}
+	if v.Has(valueSint) {
+		b = appendKey(b, valueSint, varintType)
+		b = appendVarint(b, zigzag(v.Sint))
+	}
+	if v.Has(valueBool) {
+		b = appendKey(b, valueBool, varintType)
+		if v.Bool {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+func (v *Value) size() int {
+	var n int
+	if v.Has(valueString) {
+		n += sizeBytes(valueString, len(v.String))
+	}
+	if v.Has(valueFloat) {
+		n += 1 + 4
+	}
+	if v.Has(valueDouble) {
+		n += 1 + 8
+	}
+	if v.Has(valueInt) {
+		n += 1 + sizeVarint(uint64(v.Int))
+	}
+	if v.Has(valueUint) {
+		n += 1 + sizeVarint(v.Uint)
+	}
+	if v.Has(valueSint) {
+		n += 1 + sizeVarint(zigzag(v.Sint))
+	}
+	if v.Has(valueBool) {
+		n += 1 + 1
+	}
+	return n
+}
+
+func sizePacked(field int, s []uint32) int {
+	if len(s) == 0 {
+		return 0
+	}
+
+	var n int
+	for _, v := range s {
+		n += sizeVarint(uint64(v))
+	}
+	return sizeBytes(field, n)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
@@ -0,0 +1,60 @@
+package wire_test
+
+import (
+	"math"
+	"testing"
+
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/everystreet/go-mvt/internal/wire"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzDecodeLayer(f *testing.F) {
+	for _, layer := range oracleTile().Layers {
+		data, err := proto.Marshal(layer)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var l wire.Layer
+		if err := wire.DecodeLayer(data, &l); err != nil {
+			return
+		}
+
+		// Anything that decodes must encode to a layer that decodes the same,
+		// and that the generated code agrees with.
+		encoded := wire.AppendLayer(nil, &l)
+
+		var tile spec.Tile
+		err := proto.Unmarshal(encoded, &tile)
+		if _, ok := err.(*proto.RequiredNotSetError); !ok {
+			require.NoError(t, err)
+		}
+
+		var decoded wire.Layer
+		require.NoError(t, wire.Layers(encoded, func(data []byte) error {
+			return wire.DecodeLayer(data, &decoded)
+		}))
+		requireEqualLayers(t, &l, &decoded)
+	})
+}
+
+// requireEqualLayers compares layers field by field, as a NaN value is never equal to itself.
+func requireEqualLayers(t *testing.T, expected, actual *wire.Layer) {
+	require.Equal(t, expected.Name, actual.Name)
+	require.Equal(t, expected.Version, actual.Version)
+	require.Equal(t, expected.Extent, actual.Extent)
+	require.Equal(t, expected.Keys, actual.Keys)
+	require.Equal(t, expected.Features, actual.Features)
+
+	require.Len(t, actual.Values, len(expected.Values))
+	for i, v := range expected.Values {
+		w := actual.Values[i]
+		require.Equal(t, math.Float32bits(v.Float), math.Float32bits(w.Float))
+		require.Equal(t, math.Float64bits(v.Double), math.Float64bits(w.Double))
+		v.Float, v.Double, w.Float, w.Double = 0, 0, 0, 0
+		require.Equal(t, v, w)
+	}
+}
@@ -0,0 +1,237 @@
+// Package wire reads and writes the protocol buffer wire format of the vector tile schema.
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// Wire types.
+const (
+	varintType  = 0
+	fixed64Type = 1
+	bytesType   = 2
+	fixed32Type = 5
+)
+
+// Field numbers of the vector tile schema.
+const (
+	tileLayers = 3
+
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+	layerVersion  = 15
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueFloat  = 2
+	valueDouble = 3
+	valueInt    = 4
+	valueUint   = 5
+	valueSint   = 6
+	valueBool   = 7
+)
+
+// ErrTruncated is returned when a message ends in the middle of a field.
+var ErrTruncated = errors.New("unexpected end of message")
+
+// reader decodes wire format primitives from a message.
+type reader struct {
+	data []byte
+	off  int
+}
+
+func (r *reader) done() bool {
+	return r.off >= len(r.data)
+}
+
+func (r *reader) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if r.off >= len(r.data) {
+			return 0, ErrTruncated
+		}
+
+		b := r.data[r.off]
+		r.off++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("varint overflows 64 bits at offset %d", r.off)
+}
+
+// key returns the field number and wire type of the next field.
+func (r *reader) key() (int, int, error) {
+	k, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	field := k >> 3
+	if field == 0 || field > 1<<29-1 {
+		return 0, 0, fmt.Errorf("invalid field number %d", field)
+	}
+	return int(field), int(k & 7), nil
+}
+
+func (r *reader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	} else if n > uint64(len(r.data)-r.off) {
+		return nil, ErrTruncated
+	}
+
+	b := r.data[r.off : r.off+int(n)]
+	r.off += int(n)
+	return b, nil
+}
+
+func (r *reader) fixed32() (uint32, error) {
+	if len(r.data)-r.off < 4 {
+		return 0, ErrTruncated
+	}
+
+	b := r.data[r.off:]
+	r.off += 4
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24, nil
+}
+
+func (r *reader) fixed64() (uint64, error) {
+	lo, err := r.fixed32()
+	if err != nil {
+		return 0, err
+	}
+
+	hi, err := r.fixed32()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(lo) | uint64(hi)<<32, nil
+}
+
+// skip the value of a field with the supplied wire type.
+func (r *reader) skip(wireType int) error {
+	var err error
+	switch wireType {
+	case varintType:
+		_, err = r.varint()
+	case fixed64Type:
+		_, err = r.fixed64()
+	case bytesType:
+		_, err = r.bytes()
+	case fixed32Type:
+		_, err = r.fixed32()
+	default:
+		err = fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	return err
+}
+
+// uint32s appends the value of a repeated uint32 field to s.
+// Both packed and unpacked encodings are accepted.
+func (r *reader) uint32s(wireType int, s []uint32) ([]uint32, error) {
+	switch wireType {
+	case varintType:
+		v, err := r.varint()
+		if err != nil {
+			return s, err
+		}
+		return append(s, uint32(v)), nil
+	case bytesType:
+		b, err := r.bytes()
+		if err != nil {
+			return s, err
+		}
+
+		packed := reader{data: b}
+		for !packed.done() {
+			v, err := packed.varint()
+			if err != nil {
+				return s, err
+			}
+			s = append(s, uint32(v))
+		}
+		return s, nil
+	default:
+		return s, fmt.Errorf("unexpected wire type %d for repeated field", wireType)
+	}
+}
+
+// expect returns an error if the wire type of a field does not match want.
+func expect(field, wireType, want int) error {
+	if wireType != want {
+		return fmt.Errorf("unexpected wire type %d for field %d", wireType, field)
+	}
+	return nil
+}
+
+func appendKey(b []byte, field, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendFixed32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	return appendFixed32(appendFixed32(b, uint32(v)), uint32(v>>32))
+}
+
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = appendKey(b, field, bytesType)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendString(b []byte, field int, s string) []byte {
+	b = appendKey(b, field, bytesType)
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendPacked appends a packed repeated uint32 field. Empty fields are omitted.
+func appendPacked(b []byte, field int, s []uint32) []byte {
+	if len(s) == 0 {
+		return b
+	}
+
+	var n int
+	for _, v := range s {
+		n += sizeVarint(uint64(v))
+	}
+
+	b = appendKey(b, field, bytesType)
+	b = appendVarint(b, uint64(n))
+	for _, v := range s {
+		b = appendVarint(b, uint64(v))
+	}
+	return b
+}
+
+func sizeVarint(v uint64) int {
+	return (bits.Len64(v|1) + 6) / 7
+}
+
+// sizeBytes returns the encoded size of a length-delimited field with a payload of n bytes.
+func sizeBytes(field, n int) int {
+	return sizeVarint(uint64(field)<<3) + sizeVarint(uint64(n)) + n
+}
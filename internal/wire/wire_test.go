@@ -0,0 +1,375 @@
+package wire_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/everystreet/go-mvt/internal/wire"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// oracleTile is encoded with the generated code, which the wire package must agree with.
+func oracleTile() *spec.Tile {
+	return &spec.Tile{
+		Layers: []*spec.Tile_Layer{
+			{
+				Version: proto.Uint32(2),
+				Name:    proto.String("roads"),
+				Extent:  proto.Uint32(4096),
+				Keys:    []string{"name", "lanes", "speed", "oneway", "ratio", "offset", "big"},
+				Values: []*spec.Tile_Value{
+					{StringValue: proto.String("High Street")},
+					{IntValue: proto.Int64(-2)},
+					{UintValue: proto.Uint64(math.MaxUint64)},
+					{BoolValue: proto.Bool(true)},
+					{FloatValue: proto.Float32(0.25)},
+					{SintValue: proto.Int64(-300)},
+					{DoubleValue: proto.Float64(math.Inf(-1))},
+				},
+				Features: []*spec.Tile_Feature{
+					{
+						Id:       proto.Uint64(1),
+						Tags:     []uint32{0, 0, 1, 1, 2, 2, 3, 3},
+						Type:     spec.Tile_LINESTRING.Enum(),
+						Geometry: []uint32{9, 4, 4, 18, 0, 16, 16, 0},
+					},
+					{
+						Tags:     []uint32{4, 4, 5, 5, 6, 6},
+						Type:     spec.Tile_POINT.Enum(),
+						Geometry: []uint32{9, 50, 34},
+					},
+					{
+						Id:   proto.Uint64(1 << 40),
+						Type: spec.Tile_UNKNOWN.Enum(),
+					},
+				},
+			},
+			{
+				Version: proto.Uint32(1),
+				Name:    proto.String(""),
+			},
+		},
+	}
+}
+
+func TestDecodeLayer(t *testing.T) {
+	tile := oracleTile()
+	data, err := proto.Marshal(tile)
+	require.NoError(t, err)
+
+	var i int
+	var l wire.Layer
+	err = wire.Layers(data, func(b []byte) error {
+		require.NoError(t, wire.DecodeLayer(b, &l))
+		requireSameLayer(t, tile.Layers[i], &l)
+		i++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(tile.Layers), i)
+
+	n, err := wire.CountLayers(data)
+	require.NoError(t, err)
+	require.Equal(t, len(tile.Layers), n)
+}
+
+func TestAppendLayer(t *testing.T) {
+	tile := oracleTile()
+	expected, err := proto.Marshal(tile)
+	require.NoError(t, err)
+
+	var data []byte
+	for _, layer := range tile.Layers {
+		data = wire.AppendLayer(data, wireLayer(layer))
+	}
+	require.Equal(t, expected, data)
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	var l wire.Layer
+	require.NoError(t, wire.DecodeLayer(nil, &l))
+	require.Equal(t, wire.Layer{Version: 1, Extent: 4096}, l)
+}
+
+func TestDecodeUnpacked(t *testing.T) {
+	// A feature with unpacked tags, and geometry split across two packed fields.
+	feature := []byte{
+		0x10, 0x00, 0x10, 0x01, // tags: 0, 1
+		0x22, 0x01, 0x09, // geometry: 9
+		0x18, 0x01, // type: POINT
+		0x22, 0x02, 0x32, 0x22, // geometry: 50, 34
+	}
+	layer := append([]byte{0x12, byte(len(feature))}, feature...)
+
+	var l wire.Layer
+	require.NoError(t, wire.DecodeLayer(layer, &l))
+	require.Len(t, l.Features, 1)
+	require.Equal(t, []uint32{0, 1}, l.Features[0].Tags)
+	require.Equal(t, []uint32{9, 50, 34}, l.Features[0].Geometry)
+	require.Equal(t, spec.Tile_POINT, l.Features[0].Type)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data, err := proto.Marshal(oracleTile())
+	require.NoError(t, err)
+
+	for n := 0; n < len(data); n++ {
+		err := wire.Layers(data[:n], func(b []byte) error {
+			var l wire.Layer
+			return wire.DecodeLayer(b, &l)
+		})
+		if err == nil {
+			// Truncating the tile between layers leaves a valid tile.
+			continue
+		}
+
+		var tile spec.Tile
+		require.Error(t, proto.Unmarshal(data[:n], &tile), "length %d", n)
+	}
+
+	tests := map[string][]byte{
+		"wrong wire type":  {0x08, 0x01}, // name as varint
+		"invalid field":    {0x00},
+		"varint overflow":  {0x78, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"group wire type":  {0x0b},
+		"truncated string": {0x0a, 0x05, 'a'},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			var l wire.Layer
+			require.Error(t, wire.DecodeLayer(data, &l))
+		})
+	}
+}
+
+func TestDecodeLayerReuse(t *testing.T) {
+	data, err := proto.Marshal(oracleTile().Layers[0])
+	require.NoError(t, err)
+
+	var l wire.Layer
+	require.NoError(t, wire.DecodeLayer(data, &l))
+
+	allocs := testing.AllocsPerRun(10, func() {
+		require.NoError(t, wire.DecodeLayer(data, &l))
+	})
+	// Only the strings are allocated once the layer's slices have grown.
+	require.LessOrEqual(t, allocs, float64(1+len(l.Keys)+1))
+}
+
+func TestDecodeLayerLimits(t *testing.T) {
+	layer := &spec.Tile_Layer{
+		Version: proto.Uint32(2),
+		Name:    proto.String("points"),
+		Keys:    []string{"a", "b", "c"},
+	}
+	for i := 0; i < 1000; i++ {
+		layer.Features = append(layer.Features, &spec.Tile_Feature{Type: spec.Tile_POINT.Enum(), Geometry: []uint32{9, 2, 2}})
+		layer.Values = append(layer.Values, &spec.Tile_Value{IntValue: proto.Int64(int64(i))})
+	}
+	data, err := proto.Marshal(layer)
+	require.NoError(t, err)
+
+	var l wire.Layer
+	require.NoError(t, wire.DecodeLayerLimits(data, &l, wire.Limits{Features: 1000, Keys: 3, Values: 1000}))
+
+	tests := map[string]struct {
+		lim     wire.Limits
+		field   int
+		decoded func(l *wire.Layer) int
+	}{
+		"features": {wire.Limits{Features: 10}, wire.FeaturesField, func(l *wire.Layer) int { return len(l.Features) }},
+		"keys":     {wire.Limits{Keys: 2}, wire.KeysField, func(l *wire.Layer) int { return len(l.Keys) }},
+		"values":   {wire.Limits{Values: 10}, wire.ValuesField, func(l *wire.Layer) int { return len(l.Values) }},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var l wire.Layer
+			err := wire.DecodeLayerLimits(data, &l, tt.lim)
+			limErr, ok := err.(*wire.LimitError)
+			require.True(t, ok, err)
+			require.Equal(t, tt.field, limErr.Field)
+
+			// Decoding stops at the limit, rather than decoding the whole layer first.
+			require.Equal(t, limErr.Max, tt.decoded(&l))
+		})
+	}
+}
+
+func requireSameLayer(t *testing.T, expected *spec.Tile_Layer, actual *wire.Layer) {
+	require.Equal(t, expected.Name != nil, actual.HasName)
+	require.Equal(t, expected.GetName(), actual.Name)
+	require.Equal(t, expected.Version != nil, actual.HasVersion)
+	require.Equal(t, expected.GetVersion(), actual.Version)
+	require.Equal(t, expected.Extent != nil, actual.HasExtent)
+	require.Equal(t, expected.GetExtent(), actual.Extent)
+	require.Equal(t, len(expected.Keys), len(actual.Keys))
+	for i, key := range expected.Keys {
+		require.Equal(t, key, actual.Keys[i])
+	}
+
+	require.Len(t, actual.Values, len(expected.Values))
+	for i, v := range expected.Values {
+		require.Equal(t, wireValue(v), actual.Values[i])
+	}
+
+	require.Len(t, actual.Features, len(expected.Features))
+	for i, f := range expected.Features {
+		require.Equal(t, wireFeature(f), actual.Features[i])
+	}
+}
+
+func wireLayer(layer *spec.Tile_Layer) *wire.Layer {
+	l := wire.Layer{
+		Name:       layer.GetName(),
+		Version:    layer.GetVersion(),
+		Extent:     layer.GetExtent(),
+		HasName:    layer.Name != nil,
+		HasVersion: layer.Version != nil,
+		HasExtent:  layer.Extent != nil,
+		Keys:       layer.Keys,
+	}
+
+	for _, v := range layer.Values {
+		l.Values = append(l.Values, wireValue(v))
+	}
+
+	for _, f := range layer.Features {
+		l.Features = append(l.Features, wireFeature(f))
+	}
+	return &l
+}
+
+func wireFeature(f *spec.Tile_Feature) wire.Feature {
+	return wire.Feature{
+		ID:       f.GetId(),
+		HasID:    f.Id != nil,
+		Type:     f.GetType(),
+		HasType:  f.Type != nil,
+		Tags:     f.Tags,
+		Geometry: f.Geometry,
+	}
+}
+
+func wireValue(v *spec.Tile_Value) wire.Value {
+	var fields uint8
+	for n, set := range map[int]bool{
+		wire.StringField: v.StringValue != nil,
+		wire.FloatField:  v.FloatValue != nil,
+		wire.DoubleField: v.DoubleValue != nil,
+		wire.IntField:    v.IntValue != nil,
+		wire.UintField:   v.UintValue != nil,
+		wire.SintField:   v.SintValue != nil,
+		wire.BoolField:   v.BoolValue != nil,
+	} {
+		if set {
+			fields |= 1 << n
+		}
+	}
+
+	return wire.Value{
+		Fields: fields,
+		String: v.GetStringValue(),
+		Float:  v.GetFloatValue(),
+		Double: v.GetDoubleValue(),
+		Int:    v.GetIntValue(),
+		Uint:   v.GetUintValue(),
+		Sint:   v.GetSintValue(),
+		Bool:   v.GetBoolValue(),
+	}
+}
+
+func BenchmarkDecodeLayer(b *testing.B) {
+	data, err := proto.Marshal(benchTile())
+	require.NoError(b, err)
+	b.SetBytes(int64(len(data)))
+
+	var l wire.Layer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := wire.Layers(data, func(data []byte) error {
+			return wire.DecodeLayer(data, &l)
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkProtoUnmarshal decodes the same tile as BenchmarkDecodeLayer with the generated code.
+func BenchmarkProtoUnmarshal(b *testing.B) {
+	data, err := proto.Marshal(benchTile())
+	require.NoError(b, err)
+	b.SetBytes(int64(len(data)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var tile spec.Tile
+		if err := proto.Unmarshal(data, &tile); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendLayer(b *testing.B) {
+	tile := benchTile()
+	layers := make([]*wire.Layer, len(tile.Layers))
+	for i, layer := range tile.Layers {
+		layers[i] = wireLayer(layer)
+	}
+
+	var data []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data = data[:0]
+		for _, l := range layers {
+			data = wire.AppendLayer(data, l)
+		}
+	}
+}
+
+// BenchmarkProtoMarshal encodes the same tile as BenchmarkAppendLayer with the generated code.
+func BenchmarkProtoMarshal(b *testing.B) {
+	tile := benchTile()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := proto.Marshal(tile); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchTile returns a tile with a single layer of 1000 features, each with tags and a short line.
+func benchTile() *spec.Tile {
+	layer := &spec.Tile_Layer{
+		Version: proto.Uint32(2),
+		Name:    proto.String("roads"),
+		Extent:  proto.Uint32(4096),
+		Keys:    []string{"name", "lanes"},
+	}
+
+	for i := 0; i < 50; i++ {
+		layer.Values = append(layer.Values, &spec.Tile_Value{StringValue: proto.String(fmt.Sprintf("Street %d", i))})
+	}
+	for i := 0; i < 4; i++ {
+		layer.Values = append(layer.Values, &spec.Tile_Value{IntValue: proto.Int64(int64(i))})
+	}
+
+	for i := 0; i < 1000; i++ {
+		layer.Features = append(layer.Features, &spec.Tile_Feature{
+			Id:       proto.Uint64(uint64(i)),
+			Tags:     []uint32{0, uint32(i % 50), 1, uint32(50 + i%4)},
+			Type:     spec.Tile_LINESTRING.Enum(),
+			Geometry: []uint32{9, uint32(i * 2), 4, 34, 2, 0, 8, 2, 10, 4},
+		})
+	}
+	return &spec.Tile{Layers: []*spec.Tile_Layer{layer}}
+}
@@ -0,0 +1,179 @@
+package mvt
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/everystreet/go-geojson/v2"
+	"github.com/everystreet/go-mvt/internal/geometry"
+	"github.com/golang/geo/r2"
+)
+
+// LabelLayers configures companion layers of label points, by the name of the layer of polygons they label.
+type LabelLayers map[LayerName]LabelLayer
+
+// LabelLayer configures a companion layer with a label point for each polygon feature of a layer.
+// The label point is the pole of inaccessibility of the polygon, which is the point inside it farthest from its edges.
+type LabelLayer struct {
+	// Layer is the name of the layer of label points.
+	Layer LayerName
+
+	// Keys of the tags copied from each polygon feature to its label point. If nil, every tag is copied, and if empty, none are.
+	Keys []string
+
+	// Precision of the label points, in tile coordinates. If zero, 1 is used.
+	Precision float64
+}
+
+// Validate the label layers. A label layer must be named, and not share a name with another layer.
+func (l LabelLayers) Validate() error {
+	names := make(map[LayerName]bool, len(l))
+	for from := range l {
+		names[from] = true
+	}
+
+	for from, cfg := range l {
+		if cfg.Layer == "" {
+			return fmt.Errorf("label layer of '%s' must be named", from)
+		} else if names[cfg.Layer] {
+			return fmt.Errorf("label layer '%s' of '%s' is already a layer", cfg.Layer, from)
+		} else if cfg.Precision < 0 {
+			return fmt.Errorf("label layer '%s' precision must not be negative", cfg.Layer)
+		}
+		names[cfg.Layer] = true
+	}
+	return nil
+}
+
+// layers returns the layers with a layer of label points added for each configured layer that is present.
+// Geographic polygons are projected to tile coordinates before their label points are found.
+// Label points are tile geometries, and carry the ID of their polygon feature and its selected tags.
+// Label layers with no features are not added. The supplied layers are not modified.
+func (l LabelLayers) layers(layers Layers, project func(LayerName) geometry.Project) (Layers, error) {
+	if len(l) == 0 {
+		return layers, nil
+	}
+
+	out := make(Layers, len(layers)+len(l))
+	for name, layer := range layers {
+		out[name] = layer
+	}
+
+	for name, layer := range layers {
+		cfg, ok := l[name]
+		if !ok {
+			continue
+		} else if _, ok := layers[cfg.Layer]; ok {
+			return nil, fmt.Errorf("label layer '%s' of '%s' is already a layer", cfg.Layer, name)
+		}
+
+		precision := cfg.Precision
+		if precision == 0 {
+			precision = 1
+		}
+
+		var features []Feature
+		for i, f := range layer.Features {
+			rings, err := labelRings(f.Geometry, project(name))
+			if err != nil {
+				return nil, &FeatureError{Layer: name, Index: i, ID: f.ID, Offset: -1, Err: err}
+			} else if rings == nil {
+				continue
+			}
+
+			p, _ := geometry.PoleOfInaccessibility(rings, precision)
+			tags := f.Tags
+			if cfg.Keys != nil {
+				tags = selectTags(tags, cfg.Keys)
+			}
+
+			features = append(features, Feature{
+				ID:   f.ID,
+				Tags: tags,
+				Geometry: &TileGeometry{
+					GeomType: TilePointType,
+					Parts:    [][]TilePoint{{{X: int32(math.Round(p.X)), Y: int32(math.Round(p.Y))}}},
+				},
+			})
+		}
+
+		if len(features) > 0 {
+			out[cfg.Layer] = MakeLayer(layer.Extent, features...)
+		}
+	}
+	return out, nil
+}
+
+// LabelPosition returns the pole of inaccessibility of the polygon, which is the point inside it farthest from its edges.
+// Longitude and latitude are treated as planar coordinates, and precision is in degrees.
+// If zero, the precision is a thousandth of the smaller side of the bounds of the polygon.
+func LabelPosition(p geojson.Polygon, precision float64) geojson.Position {
+	rings := make([][]r2.Point, len(p))
+	for i, ring := range p {
+		rings[i] = make([]r2.Point, len(ring))
+		for j, pos := range ring {
+			rings[i][j] = r2.Point{X: pos.Lng.Degrees(), Y: pos.Lat.Degrees()}
+		}
+	}
+
+	label, _ := geometry.PoleOfInaccessibility(rings, precision)
+	return geojson.MakePosition(label.Y, label.X)
+}
+
+// LabelPoint returns the pole of inaccessibility of a polygon geometry, which is the point inside it farthest from its edges,
+// rounded to tile coordinates. The polygons of a multipolygon are searched together.
+// If zero, the precision is a thousandth of the smaller side of the bounds of the geometry.
+// It returns false if the geometry is not a polygon.
+func (g TileGeometry) LabelPoint(precision float64) (TilePoint, bool) {
+	if g.GeomType != TilePolygonType || len(g.Parts) == 0 {
+		return TilePoint{}, false
+	}
+
+	label, _ := geometry.PoleOfInaccessibility(tileRings(g.Parts), precision)
+	return TilePoint{X: int32(math.Round(label.X)), Y: int32(math.Round(label.Y))}, true
+}
+
+// labelRings returns the rings of a polygon geometry in tile coordinates, or nil if it is not a polygon.
+func labelRings(geo geojson.Geometry, project geometry.Project) ([][]r2.Point, error) {
+	var polygons [][][]geojson.Position
+	switch g := geo.(type) {
+	case *TileGeometry:
+		if g.GeomType != TilePolygonType || len(g.Parts) == 0 {
+			return nil, nil
+		}
+		return tileRings(g.Parts), nil
+	case *geojson.Polygon:
+		polygons = [][][]geojson.Position{*g}
+	case *geojson.MultiPolygon:
+		polygons = *g
+	default:
+		return nil, nil
+	}
+
+	if project == nil {
+		return nil, fmt.Errorf("missing projection")
+	}
+
+	var rings [][]r2.Point
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			projected := make([]r2.Point, len(ring))
+			for i, pos := range ring {
+				projected[i] = project(pos.LatLng)
+			}
+			rings = append(rings, projected)
+		}
+	}
+	return rings, nil
+}
+
+func tileRings(parts [][]TilePoint) [][]r2.Point {
+	rings := make([][]r2.Point, len(parts))
+	for i, part := range parts {
+		rings[i] = make([]r2.Point, len(part))
+		for j, p := range part {
+			rings[i][j] = r2.Point{X: float64(p.X), Y: float64(p.Y)}
+		}
+	}
+	return rings
+}
@@ -0,0 +1,121 @@
+package mvt_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
+	"github.com/stretchr/testify/require"
+)
+
+// uShape returns the rings of a U, whose centroid is in the gap between its arms.
+// Its arms and base are 100 wide, so the label point is where they meet.
+func uShape() [][]mvt21.TilePoint {
+	return [][]mvt21.TilePoint{{
+		{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 300}, {X: 500, Y: 300},
+		{X: 500, Y: 0}, {X: 600, Y: 0}, {X: 600, Y: 400}, {X: 0, Y: 400},
+	}}
+}
+
+func TestLabelPoint(t *testing.T) {
+	g := mvt21.TileGeometry{GeomType: mvt21.TilePolygonType, Parts: uShape()}
+	p, ok := g.LabelPoint(1)
+	require.True(t, ok)
+	require.True(t, p.Y > 300 && p.Y < 400, p)
+
+	// A hole in the base moves the label point into an arm.
+	g.Parts = append(g.Parts, []mvt21.TilePoint{{X: 10, Y: 310}, {X: 10, Y: 390}, {X: 590, Y: 390}, {X: 590, Y: 310}})
+	p, ok = g.LabelPoint(1)
+	require.True(t, ok)
+	require.True(t, p.X < 100 || p.X > 500, p)
+	require.Less(t, p.Y, int32(300))
+
+	_, ok = mvt21.TileGeometry{GeomType: mvt21.TileLineStringType, Parts: uShape()}.LabelPoint(1)
+	require.False(t, ok)
+}
+
+func TestLabelPosition(t *testing.T) {
+	var ring []geojson.Position
+	for _, p := range uShape()[0] {
+		ring = append(ring, geojson.MakePosition(float64(p.Y)/100, float64(p.X)/100))
+	}
+	ring = append(ring, ring[0])
+
+	label := mvt21.LabelPosition(geojson.Polygon{ring}, 0.001)
+	require.Greater(t, label.Lat.Degrees(), 3.0)
+	require.Less(t, label.Lat.Degrees(), 4.0)
+}
+
+func TestMarshalLabels(t *testing.T) {
+	layers := mvt21.Layers{"buildings": mvt21.MakeLayer(4096,
+		mvt21.Feature{
+			ID:       mvt21.NewOptionalUint64(7),
+			Tags:     geojson.PropertyList{{Name: "name", Value: "Hall"}, {Name: "levels", Value: int64(2)}},
+			Geometry: &mvt21.TileGeometry{GeomType: mvt21.TilePolygonType, Parts: uShape()},
+		},
+		mvt21.Feature{
+			Geometry: &mvt21.TileGeometry{
+				GeomType: mvt21.TileLineStringType,
+				Parts:    [][]mvt21.TilePoint{{{X: 0, Y: 0}, {X: 10, Y: 10}}},
+			},
+		},
+	)}
+	opts := mvt21.MarshalOptions{Labels: mvt21.LabelLayers{
+		"buildings": {Layer: "building_labels", Keys: []string{"name"}},
+	}}
+
+	data, err := opts.Marshal(layers)
+	require.NoError(t, err)
+
+	// Tile coordinates are flipped and scaled into valid positions.
+	unproject := func(p r2.Point) s2.LatLng { return s2.LatLngFromDegrees(-p.Y/100, p.X/100) }
+	decoded, err := mvt21.Unmarshal(data, unproject)
+	require.NoError(t, err)
+	require.Len(t, decoded["buildings"].Features, 2)
+
+	labels := decoded["building_labels"]
+	require.Equal(t, uint32(4096), labels.Extent)
+	require.Len(t, labels.Features, 1)
+	require.Equal(t, mvt21.NewOptionalUint64(7), labels.Features[0].ID)
+	require.Equal(t, geojson.PropertyList{{Name: "name", Value: "Hall"}}, labels.Features[0].Tags)
+
+	// Geographic polygons are projected before their label points are found.
+	geographic := mvt21.Layers{"buildings": mvt21.MakeLayer(4096, mvt21.Feature{
+		Geometry: geojson.NewPolygon([]geojson.Position{
+			geojson.MakePosition(0, 0), geojson.MakePosition(0, 100),
+			geojson.MakePosition(100, 100), geojson.MakePosition(100, 0),
+			geojson.MakePosition(0, 0),
+		}).Geometry,
+	})}
+	opts.Project = SimpleProject
+	data, err = opts.Marshal(geographic)
+	require.NoError(t, err)
+	decoded, err = mvt21.Unmarshal(data, unproject)
+	require.NoError(t, err)
+	require.Len(t, decoded["building_labels"].Features, 1)
+
+	opts.Project = nil
+	_, err = opts.Marshal(geographic)
+	var featureErr *mvt21.FeatureError
+	require.True(t, errors.As(err, &featureErr))
+
+	for name, labels := range map[string]mvt21.LabelLayers{
+		"unnamed":            {"buildings": {}},
+		"existing layer":     {"buildings": {Layer: "buildings"}},
+		"shared label layer": {"buildings": {Layer: "labels"}, "parks": {Layer: "labels"}},
+		"negative precision": {"buildings": {Layer: "labels", Precision: -1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := mvt21.MarshalOptions{Labels: labels}.Marshal(layers)
+			require.Error(t, err)
+		})
+	}
+
+	_, err = mvt21.MarshalOptions{Labels: mvt21.LabelLayers{
+		"parks": {Layer: "buildings"},
+	}}.Marshal(mvt21.Layers{"parks": mvt21.MakeLayer(4096), "buildings": mvt21.MakeLayer(4096)})
+	require.Error(t, err)
+}
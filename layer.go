@@ -2,6 +2,7 @@ package mvt
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 
 	"github.com/everystreet/go-geojson/v2"
@@ -25,14 +26,24 @@ type (
 
 // Validate the set of layers.
 func (l Layers) Validate() error {
-	for name, l := range l {
-		if err := l.Validate(); err != nil {
+	for _, name := range l.names() {
+		if err := l[name].Validate(); err != nil {
 			return fmt.Errorf("layer '%s' invalid: %w", name, err)
 		}
 	}
 	return nil
 }
 
+// names returns the layer names in sorted order.
+func (l Layers) names() []LayerName {
+	names := make([]LayerName, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
 // MakeLayer setting the required extent field.
 func MakeLayer(extent uint32, features ...Feature) Layer {
 	return Layer{
@@ -45,11 +56,11 @@ func MakeLayer(extent uint32, features ...Feature) Layer {
 func (l Layer) Validate() error {
 	for _, f := range l.Features {
 		switch t := f.Geometry.(type) {
-		case *UnknownGeometry, *geojson.Point, *geojson.MultiPoint,
+		case *UnknownGeometry, *TileGeometry, *geojson.Point, *geojson.MultiPoint,
 			*geojson.LineString, *geojson.MultiLineString,
 			*geojson.Polygon, *geojson.MultiPolygon:
 		default:
-			return fmt.Errorf("'%t' is not allowed", t)
+			return fmt.Errorf("'%T' is not allowed", t)
 		}
 
 		if err := f.Geometry.Validate(); err != nil {
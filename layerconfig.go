@@ -0,0 +1,248 @@
+package mvt
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/everystreet/go-geojson/v2"
+)
+
+// LayerConfigs configures the layers of a tile set, by name.
+// Layers without a configuration are encoded as they are at every zoom.
+type LayerConfigs map[LayerName]LayerConfig
+
+// LayerConfig configures how a layer is encoded at each zoom of a tile set.
+type LayerConfig struct {
+	// MinZoom is the lowest zoom at which the layer is encoded.
+	MinZoom uint32
+
+	// MaxZoom is the highest zoom at which the layer is encoded. If zero, the layer has no maximum zoom.
+	MaxZoom uint32
+
+	// Attributes selects the tags that are kept at each zoom, in order of increasing MinZoom.
+	// The last entry whose MinZoom is at or below the zoom applies. If none applies, every tag is kept.
+	Attributes []ZoomAttributes
+
+	// Extents sets the extent of the layer at each zoom, in order of increasing MinZoom.
+	// The last entry whose MinZoom is at or below the zoom applies. If none applies, the extent of the layer is kept.
+	Extents []ZoomExtent
+
+	// Simplify simplifies the lines and polygons of the layer together, after it is scaled to its extent,
+	// so that the edges they share stay shared. If nil, they are not simplified.
+	Simplify *Simplification
+
+	// Reduce removes tiny polygons and short lines of the layer, after it is scaled to its extent. If nil, none are removed.
+	Reduce *Reduction
+
+	// Coalesce merges features of the layer with equal tags, after their tags are selected. If nil, features are not merged.
+	Coalesce *Coalesce
+}
+
+// ZoomAttributes selects the tags kept from a zoom upwards.
+type ZoomAttributes struct {
+	MinZoom uint32
+
+	// Keys of the tags that are kept. If nil, every tag is kept, and if empty, none are.
+	Keys []string
+}
+
+// ZoomExtent sets the extent from a zoom upwards.
+type ZoomExtent struct {
+	MinZoom uint32
+	Extent  uint32
+}
+
+// Validate the configuration of each layer.
+func (c LayerConfigs) Validate() error {
+	names := make([]LayerName, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if err := c[name].Validate(); err != nil {
+			return fmt.Errorf("layer '%s' config invalid: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Layers returns the layers as configured for the zoom.
+// Layers that are not visible at the zoom are removed, and the tags of each feature are selected.
+// If the extent of a layer changes, tile geometries are scaled to it, and features whose geometry collapses are removed.
+// Features are then simplified, tiny polygons and short lines removed, and features merged, if the layer is configured to.
+// Geographic geometries are unchanged, as they are projected to the extent when encoded, so they are only
+// simplified, reduced and merged once MarshalOptions has projected them to tile coordinates.
+// The supplied layers are not modified.
+func (c LayerConfigs) Layers(layers Layers, zoom uint32) Layers {
+	out := make(Layers, len(layers))
+	for name, layer := range layers {
+		cfg, ok := c[name]
+		if !ok {
+			out[name] = layer
+			continue
+		} else if !cfg.Visible(zoom) {
+			continue
+		}
+
+		extent := cfg.Extent(zoom, layer.Extent)
+		keys, all := cfg.Keys(zoom)
+		if extent == layer.Extent && all && cfg.Simplify == nil && cfg.Reduce == nil && cfg.Coalesce == nil {
+			out[name] = layer
+			continue
+		}
+
+		features := make([]Feature, 0, len(layer.Features))
+		for _, f := range layer.Features {
+			if !all {
+				f.Tags = selectTags(f.Tags, keys)
+			}
+			if g, ok := f.Geometry.(*TileGeometry); ok && extent != layer.Extent {
+				if g = g.scale(float64(extent) / float64(layer.Extent)); g == nil {
+					continue
+				}
+				f.Geometry = g
+			}
+			features = append(features, f)
+		}
+
+		out[name] = MakeLayer(extent, features...)
+		if cfg.Simplify != nil {
+			out[name] = cfg.Simplify.Layer(out[name])
+		}
+		if cfg.Reduce != nil {
+			out[name] = cfg.Reduce.Layer(out[name])
+		}
+		if cfg.Coalesce != nil {
+			out[name] = cfg.Coalesce.Layer(out[name])
+		}
+	}
+	return out
+}
+
+// Visible returns true if the layer is encoded at the zoom.
+func (c LayerConfig) Visible(zoom uint32) bool {
+	return zoom >= c.MinZoom && (c.MaxZoom == 0 || zoom <= c.MaxZoom)
+}
+
+// Keys returns the keys of the tags kept at the zoom. If every tag is kept, all is true.
+func (c LayerConfig) Keys(zoom uint32) (keys []string, all bool) {
+	for i := len(c.Attributes) - 1; i >= 0; i-- {
+		if a := c.Attributes[i]; a.MinZoom <= zoom {
+			return a.Keys, a.Keys == nil
+		}
+	}
+	return nil, true
+}
+
+// Tags returns the tags kept at the zoom.
+// The supplied tags are returned if every tag is kept, and otherwise a new list.
+func (c LayerConfig) Tags(zoom uint32, tags geojson.PropertyList) geojson.PropertyList {
+	keys, all := c.Keys(zoom)
+	if all {
+		return tags
+	}
+	return selectTags(tags, keys)
+}
+
+// Extent returns the extent of the layer at the zoom, or the supplied extent if none is configured.
+func (c LayerConfig) Extent(zoom, extent uint32) uint32 {
+	for i := len(c.Extents) - 1; i >= 0; i-- {
+		if e := c.Extents[i]; e.MinZoom <= zoom {
+			return e.Extent
+		}
+	}
+	return extent
+}
+
+// Validate the configuration.
+func (c LayerConfig) Validate() error {
+	if c.MaxZoom != 0 && c.MinZoom > c.MaxZoom {
+		return fmt.Errorf("min zoom %d is greater than max zoom %d", c.MinZoom, c.MaxZoom)
+	}
+
+	for i, a := range c.Attributes {
+		if i > 0 && a.MinZoom <= c.Attributes[i-1].MinZoom {
+			return fmt.Errorf("attributes must be in order of increasing min zoom")
+		}
+	}
+
+	for i, e := range c.Extents {
+		if e.Extent == 0 {
+			return fmt.Errorf("extent at zoom %d must not be zero", e.MinZoom)
+		} else if i > 0 && e.MinZoom <= c.Extents[i-1].MinZoom {
+			return fmt.Errorf("extents must be in order of increasing min zoom")
+		}
+	}
+
+	if c.Simplify != nil {
+		if err := c.Simplify.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Reduce != nil {
+		if err := c.Reduce.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Coalesce != nil && c.Coalesce.IDs > CoalesceByID {
+		return fmt.Errorf("unknown coalesce ID rule '%d'", c.Coalesce.IDs)
+	}
+	return nil
+}
+
+// selectTags returns a new list of the tags with the keys.
+func selectTags(tags geojson.PropertyList, keys []string) geojson.PropertyList {
+	var out geojson.PropertyList
+	for _, tag := range tags {
+		if slices.Contains(keys, tag.Name) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// scale returns the geometry with every point scaled by the factor and rounded.
+// Repeated points are removed, along with lines and rings that collapse.
+// It returns nil if nothing remains.
+func (g *TileGeometry) scale(factor float64) *TileGeometry {
+	out := TileGeometry{GeomType: g.GeomType}
+	exterior := false
+
+	for _, part := range g.Parts {
+		scaled := make([]TilePoint, 0, len(part))
+		for _, p := range part {
+			sp := TilePoint{
+				X: int32(math.Round(float64(p.X) * factor)),
+				Y: int32(math.Round(float64(p.Y) * factor)),
+			}
+			if n := len(scaled); g.GeomType == TilePointType || n == 0 || scaled[n-1] != sp {
+				scaled = append(scaled, sp)
+			}
+		}
+
+		switch g.GeomType {
+		case TileLineStringType:
+			if len(scaled) < 2 {
+				continue
+			}
+		case TilePolygonType:
+			if n := len(scaled); n > 1 && scaled[n-1] == scaled[0] {
+				scaled = scaled[:n-1]
+			}
+			if !KeepRing(part, scaled, &exterior) {
+				continue
+			}
+		}
+		out.Parts = append(out.Parts, scaled)
+	}
+
+	if len(out.Parts) == 0 {
+		return nil
+	}
+	return &out
+}
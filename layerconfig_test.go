@@ -0,0 +1,238 @@
+package mvt_test
+
+import (
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayerConfig(t *testing.T) {
+	cfg := mvt21.LayerConfig{
+		MinZoom: 2,
+		MaxZoom: 10,
+		Attributes: []mvt21.ZoomAttributes{
+			{MinZoom: 4, Keys: []string{}},
+			{MinZoom: 6, Keys: []string{"name"}},
+			{MinZoom: 8},
+		},
+		Extents: []mvt21.ZoomExtent{
+			{MinZoom: 0, Extent: 256},
+			{MinZoom: 6, Extent: 1024},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	require.False(t, cfg.Visible(1))
+	require.True(t, cfg.Visible(2))
+	require.True(t, cfg.Visible(10))
+	require.False(t, cfg.Visible(11))
+	require.True(t, mvt21.LayerConfig{MinZoom: 2}.Visible(30))
+
+	tags := geojson.PropertyList{
+		{Name: "name", Value: "Main Street"},
+		{Name: "address", Value: "1 Main Street"},
+	}
+	require.Equal(t, tags, cfg.Tags(3, tags))
+	require.Empty(t, cfg.Tags(4, tags))
+	require.Equal(t, tags[:1], cfg.Tags(7, tags))
+	require.Equal(t, tags, cfg.Tags(8, tags))
+
+	require.Equal(t, uint32(256), cfg.Extent(5, 4096))
+	require.Equal(t, uint32(1024), cfg.Extent(6, 4096))
+	require.Equal(t, uint32(4096), mvt21.LayerConfig{}.Extent(6, 4096))
+
+	for name, cfg := range map[string]mvt21.LayerConfig{
+		"min zoom above max zoom": {MinZoom: 5, MaxZoom: 4},
+		"unordered attributes": {
+			Attributes: []mvt21.ZoomAttributes{{MinZoom: 5}, {MinZoom: 5}},
+		},
+		"unordered extents": {
+			Extents: []mvt21.ZoomExtent{{MinZoom: 5, Extent: 512}, {MinZoom: 2, Extent: 512}},
+		},
+		"zero extent": {
+			Extents: []mvt21.ZoomExtent{{MinZoom: 5}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, cfg.Validate())
+			require.Error(t, mvt21.LayerConfigs{"layer": cfg}.Validate())
+		})
+	}
+}
+
+func TestLayerConfigsLayers(t *testing.T) {
+	box := func(x0, y0, x1, y1 int32) []mvt21.TilePoint {
+		return []mvt21.TilePoint{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}
+	}
+	hole := func(x0, y0, x1, y1 int32) []mvt21.TilePoint {
+		return []mvt21.TilePoint{{X: x0, Y: y0}, {X: x0, Y: y1}, {X: x1, Y: y1}, {X: x1, Y: y0}}
+	}
+
+	layers := mvt21.Layers{
+		"buildings": mvt21.MakeLayer(4096,
+			mvt21.Feature{
+				ID:   mvt21.NewOptionalUint64(1),
+				Tags: geojson.PropertyList{{Name: "name", Value: "Hall"}, {Name: "levels", Value: int64(2)}},
+				Geometry: &mvt21.TileGeometry{
+					GeomType: mvt21.TilePolygonType,
+					Parts: [][]mvt21.TilePoint{
+						box(0, 0, 400, 400), hole(100, 100, 104, 104),
+						box(1000, 1000, 1002, 1002), hole(1001, 1001, 1002, 1002),
+					},
+				},
+			},
+			mvt21.Feature{
+				ID: mvt21.NewOptionalUint64(2),
+				Geometry: &mvt21.TileGeometry{
+					GeomType: mvt21.TilePolygonType,
+					Parts:    [][]mvt21.TilePoint{box(2001, 2001, 2003, 2003)},
+				},
+			},
+			mvt21.Feature{
+				ID: mvt21.NewOptionalUint64(3),
+				Geometry: &mvt21.TileGeometry{
+					GeomType: mvt21.TileLineStringType,
+					Parts: [][]mvt21.TilePoint{
+						{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 800, Y: 0}},
+						{{X: 16, Y: 16}, {X: 18, Y: 18}},
+					},
+				},
+			},
+		),
+		"roads": mvt21.MakeLayer(4096),
+		"water": mvt21.MakeLayer(4096),
+	}
+	configs := mvt21.LayerConfigs{
+		"buildings": {
+			Attributes: []mvt21.ZoomAttributes{{Keys: []string{"name"}}},
+			Extents:    []mvt21.ZoomExtent{{Extent: 512}},
+		},
+		"roads": {MinZoom: 5},
+	}
+
+	configured := configs.Layers(layers, 4)
+	require.Equal(t, mvt21.Layers{
+		"buildings": mvt21.MakeLayer(512,
+			mvt21.Feature{
+				ID:   mvt21.NewOptionalUint64(1),
+				Tags: geojson.PropertyList{{Name: "name", Value: "Hall"}},
+				Geometry: &mvt21.TileGeometry{
+					GeomType: mvt21.TilePolygonType,
+					Parts:    [][]mvt21.TilePoint{box(0, 0, 50, 50)},
+				},
+			},
+			mvt21.Feature{
+				ID: mvt21.NewOptionalUint64(3),
+				Geometry: &mvt21.TileGeometry{
+					GeomType: mvt21.TileLineStringType,
+					Parts:    [][]mvt21.TilePoint{{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 100, Y: 0}}},
+				},
+			},
+		),
+		"water": layers["water"],
+	}, configured)
+
+	for _, f := range configured["buildings"].Features {
+		require.NoError(t, f.Geometry.Validate())
+	}
+
+	// The supplied layers are unchanged.
+	require.Len(t, layers["buildings"].Features[0].Tags, 2)
+	require.Len(t, layers["buildings"].Features[0].Geometry.(*mvt21.TileGeometry).Parts, 4)
+}
+
+func TestMarshalLayerConfigs(t *testing.T) {
+	layers := mvt21.Layers{
+		"places": mvt21.MakeLayer(4096, mvt21.Feature{
+			Tags: geojson.PropertyList{
+				{Name: "name", Value: "Town Hall"},
+				{Name: "address", Value: "1 Main Street"},
+			},
+			Geometry: geojson.NewPoint(40, 80).Geometry,
+		}),
+		"roads": mvt21.MakeLayer(4096, mvt21.Feature{
+			Geometry: geojson.NewPoint(1, 1).Geometry,
+		}),
+	}
+
+	opts := mvt21.MarshalOptions{
+		Project: SimpleProject,
+		Layers: mvt21.LayerConfigs{
+			"places": {
+				Attributes: []mvt21.ZoomAttributes{
+					{MinZoom: 0, Keys: []string{"name"}},
+					{MinZoom: 12},
+				},
+				Extents: []mvt21.ZoomExtent{
+					{MinZoom: 0, Extent: 512},
+					{MinZoom: 10, Extent: 4096},
+				},
+			},
+			"roads": {MinZoom: 5},
+		},
+	}
+
+	tests := []struct {
+		zoom     uint32
+		names    []string
+		extent   uint32
+		keys     []string
+		geometry []uint32
+	}{
+		{zoom: 4, names: []string{"places"}, extent: 512, keys: []string{"name"}, geometry: []uint32{9, 20, 10}},
+		{zoom: 10, names: []string{"places", "roads"}, extent: 4096, keys: []string{"name"}, geometry: []uint32{9, 160, 80}},
+		{zoom: 12, names: []string{"places", "roads"}, extent: 4096, keys: []string{"name", "address"}, geometry: []uint32{9, 160, 80}},
+	}
+
+	for _, tt := range tests {
+		opts.Zoom = tt.zoom
+		data, err := opts.Marshal(layers)
+		require.NoError(t, err)
+
+		var tile spec.Tile
+		require.NoError(t, proto.Unmarshal(data, &tile))
+
+		var names []string
+		for _, layer := range tile.Layers {
+			names = append(names, layer.GetName())
+		}
+		require.Equal(t, tt.names, names)
+
+		places := tile.Layers[0]
+		require.Equal(t, tt.extent, places.GetExtent())
+		require.Equal(t, tt.keys, places.Keys)
+		require.Equal(t, tt.geometry, places.Features[0].Geometry)
+	}
+
+	opts.Layers = mvt21.LayerConfigs{"places": {MinZoom: 2, MaxZoom: 1}}
+	_, err := opts.Marshal(layers)
+	require.Error(t, err)
+
+	t.Run("reduce", func(t *testing.T) {
+		square := func(lat, lng, size float64) mvt21.Feature {
+			return mvt21.Feature{Geometry: geojson.NewPolygon([]geojson.Position{
+				geojson.MakePosition(lat, lng),
+				geojson.MakePosition(lat, lng+size),
+				geojson.MakePosition(lat+size, lng+size),
+				geojson.MakePosition(lat+size, lng),
+				geojson.MakePosition(lat, lng),
+			}).Geometry}
+		}
+
+		// Geographic polygons are projected before the tiny one is removed.
+		data, err := mvt21.MarshalOptions{
+			Project: SimpleProject,
+			Layers:  mvt21.LayerConfigs{"parks": {Reduce: &mvt21.Reduction{MinArea: 1000}}},
+		}.Marshal(mvt21.Layers{"parks": mvt21.MakeLayer(4096, square(10, 10, 1e-4), square(10, 20, 40))})
+		require.NoError(t, err)
+
+		decoded, err := mvt21.UnmarshalOptions{TileCoordinates: true}.Unmarshal(data)
+		require.NoError(t, err)
+		require.Len(t, decoded["parks"].Features, 1)
+		require.Greater(t, mvt21.RingArea(decoded["parks"].Features[0].Geometry.(*mvt21.TileGeometry).Parts[0]), int64(1000))
+	})
+}
@@ -0,0 +1,109 @@
+package mvt_test
+
+import (
+	"errors"
+	"testing"
+
+	mvt21 "github.com/everystreet/go-mvt"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalInvalidFeatures(t *testing.T) {
+	data, err := proto.Marshal(&spec.Tile{
+		Layers: []*spec.Tile_Layer{
+			{
+				Version: proto.Uint32(2),
+				Name:    proto.String("my_layer"),
+				Keys:    []string{"key"},
+				Values:  []*spec.Tile_Value{newStringValue("value")},
+				Features: []*spec.Tile_Feature{
+					{
+						Id:       proto.Uint64(1),
+						Type:     spec.Tile_POINT.Enum(),
+						Tags:     []uint32{0, 0},
+						Geometry: []uint32{9, 0, 0},
+					},
+					{
+						Id:       proto.Uint64(2),
+						Type:     spec.Tile_POINT.Enum(),
+						Tags:     []uint32{0},
+						Geometry: []uint32{9, 0, 0},
+					},
+					{
+						Id:       proto.Uint64(3),
+						Type:     spec.Tile_POLYGON.Enum(),
+						Tags:     []uint32{0, 0},
+						Geometry: []uint32{9, 0, 0, 10, 2, 2},
+					},
+					{
+						Id:       proto.Uint64(1),
+						Type:     spec.Tile_POINT.Enum(),
+						Geometry: []uint32{9, 2, 2},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("fail", func(t *testing.T) {
+		layers, err := mvt21.Unmarshal(data, SimpleUnproject)
+		require.Error(t, err)
+		require.Nil(t, layers)
+
+		var problems mvt21.Problems
+		require.False(t, errors.As(err, &problems))
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		layers, err := mvt21.UnmarshalOptions{
+			Unproject:       SimpleUnproject,
+			InvalidFeatures: mvt21.DropInvalidFeatures,
+		}.Unmarshal(data)
+
+		var problems mvt21.Problems
+		require.True(t, errors.As(err, &problems))
+		require.Len(t, problems, 3)
+		require.Equal(t, 1, problems[0].Index)
+		require.Equal(t, 2, problems[1].Index)
+		require.Equal(t, 3, problems[2].Index)
+
+		require.Len(t, layers["my_layer"].Features, 1)
+		require.Equal(t, uint64(1), layers["my_layer"].Features[0].ID.Value())
+	})
+
+	t.Run("keep", func(t *testing.T) {
+		layers, err := mvt21.UnmarshalOptions{
+			Unproject:       SimpleUnproject,
+			InvalidFeatures: mvt21.KeepInvalidFeatures,
+		}.Unmarshal(data)
+
+		var problems mvt21.Problems
+		require.True(t, errors.As(err, &problems))
+		require.Len(t, problems, 3)
+
+		features := layers["my_layer"].Features
+		require.Len(t, features, 4)
+
+		require.Equal(t, uint64(2), features[1].ID.Value())
+		require.Empty(t, features[1].Tags)
+
+		require.Len(t, features[2].Tags, 1)
+		require.Equal(t, &mvt21.UnknownGeometry{RawShape: []uint32{9, 0, 0, 10, 2, 2}}, features[2].Geometry)
+
+		require.False(t, features[3].ID.IsSet())
+	})
+
+	t.Run("limits are fatal", func(t *testing.T) {
+		_, err := mvt21.UnmarshalOptions{
+			Unproject:       SimpleUnproject,
+			InvalidFeatures: mvt21.DropInvalidFeatures,
+			Limits:          mvt21.Limits{MaxVertices: 1},
+		}.Unmarshal(data)
+
+		var limitErr *mvt21.LimitError
+		require.True(t, errors.As(err, &limitErr))
+	})
+}
@@ -0,0 +1,140 @@
+package mvt
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/everystreet/go-geojson/v2"
+	"github.com/everystreet/go-mvt/internal/wire"
+)
+
+// Limits bounds the resources used to decode a tile.
+// A zero value for any field means that resource is not limited.
+type Limits struct {
+	// MaxLayers is the maximum number of layers in a tile.
+	MaxLayers int
+
+	// MaxFeatures is the maximum number of features in a single layer.
+	MaxFeatures int
+
+	// MaxVertices is the maximum number of vertices in a single geometry.
+	MaxVertices int
+
+	// MaxKeys is the maximum number of keys in a single layer.
+	MaxKeys int
+
+	// MaxValues is the maximum number of values in a single layer.
+	MaxValues int
+
+	// MaxAllocation is the approximate maximum number of bytes allocated for the decoded layers.
+	MaxAllocation int64
+}
+
+// LimitKind identifies a limit in Limits.
+type LimitKind uint8
+
+// Limit kinds.
+const (
+	LayersLimit LimitKind = iota
+	FeaturesLimit
+	VerticesLimit
+	KeysLimit
+	ValuesLimit
+	AllocationLimit
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case LayersLimit:
+		return "layers"
+	case FeaturesLimit:
+		return "features"
+	case VerticesLimit:
+		return "vertices"
+	case KeysLimit:
+		return "keys"
+	case ValuesLimit:
+		return "values"
+	case AllocationLimit:
+		return "allocation"
+	default:
+		return "unknown"
+	}
+}
+
+// LimitError is returned when decoding a tile would exceed one of the configured Limits.
+type LimitError struct {
+	Kind  LimitKind
+	Max   int64
+	Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v limit exceeded (%d > %d)", e.Kind, e.Value, e.Max)
+}
+
+// Approximate sizes of decoded values, used to account for allocations.
+const (
+	featureSize  = int64(unsafe.Sizeof(Feature{}))
+	propertySize = int64(unsafe.Sizeof(geojson.Property{}))
+	positionSize = int64(unsafe.Sizeof(geojson.Position{}))
+)
+
+// limiter enforces Limits during a single decode.
+// It may be shared by layers that are decoded concurrently.
+type limiter struct {
+	Limits
+	allocated atomic.Int64
+}
+
+// check returns a LimitError if n exceeds max.
+func (l *limiter) check(kind LimitKind, max, n int) error {
+	if max > 0 && n > max {
+		return &LimitError{
+			Kind:  kind,
+			Max:   int64(max),
+			Value: int64(n),
+		}
+	}
+	return nil
+}
+
+// alloc accounts for n bytes about to be allocated.
+func (l *limiter) alloc(n int64) error {
+	allocated := l.allocated.Add(n)
+	if l.MaxAllocation > 0 && allocated > l.MaxAllocation {
+		return &LimitError{
+			Kind:  AllocationLimit,
+			Max:   l.MaxAllocation,
+			Value: allocated,
+		}
+	}
+	return nil
+}
+
+// wire returns the limits that are enforced while a layer is decoded.
+func (l *limiter) wire() wire.Limits {
+	return wire.Limits{
+		Features: l.MaxFeatures,
+		Keys:     l.MaxKeys,
+		Values:   l.MaxValues,
+	}
+}
+
+// wireLimitError returns the LimitError for a limit exceeded while a layer was decoded.
+// Decoding stops at the first field over the limit, so the value is one more than the maximum.
+func wireLimitError(err *wire.LimitError) *LimitError {
+	kind := FeaturesLimit
+	switch err.Field {
+	case wire.KeysField:
+		kind = KeysLimit
+	case wire.ValuesField:
+		kind = ValuesLimit
+	}
+	return &LimitError{
+		Kind:  kind,
+		Max:   int64(err.Max),
+		Value: int64(err.Max) + 1,
+	}
+}
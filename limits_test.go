@@ -0,0 +1,126 @@
+package mvt_test
+
+import (
+	"errors"
+	"testing"
+
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/everystreet/go-mvt/internal/geometry"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalLimits(t *testing.T) {
+	name, version, typ := "layer1", uint32(2), spec.Tile_LINESTRING
+	data, err := proto.Marshal(&spec.Tile{
+		Layers: []*spec.Tile_Layer{
+			{
+				Version: &version,
+				Name:    &name,
+				Keys:    []string{"key1", "key2"},
+				Values:  []*spec.Tile_Value{newStringValue("value1"), newStringValue("value2")},
+				Features: []*spec.Tile_Feature{
+					{
+						Type:     &typ,
+						Tags:     []uint32{0, 0, 1, 1},
+						Geometry: []uint32{9, 0, 0, 26, 2, 2, 2, 2, 2, 2},
+					},
+					{
+						Type:     &typ,
+						Geometry: []uint32{9, 0, 0, 10, 2, 2},
+					},
+				},
+			},
+			newLayer("layer2", 2, 4096),
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = mvt21.UnmarshalOptions{
+		Unproject: SimpleUnproject,
+		Limits: mvt21.Limits{
+			MaxLayers:     2,
+			MaxFeatures:   2,
+			MaxVertices:   4,
+			MaxKeys:       2,
+			MaxValues:     2,
+			MaxAllocation: 1 << 20,
+		},
+	}.Unmarshal(data)
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		Name   string
+		Limits mvt21.Limits
+		Kind   mvt21.LimitKind
+	}{
+		{
+			Name:   "layers",
+			Limits: mvt21.Limits{MaxLayers: 1},
+			Kind:   mvt21.LayersLimit,
+		},
+		{
+			Name:   "features",
+			Limits: mvt21.Limits{MaxFeatures: 1},
+			Kind:   mvt21.FeaturesLimit,
+		},
+		{
+			Name:   "vertices",
+			Limits: mvt21.Limits{MaxVertices: 3},
+			Kind:   mvt21.VerticesLimit,
+		},
+		{
+			Name:   "keys",
+			Limits: mvt21.Limits{MaxKeys: 1},
+			Kind:   mvt21.KeysLimit,
+		},
+		{
+			Name:   "values",
+			Limits: mvt21.Limits{MaxValues: 1},
+			Kind:   mvt21.ValuesLimit,
+		},
+		{
+			Name:   "allocation",
+			Limits: mvt21.Limits{MaxAllocation: 64},
+			Kind:   mvt21.AllocationLimit,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := mvt21.UnmarshalOptions{
+				Unproject: SimpleUnproject,
+				Limits:    tt.Limits,
+			}.Unmarshal(data)
+
+			var limitErr *mvt21.LimitError
+			require.True(t, errors.As(err, &limitErr))
+			require.Equal(t, tt.Kind, limitErr.Kind)
+		})
+	}
+}
+
+func TestUnmarshalHugeCommandCount(t *testing.T) {
+	cmd, err := geometry.MakeCommandInteger(geometry.LineTo, 1<<29-1)
+	require.NoError(t, err)
+
+	name, version, typ := "layer1", uint32(2), spec.Tile_LINESTRING
+	data, err := proto.Marshal(&spec.Tile{
+		Layers: []*spec.Tile_Layer{
+			{
+				Version: &version,
+				Name:    &name,
+				Features: []*spec.Tile_Feature{
+					{
+						Type:     &typ,
+						Geometry: []uint32{9, 0, 0, uint32(cmd), 2, 2},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = mvt21.Unmarshal(data, SimpleUnproject)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expects")
+}
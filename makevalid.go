@@ -0,0 +1,636 @@
+package mvt
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/everystreet/go-geojson/v2"
+	"github.com/everystreet/go-mvt/internal/geometry"
+	"github.com/golang/geo/r2"
+)
+
+// maxNodingIterations bounds the number of times that segments are split at their intersections,
+// as snapping an intersection to the integer grid can create new ones.
+const maxNodingIterations = 8
+
+// maxRepairCoordinate bounds the coordinates of polygons that are repaired, so that the arithmetic is exact.
+const maxRepairCoordinate = 1 << 29
+
+// MakeValid returns the geometry repaired so that it passes ValidateTile, or nil if nothing remains.
+//
+// Repeated points are removed from lines and rings, and lines with fewer than 2 points are removed.
+// Polygons are filled where exterior rings wind more than holes, so overlapping polygons are merged,
+// and the parts of a self-intersecting ring that wind backwards are removed.
+// Rings are split where they intersect or touch, with intersections snapped to the integer grid,
+// and rebuilt as simple rings with exterior rings clockwise, each followed by the holes inside it.
+// Rings that remain invalid are removed, with the holes of an exterior ring.
+// Polygons with more points than the validator checks for self-intersection are not rebuilt.
+func (g TileGeometry) MakeValid() *TileGeometry {
+	out := TileGeometry{GeomType: g.GeomType}
+	switch g.GeomType {
+	case TilePointType:
+		for _, points := range g.Parts {
+			if len(points) > 0 {
+				out.Parts = append(out.Parts, points)
+			}
+		}
+	case TileLineStringType:
+		for _, line := range g.Parts {
+			if line = withoutRepeats(line, false); len(line) >= 2 {
+				out.Parts = append(out.Parts, line)
+			}
+		}
+	case TilePolygonType:
+		out.Parts = validRings(g.Parts, false)
+	}
+
+	if len(out.Parts) == 0 {
+		return nil
+	}
+	return &out
+}
+
+// makeValid returns the layers with every geometry repaired by TileGeometry.MakeValid,
+// once geographic geometries are projected and truncated to tile coordinates. Features left without geometry are removed.
+func makeValid(layers Layers, project func(LayerName) geometry.Project) (Layers, error) {
+	out := make(Layers, len(layers))
+	for name, layer := range layers {
+		features := make([]Feature, 0, len(layer.Features))
+		for i, f := range layer.Features {
+			g, ok := f.Geometry.(*TileGeometry)
+			if !ok {
+				var err error
+				if g, err = projectTileGeometry(f.Geometry, project(name)); err != nil {
+					return nil, &FeatureError{Layer: name, Index: i, ID: f.ID, Offset: -1, Err: err}
+				} else if g == nil {
+					features = append(features, f)
+					continue
+				}
+			}
+
+			if g = g.MakeValid(); g == nil {
+				continue
+			}
+			f.Geometry = g
+			features = append(features, f)
+		}
+		out[name] = MakeLayer(layer.Extent, features...)
+	}
+	return out, nil
+}
+
+// projectTileGeometry returns the geographic geometry in tile coordinates, as it would be encoded.
+// Exterior rings are wound clockwise and holes anticlockwise. It returns nil if the geometry is not geographic.
+func projectTileGeometry(geo geojson.Geometry, project geometry.Project) (*TileGeometry, error) {
+	var g TileGeometry
+	var positions [][]geojson.Position
+	var polygons [][][]geojson.Position
+	switch v := geo.(type) {
+	case *geojson.Point:
+		g.GeomType, positions = TilePointType, [][]geojson.Position{{geojson.Position(*v)}}
+	case *geojson.MultiPoint:
+		g.GeomType, positions = TilePointType, [][]geojson.Position{*v}
+	case *geojson.LineString:
+		g.GeomType, positions = TileLineStringType, [][]geojson.Position{*v}
+	case *geojson.MultiLineString:
+		g.GeomType = TileLineStringType
+		for _, line := range *v {
+			positions = append(positions, line)
+		}
+	case *geojson.Polygon:
+		g.GeomType, polygons = TilePolygonType, [][][]geojson.Position{*v}
+	case *geojson.MultiPolygon:
+		g.GeomType = TilePolygonType
+		for _, polygon := range *v {
+			polygons = append(polygons, polygon)
+		}
+	default:
+		return nil, nil
+	}
+
+	if project == nil {
+		return nil, fmt.Errorf("missing projection")
+	}
+
+	points := func(positions []geojson.Position) ([]TilePoint, error) {
+		out := make([]TilePoint, len(positions))
+		for i, pos := range positions {
+			p := project(pos.LatLng)
+			x, y := math.Trunc(p.X), math.Trunc(p.Y)
+			if !(math.Abs(x) <= math.MaxInt32 && math.Abs(y) <= math.MaxInt32) {
+				return nil, fmt.Errorf("point (%g, %g) is outside the range of tile coordinates", x, y)
+			}
+			out[i] = TilePoint{X: int32(x), Y: int32(y)}
+		}
+		return out, nil
+	}
+
+	for _, part := range positions {
+		p, err := points(part)
+		if err != nil {
+			return nil, err
+		}
+		g.Parts = append(g.Parts, p)
+	}
+
+	for _, polygon := range polygons {
+		for i, loop := range polygon {
+			if n := len(loop); n > 1 && loop[0] == loop[n-1] {
+				loop = loop[:n-1]
+			}
+
+			ring, err := points(loop)
+			if err != nil {
+				return nil, err
+			}
+			if area := RingArea(ring); (i == 0 && area < 0) || (i > 0 && area > 0) {
+				slices.Reverse(ring)
+			}
+			g.Parts = append(g.Parts, ring)
+		}
+	}
+	return &g, nil
+}
+
+// withoutRepeats returns the points with each point that repeats the one before it removed.
+// If closed, a last point that repeats the first is also removed.
+func withoutRepeats(points []TilePoint, closed bool) []TilePoint {
+	out := make([]TilePoint, 0, len(points))
+	for _, p := range points {
+		if len(out) == 0 || out[len(out)-1] != p {
+			out = append(out, p)
+		}
+	}
+	for closed && len(out) > 1 && out[len(out)-1] == out[0] {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// validRings returns the rings of a polygon geometry repaired, as described by TileGeometry.MakeValid.
+// If dissolve is true, rings are rebuilt even if they are valid, so polygons that share edges are merged,
+// however many points they have. Rings that aren't repairable are never rebuilt.
+func validRings(rings [][]TilePoint, dissolve bool) [][]TilePoint {
+	cleaned := make([][]TilePoint, 0, len(rings))
+	var n int
+	for _, ring := range rings {
+		if ring = withoutRepeats(ring, true); len(ring) >= 3 && RingArea(ring) != 0 {
+			cleaned = append(cleaned, ring)
+			n += len(ring)
+		}
+	}
+
+	if !repairable(rings) || (!dissolve && n > maxSelfIntersectionVertices) {
+		return filterRings(cleaned)
+	}
+
+	var segments []segment
+	for _, ring := range cleaned {
+		for i, p := range ring {
+			segments = append(segments, segment{p, ring[(i+1)%len(ring)]})
+		}
+	}
+	if !dissolve && validPolygons(cleaned, segments) {
+		return cleaned
+	}
+	return filterRings(nestRings(traceRings(boundary(nodeSegments(segments)))))
+}
+
+// repairable returns true if the coordinates of the rings are small enough for them to be rebuilt exactly.
+func repairable(rings [][]TilePoint) bool {
+	for _, ring := range rings {
+		for _, p := range ring {
+			if abs32(p.X) > maxRepairCoordinate || abs32(p.Y) > maxRepairCoordinate {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validRing returns true if the implicitly closed ring is valid in a tile.
+// A ring is valid if it has at least 3 points, no zero-length or self-intersecting edges, and a non-zero area.
+// The first ring of a polygon geometry must also be an exterior ring.
+func validRing(ring []TilePoint, first bool) bool {
+	if len(ring) < 3 || !distinctPoints(ring, true) {
+		return false
+	} else if area := RingArea(ring); area == 0 || (first && area < 0) {
+		return false
+	} else if len(ring) > maxSelfIntersectionVertices {
+		return true
+	}
+
+	points := make([]r2.Point, len(ring))
+	for i, p := range ring {
+		points[i] = r2.Point{X: float64(p.X), Y: float64(p.Y)}
+	}
+	return !geometry.SelfIntersects(points)
+}
+
+// validPolygons returns true if the rings, whose edges are the segments, are valid and need no repair.
+// Each ring must be valid, no ring may cross or touch an edge of another, exterior rings must be outside each other,
+// and each hole must be inside the exterior ring before it, and no other.
+func validPolygons(rings [][]TilePoint, segments []segment) bool {
+	if len(rings) == 0 || RingArea(rings[0]) < 0 {
+		return false
+	}
+	for _, ring := range rings {
+		if !validRing(ring, false) {
+			return false
+		}
+	}
+	if len(rings) == 1 {
+		return true
+	} else if len(splitPoints(segments)) > 0 {
+		return false
+	}
+
+	edges := make([]windingEdge, len(segments))
+	for i, s := range segments {
+		edges[i] = windingEdge{s, 1}
+	}
+
+	var start int
+	var exterior []TilePoint
+	for _, ring := range rings {
+		// The winding number of the other rings is found at the midpoint of the first edge of the ring.
+		mx, my := int64(ring[0].X)+int64(ring[1].X), int64(ring[0].Y)+int64(ring[1].Y)
+		others := slices.Concat(edges[:start], edges[start+len(ring):])
+		start += len(ring)
+
+		w := winding(others, -1, mx, my, false)
+		if RingArea(ring) > 0 {
+			if w != 0 {
+				return false
+			}
+			exterior = ring
+		} else if w != 1 || !insideRing(exterior, mx, my) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterRings returns the valid rings, removing the holes of invalid exterior rings.
+func filterRings(rings [][]TilePoint) [][]TilePoint {
+	var out [][]TilePoint
+	exterior := false
+	for _, ring := range rings {
+		valid := validRing(ring, false)
+		if RingArea(ring) > 0 {
+			exterior = valid
+		}
+		if exterior && valid {
+			out = append(out, ring)
+		}
+	}
+	return out
+}
+
+// segment is a directed edge of a ring.
+type segment struct {
+	a, b TilePoint
+}
+
+func (s segment) bounds() (minX, minY, maxX, maxY int32) {
+	return min(s.a.X, s.b.X), min(s.a.Y, s.b.Y), max(s.a.X, s.b.X), max(s.a.Y, s.b.Y)
+}
+
+// nodeSegments splits the segments wherever they cross or touch, so that they meet only at their end points.
+// Crossings are snapped to the nearest point of the integer grid, which moves the segments slightly,
+// so segments are split again until none cross, or maxNodingIterations is reached.
+func nodeSegments(segments []segment) []segment {
+	for iter := 0; iter < maxNodingIterations; iter++ {
+		splits := splitPoints(segments)
+		if len(splits) == 0 {
+			break
+		}
+
+		out := make([]segment, 0, len(segments)+2*len(splits))
+		for i, s := range segments {
+			points, ok := splits[i]
+			if !ok {
+				out = append(out, s)
+				continue
+			}
+
+			// Split points are ordered along the segment.
+			dx, dy := int64(s.b.X)-int64(s.a.X), int64(s.b.Y)-int64(s.a.Y)
+			along := func(p TilePoint) int64 {
+				return (int64(p.X)-int64(s.a.X))*dx + (int64(p.Y)-int64(s.a.Y))*dy
+			}
+			slices.SortFunc(points, func(p, q TilePoint) int { return cmp.Compare(along(p), along(q)) })
+
+			prev := s.a
+			for _, p := range append(points, s.b) {
+				if p != prev {
+					out = append(out, segment{prev, p})
+					prev = p
+				}
+			}
+		}
+		segments = out
+	}
+	return segments
+}
+
+// splitPoints returns the points at which each segment crosses or touches another, by the index of the segment.
+func splitPoints(segments []segment) map[int][]TilePoint {
+	order := make([]int, len(segments))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(i, j int) int {
+		mi, _, _, _ := segments[i].bounds()
+		mj, _, _, _ := segments[j].bounds()
+		return cmp.Compare(mi, mj)
+	})
+
+	splits := make(map[int][]TilePoint)
+	split := func(i int, p TilePoint) {
+		if s := segments[i]; p != s.a && p != s.b {
+			splits[i] = append(splits[i], p)
+		}
+	}
+
+	for k, i := range order {
+		s := segments[i]
+		_, minY, maxX, maxY := s.bounds()
+		for _, j := range order[k+1:] {
+			t := segments[j]
+			tMinX, tMinY, _, tMaxY := t.bounds()
+			if tMinX > maxX {
+				break
+			} else if tMinY > maxY || tMaxY < minY {
+				continue
+			}
+
+			for _, p := range []TilePoint{t.a, t.b} {
+				if onSegment(s, p) {
+					split(i, p)
+				}
+			}
+			for _, p := range []TilePoint{s.a, s.b} {
+				if onSegment(t, p) {
+					split(j, p)
+				}
+			}
+			if p, ok := crossing(s, t); ok {
+				split(i, p)
+				split(j, p)
+			}
+		}
+	}
+
+	return splits
+}
+
+// orient returns the cross product of a->b and a->c, which is positive if c is to the left of a->b.
+func orient(a, b, c TilePoint) int64 {
+	return (int64(b.X)-int64(a.X))*(int64(c.Y)-int64(a.Y)) - (int64(b.Y)-int64(a.Y))*(int64(c.X)-int64(a.X))
+}
+
+// onSegment returns true if p lies on the segment, other than at its end points.
+func onSegment(s segment, p TilePoint) bool {
+	if p == s.a || p == s.b || orient(s.a, s.b, p) != 0 {
+		return false
+	}
+	minX, minY, maxX, maxY := s.bounds()
+	return p.X >= minX && p.X <= maxX && p.Y >= minY && p.Y <= maxY
+}
+
+// crossing returns the point where the segments cross, other than at their end points, snapped to the integer grid.
+func crossing(s, t segment) (TilePoint, bool) {
+	d1, d2 := orient(s.a, s.b, t.a), orient(s.a, s.b, t.b)
+	d3, d4 := orient(t.a, t.b, s.a), orient(t.a, t.b, s.b)
+	if !((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) || !((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return TilePoint{}, false
+	}
+
+	f := float64(d3) / float64(d3-d4)
+	return TilePoint{
+		X: int32(math.Round(float64(s.a.X) + f*float64(int64(s.b.X)-int64(s.a.X)))),
+		Y: int32(math.Round(float64(s.a.Y) + f*float64(int64(s.b.Y)-int64(s.a.Y)))),
+	}, true
+}
+
+// windingEdge is an undirected edge, from the lesser point to the greater, with the net number of times rings run along it.
+type windingEdge struct {
+	segment
+	count int
+}
+
+// boundary returns the edges between filled and unfilled regions, directed with the filled region on their left,
+// which is inside clockwise rings as the Y axis points down. A region is filled if its winding number is positive.
+func boundary(segments []segment) []segment {
+	counts := make(map[segment]int)
+	for _, s := range segments {
+		if s.a == s.b {
+			continue
+		} else if comparePoint(s.a, s.b) < 0 {
+			counts[s]++
+		} else {
+			counts[segment{s.b, s.a}]--
+		}
+	}
+
+	edges := make([]windingEdge, 0, len(counts))
+	for s, n := range counts {
+		if n != 0 {
+			edges = append(edges, windingEdge{s, n})
+		}
+	}
+	slices.SortFunc(edges, func(a, b windingEdge) int {
+		if c := comparePoint(a.a, b.a); c != 0 {
+			return c
+		}
+		return comparePoint(a.b, b.b)
+	})
+
+	var out []segment
+	for i, e := range edges {
+		// The winding number either side of the edge is found at its midpoint, in doubled coordinates.
+		mx, my := int64(e.a.X)+int64(e.b.X), int64(e.a.Y)+int64(e.b.Y)
+		dx, dy := e.b.X-e.a.X, e.b.Y-e.a.Y
+
+		var left, right int
+		if dy != 0 {
+			// Rays are cast along X. The side with greater X doesn't cross the edge, and is on the left if the edge runs up.
+			greater := winding(edges, i, mx, my, false)
+			lesser := greater + e.count*sign(dy)
+			left, right = lesser, greater
+			if dy < 0 {
+				left, right = greater, lesser
+			}
+		} else {
+			// Rays are cast along Y, which reflects the plane and so negates the winding numbers.
+			greater := winding(edges, i, my, mx, true)
+			lesser := greater + e.count*sign(dx)
+			left, right = -lesser, -greater
+			if dx > 0 {
+				left, right = -greater, -lesser
+			}
+		}
+
+		switch {
+		case left > 0 && right <= 0:
+			out = append(out, e.segment)
+		case right > 0 && left <= 0:
+			out = append(out, segment{e.b, e.a})
+		}
+	}
+	return out
+}
+
+// winding returns the winding number of the edges, other than the skipped one, around the point in doubled coordinates,
+// by casting a ray along X. If swap is true, X and Y are swapped.
+func winding(edges []windingEdge, skip int, px, py int64, swap bool) int {
+	var w int
+	for i, e := range edges {
+		if i == skip {
+			continue
+		}
+
+		ax, ay, bx, by := 2*int64(e.a.X), 2*int64(e.a.Y), 2*int64(e.b.X), 2*int64(e.b.Y)
+		if swap {
+			ax, ay, bx, by = ay, ax, by, bx
+		}
+
+		side := (bx-ax)*(py-ay) - (px-ax)*(by-ay)
+		if ay <= py {
+			if by > py && side > 0 {
+				w += e.count
+			}
+		} else if by <= py && side < 0 {
+			w -= e.count
+		}
+	}
+	return w
+}
+
+// traceRings joins the directed edges into rings, which are split wherever they would visit a point twice.
+func traceRings(edges []segment) [][]TilePoint {
+	outgoing := make(map[TilePoint][]TilePoint)
+	for _, e := range edges {
+		outgoing[e.a] = append(outgoing[e.a], e.b)
+	}
+	next := func(p TilePoint) (TilePoint, bool) {
+		if out := outgoing[p]; len(out) > 0 {
+			outgoing[p] = out[1:]
+			return out[0], true
+		}
+		return TilePoint{}, false
+	}
+
+	var rings [][]TilePoint
+	for _, e := range edges {
+		if len(outgoing[e.a]) == 0 {
+			continue
+		}
+
+		path := []TilePoint{e.a}
+		index := map[TilePoint]int{e.a: 0}
+		for {
+			p, ok := next(path[len(path)-1])
+			if !ok {
+				break
+			}
+
+			i, visited := index[p]
+			if !visited {
+				index[p] = len(path)
+				path = append(path, p)
+				continue
+			}
+
+			rings = append(rings, withoutCollinear(slices.Clone(path[i:])))
+			for _, q := range path[i+1:] {
+				delete(index, q)
+			}
+			path = path[:i+1]
+		}
+	}
+	return rings
+}
+
+// withoutCollinear returns the implicitly closed ring with points that lie on a straight line between their neighbours removed.
+func withoutCollinear(ring []TilePoint) []TilePoint {
+	for removed := true; removed && len(ring) >= 3; {
+		removed = false
+		for i := 0; i < len(ring) && len(ring) >= 3; i++ {
+			prev, p, next := ring[(i+len(ring)-1)%len(ring)], ring[i], ring[(i+1)%len(ring)]
+			if orient(prev, p, next) == 0 {
+				ring = slices.Delete(ring, i, i+1)
+				removed = true
+				i--
+			}
+		}
+	}
+	return ring
+}
+
+// nestRings returns the exterior rings, largest first, each followed by the holes inside it.
+// Holes that are not inside an exterior ring are removed.
+func nestRings(rings [][]TilePoint) [][]TilePoint {
+	var exteriors, holes [][]TilePoint
+	for _, ring := range rings {
+		if len(ring) < 3 {
+			continue
+		} else if RingArea(ring) > 0 {
+			exteriors = append(exteriors, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+	slices.SortStableFunc(exteriors, func(a, b []TilePoint) int {
+		return cmp.Compare(RingArea(b), RingArea(a))
+	})
+
+	// Each hole belongs to the smallest exterior ring around the midpoint of its first edge.
+	nested := make([][][]TilePoint, len(exteriors))
+	for _, hole := range holes {
+		mx, my := int64(hole[0].X)+int64(hole[1].X), int64(hole[0].Y)+int64(hole[1].Y)
+		owner := -1
+		for i, ring := range exteriors {
+			if insideRing(ring, mx, my) && (owner < 0 || RingArea(ring) < RingArea(exteriors[owner])) {
+				owner = i
+			}
+		}
+		if owner >= 0 {
+			nested[owner] = append(nested[owner], hole)
+		}
+	}
+
+	var out [][]TilePoint
+	for i, ring := range exteriors {
+		out = append(out, ring)
+		out = append(out, nested[i]...)
+	}
+	return out
+}
+
+// insideRing returns true if the point, in doubled coordinates, is inside the ring.
+func insideRing(ring []TilePoint, px, py int64) bool {
+	edges := make([]windingEdge, len(ring))
+	for i, p := range ring {
+		edges[i] = windingEdge{segment{p, ring[(i+1)%len(ring)]}, 1}
+	}
+	return winding(edges, -1, px, py, false) != 0
+}
+
+func sign(v int32) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func abs32(v int32) int64 {
+	return max(int64(v), -int64(v))
+}
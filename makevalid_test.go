@@ -0,0 +1,174 @@
+package mvt_test
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
+	"github.com/stretchr/testify/require"
+)
+
+func requireValidTile(t *testing.T, layers mvt21.Layers) {
+	t.Helper()
+	data, err := mvt21.MarshalOptions{MakeValid: true}.Marshal(layers)
+	require.NoError(t, err)
+
+	report, err := mvt21.ValidateTile(data)
+	require.NoError(t, err)
+	require.True(t, report.Valid(), report)
+}
+
+func TestMakeValid(t *testing.T) {
+	polygon := func(rings ...[]mvt21.TilePoint) mvt21.TileGeometry {
+		return mvt21.TileGeometry{GeomType: mvt21.TilePolygonType, Parts: rings}
+	}
+	totalArea := func(g *mvt21.TileGeometry) (area int64) {
+		for _, ring := range g.Parts {
+			area += mvt21.RingArea(ring)
+		}
+		return area / 2
+	}
+
+	for name, tt := range map[string]struct {
+		geometry mvt21.TileGeometry
+		area     int64
+		rings    int
+	}{
+		"valid": {
+			geometry: polygon([]mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}),
+			area:     100,
+			rings:    1,
+		},
+		"repeated points": {
+			geometry: polygon([]mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}, {X: 0, Y: 0}}),
+			area:     100,
+			rings:    1,
+		},
+		"bow tie": {
+			// The lobes wind in opposite directions, so the larger is kept, with the crossing snapped to (13, 7).
+			geometry: polygon([]mvt21.TilePoint{{X: 0, Y: 0}, {X: 20, Y: 10}, {X: 20, Y: 0}, {X: 0, Y: 20}}),
+			area:     130,
+			rings:    1,
+		},
+		"figure of eight": {
+			// The lobes wind the same way, and touch at a point, so they are split into two rings.
+			geometry: polygon([]mvt21.TilePoint{
+				{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 20, Y: 10}, {X: 20, Y: 20}, {X: 10, Y: 20}, {X: 10, Y: 10}, {X: 0, Y: 10},
+			}),
+			area:  200,
+			rings: 2,
+		},
+		"hole touching exterior": {
+			geometry: polygon(
+				[]mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+				[]mvt21.TilePoint{{X: 0, Y: 0}, {X: 5, Y: 8}, {X: 8, Y: 5}},
+			),
+			area:  (200 - 39) / 2,
+			rings: 2,
+		},
+		"hole crossing exterior": {
+			geometry: polygon(
+				[]mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+				[]mvt21.TilePoint{{X: 5, Y: 5}, {X: 5, Y: 15}, {X: 15, Y: 15}, {X: 15, Y: 5}},
+			),
+			area:  75,
+			rings: 1,
+		},
+		"overlapping exteriors": {
+			geometry: polygon(
+				[]mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+				[]mvt21.TilePoint{{X: 5, Y: 5}, {X: 15, Y: 5}, {X: 15, Y: 15}, {X: 5, Y: 15}},
+			),
+			area:  175,
+			rings: 1,
+		},
+		"hole before exterior": {
+			geometry: polygon(
+				[]mvt21.TilePoint{{X: 2, Y: 2}, {X: 2, Y: 8}, {X: 8, Y: 8}, {X: 8, Y: 2}},
+				[]mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+			),
+			area:  64,
+			rings: 2,
+		},
+		"spike": {
+			geometry: polygon([]mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 20, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}),
+			area:     100,
+			rings:    1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := tt.geometry.MakeValid()
+			require.NotNil(t, g)
+			require.NoError(t, g.Validate())
+			require.Len(t, g.Parts, tt.rings)
+			require.Greater(t, mvt21.RingArea(g.Parts[0]), int64(0))
+			require.Equal(t, tt.area, totalArea(g))
+
+			requireValidTile(t, mvt21.Layers{"polygons": mvt21.MakeLayer(4096, mvt21.Feature{Geometry: &tt.geometry})})
+		})
+	}
+
+	t.Run("collapsed", func(t *testing.T) {
+		require.Nil(t, polygon([]mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 20, Y: 0}}).MakeValid())
+		require.Nil(t, mvt21.TileGeometry{
+			GeomType: mvt21.TileLineStringType,
+			Parts:    [][]mvt21.TilePoint{{{X: 1, Y: 1}, {X: 1, Y: 1}}},
+		}.MakeValid())
+	})
+}
+
+func TestMarshalMakeValid(t *testing.T) {
+	// Random polygons, quantised to a coarse grid, are full of intersections.
+	r := rand.New(rand.NewSource(1))
+	var features []mvt21.Feature
+	for i := 0; i < 200; i++ {
+		var rings [][]mvt21.TilePoint
+		for j := 0; j < 1+r.Intn(3); j++ {
+			ring := make([]mvt21.TilePoint, 3+r.Intn(10))
+			for k := range ring {
+				ring[k] = mvt21.TilePoint{X: r.Int31n(16), Y: r.Int31n(16)}
+			}
+			rings = append(rings, ring)
+		}
+		features = append(features, mvt21.Feature{
+			Geometry: &mvt21.TileGeometry{GeomType: mvt21.TilePolygonType, Parts: rings},
+		})
+	}
+	requireValidTile(t, mvt21.Layers{"random": mvt21.MakeLayer(16, features...)})
+
+	// A thin geographic polygon folds over itself once rounded.
+	project := func(ll s2.LatLng) r2.Point {
+		return r2.Point{X: math.Round(ll.Lng.Degrees()), Y: math.Round(-ll.Lat.Degrees())}
+	}
+	layers := mvt21.Layers{"polygons": mvt21.MakeLayer(4096, mvt21.Feature{
+		Geometry: geojson.NewPolygon([]geojson.Position{
+			geojson.MakePosition(0, 0),
+			geojson.MakePosition(0.4, 10),
+			geojson.MakePosition(0.6, 20),
+			geojson.MakePosition(0.45, 20.4),
+			geojson.MakePosition(0.1, 10),
+			geojson.MakePosition(0, 0),
+		}).Geometry,
+	})}
+
+	data, err := mvt21.MarshalOptions{Project: project}.Marshal(layers)
+	require.NoError(t, err)
+	report, err := mvt21.ValidateTile(data)
+	require.NoError(t, err)
+	require.False(t, report.Valid())
+
+	data, err = mvt21.MarshalOptions{Project: project, MakeValid: true}.Marshal(layers)
+	require.NoError(t, err)
+	report, err = mvt21.ValidateTile(data)
+	require.NoError(t, err)
+	require.True(t, report.Valid(), report)
+
+	_, err = mvt21.MarshalOptions{MakeValid: true}.Marshal(layers)
+	var featureErr *mvt21.FeatureError
+	require.True(t, errors.As(err, &featureErr))
+}
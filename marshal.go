@@ -1,105 +1,442 @@
 package mvt
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
 
 	"github.com/everystreet/go-geojson/v2"
 	"github.com/everystreet/go-mvt/internal/geometry"
 	spec "github.com/everystreet/go-mvt/internal/spec"
-	"github.com/golang/protobuf/proto"
+	"github.com/everystreet/go-mvt/internal/wire"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
 )
 
 // Project a geographic coordinate to a projected CRS.
 type Project geometry.Project
 
+// MarshalOptions configures how layers are encoded.
+type MarshalOptions struct {
+	// Project converts geographic coordinates to tile coordinates.
+	Project Project
+
+	// Round is applied to each projected coordinate before it is encoded.
+	// If nil, coordinates are truncated towards zero.
+	Round func(float64) float64
+
+	// Validate the layers before encoding them.
+	Validate bool
+
+	// Compression applied to the encoded tile.
+	Compression Compression
+
+	// Concurrency is the maximum number of layers encoded at once.
+	// If zero or one, layers are encoded one at a time. If negative, GOMAXPROCS is used.
+	// Layers are written in name order regardless of concurrency.
+	Concurrency int
+
+	// Layers configures each layer for the zoom of the tile, as described by LayerConfigs.Layers.
+	// Geographic geometries of layers that are simplified, reduced or coalesced are projected and rounded
+	// to tile coordinates first, and encoded as tile geometries. Other geographic geometries are projected with Project,
+	// and then scaled from the extent of the layer to the configured extent.
+	Layers LayerConfigs
+
+	// Zoom of the tile, which selects the configuration of each layer.
+	Zoom uint32
+
+	// MakeValid repairs every geometry, after Layers is applied, so that the tile passes ValidateTile.
+	// Geographic geometries are projected and rounded to tile coordinates first, and encoded as tile geometries.
+	// Features left without geometry are removed. See TileGeometry.MakeValid.
+	MakeValid bool
+
+	// Labels adds a layer of label points for the polygons of each configured layer, after Layers and MakeValid are applied.
+	Labels LabelLayers
+}
+
 // Marshal returns the mvt encoding of the supplied layers.
 func Marshal(layers Layers, project Project) ([]byte, error) {
-	tile := spec.Tile{
-		Layers: make([]*spec.Tile_Layer, len(layers)),
+	return MarshalOptions{Project: project}.Marshal(layers)
+}
+
+// Marshal returns the mvt encoding of the supplied layers.
+func (o MarshalOptions) Marshal(layers Layers) ([]byte, error) {
+	return o.MarshalContext(context.Background(), layers)
+}
+
+// MarshalContext returns the mvt encoding of the supplied layers.
+// Encoding stops between features if ctx is done, and ctx.Err() is returned.
+func (o MarshalOptions) MarshalContext(ctx context.Context, layers Layers) ([]byte, error) {
+	data, err := o.marshalAppend(ctx, nil, layers)
+	if err != nil || o.Compression == NoCompression {
+		return data, err
 	}
 
-	var i int
-	for name, data := range layers {
-		layer, err := marshalLayer(data, string(name), geometry.Project(project))
-		if err != nil {
+	var buf bytes.Buffer
+	var c compressor
+	if err := c.compress(&buf, data, o.Compression); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalAppend appends the uncompressed mvt encoding of the supplied layers to b.
+func (o MarshalOptions) marshalAppend(ctx context.Context, b []byte, layers Layers) ([]byte, error) {
+	if o.Validate {
+		if err := layers.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Geographic geometries are scaled to the extent of each configured layer.
+	var scales map[LayerName]float64
+	if o.Layers != nil {
+		if err := o.Layers.Validate(); err != nil {
+			return nil, err
+		}
+
+		for name, layer := range layers {
+			if cfg, ok := o.Layers[name]; ok && layer.Extent != 0 {
+				if extent := cfg.Extent(o.Zoom, layer.Extent); extent != layer.Extent {
+					if scales == nil {
+						scales = make(map[LayerName]float64)
+					}
+					scales[name] = float64(extent) / float64(layer.Extent)
+				}
+			}
+		}
+
+		// Only tile geometries are simplified, reduced and merged, so geographic geometries are projected first.
+		var err error
+		if layers, err = o.projectConfigured(layers); err != nil {
 			return nil, err
 		}
+		layers = o.Layers.Layers(layers, o.Zoom)
+	}
+
+	project := o.project(1)
+	layerProject := func(name LayerName) geometry.Project {
+		if scale, ok := scales[name]; ok {
+			return o.project(scale)
+		}
+		return project
+	}
+
+	if o.MakeValid {
+		var err error
+		if layers, err = makeValid(layers, layerProject); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Labels != nil {
+		if err := o.Labels.Validate(); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if layers, err = o.Labels.layers(layers, layerProject); err != nil {
+			return nil, err
+		}
+	}
 
-		tile.Layers[i] = layer
-		i++
+	names := layers.names()
+
+	w := workers(o.Concurrency, len(names))
+	if w == 1 {
+		e := newLayerEncoder(ctx, project)
+		defer e.release()
+
+		for _, name := range names {
+			var err error
+			e.geo.Project = layerProject(name)
+			if b, err = e.marshalLayer(b, layers[name], name); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
 	}
 
-	return proto.Marshal(&tile)
+	encoders := make([]*layerEncoder, w)
+	for i := range encoders {
+		encoders[i] = newLayerEncoder(ctx, project)
+		defer encoders[i].release()
+	}
+
+	encoded := make([][]byte, len(names))
+	if err := forEachLayer(ctx, len(names), w, func(ctx context.Context, worker, i int) error {
+		var err error
+		encoders[worker].ctx = ctx
+		encoders[worker].geo.Project = layerProject(names[i])
+		encoded[i], err = encoders[worker].marshalLayer(nil, layers[names[i]], names[i])
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, layer := range encoded {
+		b = append(b, layer...)
+	}
+	return b, nil
 }
 
-func marshalLayer(data Layer, name string, project geometry.Project) (*spec.Tile_Layer, error) {
-	var version uint32 = 2
-	layer := spec.Tile_Layer{
-		Version: &version,
-		Name:    &name,
-		Extent:  &data.Extent,
+// projectConfigured returns the layers with the geographic geometries of each layer that is visible at the zoom,
+// and configured to be simplified, reduced or coalesced, projected and rounded to tile coordinates.
+// The supplied layers are not modified.
+func (o MarshalOptions) projectConfigured(layers Layers) (Layers, error) {
+	project := o.project(1)
+
+	var out Layers
+	for name, layer := range layers {
+		cfg, ok := o.Layers[name]
+		if !ok || !cfg.Visible(o.Zoom) || (cfg.Simplify == nil && cfg.Reduce == nil && cfg.Coalesce == nil) {
+			continue
+		}
+
+		var features []Feature
+		for i, f := range layer.Features {
+			g, err := projectTileGeometry(f.Geometry, project)
+			if err != nil {
+				return nil, &FeatureError{Layer: name, Index: i, ID: f.ID, Offset: -1, Err: err}
+			} else if g == nil {
+				continue
+			}
+
+			if features == nil {
+				features = slices.Clone(layer.Features)
+			}
+			features[i].Geometry = g
+		}
+
+		if features != nil {
+			if out == nil {
+				out = maps.Clone(layers)
+			}
+			out[name] = MakeLayer(layer.Extent, features...)
+		}
+	}
+
+	if out == nil {
+		return layers, nil
+	}
+	return out, nil
+}
+
+// project returns the projection, with coordinates scaled by the factor before they are rounded.
+func (o MarshalOptions) project(scale float64) geometry.Project {
+	if o.Project == nil || (o.Round == nil && scale == 1) {
+		return geometry.Project(o.Project)
+	}
+
+	round := o.Round
+	if round == nil {
+		round = func(v float64) float64 { return v }
+	}
+
+	return func(ll s2.LatLng) r2.Point {
+		p := o.Project(ll)
+		return r2.Point{
+			X: round(p.X * scale),
+			Y: round(p.Y * scale),
+		}
 	}
+}
+
+// maxPooledInts is the largest buffer, in integers, that is kept for reuse by a layerEncoder.
+const maxPooledInts = 1 << 20
+
+// layerEncoder holds the state used to encode layers.
+// Its buffers are reused between layers, and encoders are pooled between calls to Marshal.
+type layerEncoder struct {
+	ctx   context.Context
+	geo   geometry.Encoder
+	layer wire.Layer
+
+	// ints holds the tags of every feature in the layer, followed by the geometry of each feature.
+	ints []uint32
+	// ends holds the end of the geometry of each feature in ints.
+	ends []int
+
+	ids    map[uint64]struct{}
+	keys   map[string]int
+	values map[interface{}]int
+}
+
+var layerEncoders = sync.Pool{
+	New: func() interface{} {
+		return &layerEncoder{
+			ids:    make(map[uint64]struct{}),
+			keys:   make(map[string]int),
+			values: make(map[interface{}]int),
+		}
+	},
+}
+
+func newLayerEncoder(ctx context.Context, project geometry.Project) *layerEncoder {
+	e := layerEncoders.Get().(*layerEncoder)
+	e.ctx = ctx
+	e.geo.Project = project
+	return e
+}
+
+// release returns the encoder to the pool, dropping references to the encoded layers.
+func (e *layerEncoder) release() {
+	e.reset()
+	e.ctx = nil
+	e.geo.Project = nil
+	if cap(e.ints) <= maxPooledInts {
+		layerEncoders.Put(e)
+	}
+}
 
-	if err := marshalFeatures(data.Features, project, &layer); err != nil {
+func (e *layerEncoder) reset() {
+	clear(e.ids)
+	clear(e.keys)
+	clear(e.values)
+
+	clear(e.layer.Keys)
+	clear(e.layer.Values)
+	clear(e.layer.Features)
+	e.layer = wire.Layer{
+		Keys:     e.layer.Keys[:0],
+		Values:   e.layer.Values[:0],
+		Features: e.layer.Features[:0],
+	}
+
+	e.ints = e.ints[:0]
+	e.ends = e.ends[:0]
+}
+
+// marshalLayer appends the encoded layer to b.
+func (e *layerEncoder) marshalLayer(b []byte, data Layer, name LayerName) ([]byte, error) {
+	if err := e.ctx.Err(); err != nil {
 		return nil, err
 	}
-	return &layer, nil
+
+	e.reset()
+	e.layer.Name = string(name)
+	e.layer.Version = 2
+	e.layer.Extent = data.Extent
+	e.layer.HasName = true
+	e.layer.HasVersion = true
+	e.layer.HasExtent = true
+
+	if err := e.marshalFeatures(name, data.Features); err != nil {
+		return nil, err
+	}
+	return wire.AppendLayer(b, &e.layer), nil
 }
 
-func marshalFeatures(features []Feature, project geometry.Project, layer *spec.Tile_Layer) error {
-	layer.Features = make([]*spec.Tile_Feature, len(features))
+func (e *layerEncoder) marshalFeatures(name LayerName, features []Feature) error {
+	e.layer.Features = slices.Grow(e.layer.Features, len(features))[:len(features)]
 
-	ids := make(map[uint64]struct{})
-	keys := make(map[string]int)
-	values := make(map[interface{}]int)
+	// The tags of every feature come first, so that geometry can be appended.
+	var n int
+	for _, data := range features {
+		n += len(data.Tags) * 2
+	}
+	e.ints = slices.Grow(e.ints, n)[:n]
 
+	var tags int
 	for i, data := range features {
-		feature := spec.Tile_Feature{}
+		if err := e.ctx.Err(); err != nil {
+			return err
+		}
+
+		feature := &e.layer.Features[i]
 
 		if id, ok := data.ID.Get(); ok {
-			if _, ok = ids[id]; ok {
-				return fmt.Errorf("layer with ID '%d' already exists", id)
+			if _, ok = e.ids[id]; ok {
+				return newFeatureError(name, i, data.ID, SectionFeatures,
+					fmt.Errorf("feature with ID '%d' already exists", id))
 			}
 
-			feature.Id = &id
-			ids[id] = struct{}{}
+			feature.ID = id
+			feature.HasID = true
+			e.ids[id] = struct{}{}
 		}
 
-		marshalTags(data.Tags, keys, values, &feature)
+		n := len(data.Tags) * 2
+		if err := marshalTags(data.Tags, e.keys, e.values, e.ints[tags:tags+n]); err != nil {
+			return newFeatureError(name, i, data.ID, SectionAttributes, err)
+		}
+		tags += n
 
-		if err := marshalGeometry(data.Geometry, project, &feature); err != nil {
-			return fmt.Errorf("failed to marshal geometry: %w", err)
+		var err error
+		if e.ints, err = marshalGeometry(data.Geometry, &e.geo, e.ints, feature); err != nil {
+			return newFeatureError(name, i, data.ID, SectionGeometry,
+				fmt.Errorf("failed to marshal geometry: %w", err))
 		}
+		e.ends = append(e.ends, len(e.ints))
+	}
 
-		layer.Features[i] = &feature
+	// ints may have grown while encoding geometry, so it is only sliced once every feature is encoded.
+	start, tags := tags, 0
+	for i, data := range features {
+		n := len(data.Tags) * 2
+		e.layer.Features[i].Tags = e.ints[tags : tags+n : tags+n]
+		e.layer.Features[i].Geometry = e.ints[start:e.ends[i]:e.ends[i]]
+		tags, start = tags+n, e.ends[i]
 	}
 
-	return marshalKeyValues(keys, values, layer)
+	if err := marshalKeyValues(e.keys, e.values, &e.layer); err != nil {
+		return &LayerError{
+			Layer:   name,
+			Section: SectionAttributes,
+			Err:     err,
+		}
+	}
+	return nil
 }
 
-func marshalTags(tags geojson.PropertyList, keys map[string]int, values map[interface{}]int, feature *spec.Tile_Feature) {
-	feature.Tags = make([]uint32, len(tags)*2)
+// marshalTags stores the key and value index of each tag in buf, which must have room for every tag.
+func marshalTags(tags geojson.PropertyList, keys map[string]int, values map[interface{}]int, buf []uint32) error {
 	for i, tag := range tags {
-		if _, ok := keys[tag.Name]; !ok {
-			keys[tag.Name] = len(keys)
+		if !isValueType(tag.Value) {
+			return fmt.Errorf("tag '%s' has unsupported type '%T'", tag.Name, tag.Value)
 		}
 
-		if _, ok := values[tag.Value]; !ok {
-			values[tag.Value] = len(values)
+		key, ok := keys[tag.Name]
+		if !ok {
+			key = len(keys)
+			keys[tag.Name] = key
 		}
 
-		feature.Tags[i*2] = uint32(keys[tag.Name])
-		feature.Tags[i*2+1] = uint32(values[tag.Value])
+		// Look up the value index once, as some values (NaN) are never equal to themselves.
+		value, ok := values[tag.Value]
+		if !ok {
+			value = len(values)
+			values[tag.Value] = value
+		}
+
+		buf[i*2] = uint32(key)
+		buf[i*2+1] = uint32(value)
+	}
+	return nil
+}
+
+// isValueType returns true if v has a type that can be encoded as a tag value.
+func isValueType(v interface{}) bool {
+	switch v.(type) {
+	case string, float32, float64, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		return true
+	default:
+		return false
 	}
 }
 
-func marshalKeyValues(keys map[string]int, values map[interface{}]int, layer *spec.Tile_Layer) error {
-	layer.Keys = make([]string, len(keys))
+func marshalKeyValues(keys map[string]int, values map[interface{}]int, layer *wire.Layer) error {
+	layer.Keys = slices.Grow(layer.Keys[:0], len(keys))[:len(keys)]
 	for key, pos := range keys {
 		layer.Keys[pos] = key
 	}
 
-	layer.Values = make([]*spec.Tile_Value, len(values))
+	layer.Values = slices.Grow(layer.Values[:0], len(values))[:len(values)]
 	for value, pos := range values {
 		v, err := marshalKeyValue(value)
 		if err != nil {
@@ -110,7 +447,7 @@ func marshalKeyValues(keys map[string]int, values map[interface{}]int, layer *sp
 	return nil
 }
 
-func marshalKeyValue(value interface{}) (*spec.Tile_Value, error) {
+func marshalKeyValue(value interface{}) (wire.Value, error) {
 	switch v := value.(type) {
 	case int:
 		value = int64(v)
@@ -132,40 +469,59 @@ func marshalKeyValue(value interface{}) (*spec.Tile_Value, error) {
 
 	switch v := value.(type) {
 	case string:
-		return &spec.Tile_Value{
-			StringValue: &v,
+		return wire.Value{
+			Fields: 1 << wire.StringField,
+			String: v,
 		}, nil
 	case float32:
-		return &spec.Tile_Value{
-			FloatValue: &v,
+		return wire.Value{
+			Fields: 1 << wire.FloatField,
+			Float:  v,
 		}, nil
 	case float64:
-		return &spec.Tile_Value{
-			DoubleValue: &v,
+		return wire.Value{
+			Fields: 1 << wire.DoubleField,
+			Double: v,
 		}, nil
 	case int64:
-		return &spec.Tile_Value{
-			IntValue: &v,
+		return wire.Value{
+			Fields: 1 << wire.IntField,
+			Int:    v,
 		}, nil
 	case uint64:
-		return &spec.Tile_Value{
-			UintValue: &v,
+		return wire.Value{
+			Fields: 1 << wire.UintField,
+			Uint:   v,
 		}, nil
 	case bool:
-		return &spec.Tile_Value{
-			BoolValue: &v,
+		return wire.Value{
+			Fields: 1 << wire.BoolField,
+			Bool:   v,
 		}, nil
 	default:
-		return nil, fmt.Errorf("unsupported type '%t'", v)
+		return wire.Value{}, fmt.Errorf("unsupported type '%T'", v)
 	}
 }
 
-func marshalGeometry(geo geojson.Geometry, project geometry.Project, feature *spec.Tile_Feature) error {
-	buf, err := geometry.Marshal(geo, project)
-	if err != nil {
-		return err
-	}
+// marshalGeometry appends the encoded geometry to b, and sets the geometry type of the feature.
+func marshalGeometry(geo geojson.Geometry, e *geometry.Encoder, b []uint32, feature *wire.Feature) ([]uint32, error) {
+	feature.Type = spec.Tile_UNKNOWN
+	feature.HasType = true
 
-	feature.Geometry = buf
-	return nil
+	switch g := geo.(type) {
+	case nil:
+		return b, nil
+	case *UnknownGeometry:
+		geo = &g.RawShape
+	case *TileGeometry:
+		feature.Type = g.specType()
+		return g.appendCommands(b)
+	case *geojson.Point, *geojson.MultiPoint:
+		feature.Type = spec.Tile_POINT
+	case *geojson.LineString, *geojson.MultiLineString:
+		feature.Type = spec.Tile_LINESTRING
+	case *geojson.Polygon, *geojson.MultiPolygon:
+		feature.Type = spec.Tile_POLYGON
+	}
+	return e.Append(b, geo)
 }
@@ -1,6 +1,7 @@
 package mvt_test
 
 import (
+	"math"
 	"testing"
 
 	"github.com/everystreet/go-geojson/v2"
@@ -303,3 +304,48 @@ func TestMarshalFeatureTags(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshalMissingProject(t *testing.T) {
+	_, err := mvt21.Marshal(mvt21.Layers{
+		"my_layer": {
+			Features: []mvt21.Feature{
+				{
+					Geometry: geojson.NewPoint(1, 2).Geometry,
+				},
+			},
+		},
+	}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing project function")
+}
+
+func TestMarshalNaNTags(t *testing.T) {
+	data, err := mvt21.Marshal(mvt21.Layers{
+		"my_layer": {
+			Features: []mvt21.Feature{
+				{
+					Tags: []geojson.Property{
+						{
+							Name:  "key1",
+							Value: "value",
+						},
+						{
+							Name:  "key2",
+							Value: math.NaN(),
+						},
+					},
+				},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	var tile spec.Tile
+	require.NoError(t, proto.Unmarshal(data, &tile))
+	require.Len(t, tile.Layers, 1)
+
+	layer := tile.Layers[0]
+	require.Len(t, layer.Values, 2)
+	require.Equal(t, []uint32{0, 0, 1, 1}, layer.Features[0].Tags)
+	require.True(t, math.IsNaN(layer.Values[1].GetDoubleValue()))
+}
@@ -0,0 +1,102 @@
+package mvt
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// workers returns the number of goroutines used to process n layers with the configured concurrency.
+func workers(concurrency, n int) int {
+	if concurrency < 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return concurrency
+}
+
+// forEachLayer calls fn for each layer index in [0, n) using the supplied number of workers.
+// fn is passed the index of the worker, which is less than workers, and is never called concurrently for the same worker.
+// Each layer is passed its own context, derived from ctx.
+// Once fn returns an error, no further layers are started, and the contexts of layers after the failing one are canceled,
+// so that work in flight on them stops. Layers are started in index order, so every layer before a failing one
+// still completes, and the returned error is that of the lowest failing layer index, as if run serially.
+func forEachLayer(ctx context.Context, n, workers int, fn func(ctx context.Context, worker, i int) error) error {
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(ctx, 0, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		next atomic.Int64
+		wg   sync.WaitGroup
+
+		mu       sync.Mutex
+		firstErr error
+		errIndex = n
+		cancels  = make(map[int]context.CancelFunc)
+	)
+
+	// start returns the context of layer i, or false if a layer before it has failed.
+	start := func(i int) (context.Context, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if i > errIndex {
+			return nil, false
+		}
+
+		layerCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		return layerCtx, true
+	}
+
+	// finish records the result of layer i, and cancels the layers after it if it failed.
+	finish := func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		cancels[i]()
+		delete(cancels, i)
+
+		if err == nil || i > errIndex {
+			return
+		}
+		firstErr, errIndex = err, i
+		for j, cancel := range cancels {
+			if j > i {
+				cancel()
+			}
+		}
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+
+				layerCtx, ok := start(i)
+				if !ok {
+					return
+				}
+				finish(i, fn(layerCtx, w, i))
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,197 @@
+package mvt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalConcurrency(t *testing.T) {
+	layers := make(mvt21.Layers)
+	for i := 0; i < 20; i++ {
+		layers[mvt21.LayerName(fmt.Sprintf("layer%02d", i))] = mvt21.MakeLayer(4096,
+			mvt21.Feature{
+				ID:       mvt21.NewOptionalUint64(uint64(i)),
+				Geometry: geojson.NewPoint(float64(i), 12).Geometry,
+				Tags:     geojson.PropertyList{{Name: "index", Value: int64(i)}},
+			})
+	}
+
+	expected, err := mvt21.MarshalOptions{Project: SimpleProject}.Marshal(layers)
+	require.NoError(t, err)
+
+	// Layers are written in name order.
+	var tile spec.Tile
+	require.NoError(t, proto.Unmarshal(expected, &tile))
+	require.Len(t, tile.Layers, len(layers))
+	for i, layer := range tile.Layers {
+		require.Equal(t, fmt.Sprintf("layer%02d", i), layer.GetName())
+	}
+
+	for _, concurrency := range []int{2, 8, 64, -1} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			for i := 0; i < 5; i++ {
+				data, err := mvt21.MarshalOptions{
+					Project:     SimpleProject,
+					Concurrency: concurrency,
+				}.Marshal(layers)
+				require.NoError(t, err)
+				require.Equal(t, expected, data)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		// Features with duplicate IDs in two layers.
+		for _, name := range []mvt21.LayerName{"layer05", "layer12"} {
+			layer := layers[name]
+			layer.Features = append(layer.Features, layer.Features[0])
+			layers[name] = layer
+		}
+
+		for i := 0; i < 20; i++ {
+			_, err := mvt21.MarshalOptions{
+				Project:     SimpleProject,
+				Concurrency: 8,
+			}.Marshal(layers)
+
+			var featureErr *mvt21.FeatureError
+			require.True(t, errors.As(err, &featureErr))
+			require.Equal(t, mvt21.LayerName("layer05"), featureErr.Layer)
+		}
+	})
+}
+
+func TestMarshalConcurrencyCancel(t *testing.T) {
+	// The first layer fails once the second, which is large and slow to project, is in flight.
+	failing := mvt21.MakeLayer(4096,
+		mvt21.Feature{ID: mvt21.NewOptionalUint64(1), Geometry: geojson.NewPoint(1, 1).Geometry},
+		mvt21.Feature{ID: mvt21.NewOptionalUint64(1), Geometry: geojson.NewPoint(1, 1).Geometry},
+	)
+
+	const n = 10000
+	slow := mvt21.MakeLayer(4096)
+	for i := 0; i < n; i++ {
+		slow.Features = append(slow.Features, mvt21.Feature{Geometry: geojson.NewPoint(2, 2).Geometry})
+	}
+
+	started := make(chan struct{})
+	var projected atomic.Int64
+	_, err := mvt21.MarshalOptions{
+		Project: func(ll s2.LatLng) r2.Point {
+			if ll.Lat.Degrees() < 1.5 {
+				<-started
+			} else if projected.Add(1) == 100 {
+				close(started)
+			} else {
+				time.Sleep(10 * time.Microsecond)
+			}
+			return SimpleProject(ll)
+		},
+		Concurrency: 2,
+	}.Marshal(mvt21.Layers{"a": failing, "b": slow})
+
+	var featureErr *mvt21.FeatureError
+	require.True(t, errors.As(err, &featureErr))
+	require.Equal(t, mvt21.LayerName("a"), featureErr.Layer)
+
+	// The second layer is canceled while it is being encoded.
+	require.Less(t, projected.Load(), int64(n))
+}
+
+func TestUnmarshalConcurrency(t *testing.T) {
+	tile := spec.Tile{}
+	for i := 0; i < 20; i++ {
+		layer := newLayer(fmt.Sprintf("layer%02d", i), 2, 4096)
+		layer.Features = []*spec.Tile_Feature{
+			{
+				Id:       proto.Uint64(1),
+				Type:     spec.Tile_POINT.Enum(),
+				Geometry: []uint32{9, uint32(i * 2), 0},
+			},
+		}
+		if i%3 == 0 {
+			// A truncated geometry.
+			layer.Features = append(layer.Features, &spec.Tile_Feature{
+				Id:       proto.Uint64(2),
+				Type:     spec.Tile_POINT.Enum(),
+				Geometry: []uint32{9, 0},
+			})
+		}
+		tile.Layers = append(tile.Layers, layer)
+	}
+
+	data, err := proto.Marshal(&tile)
+	require.NoError(t, err)
+
+	opts := mvt21.UnmarshalOptions{
+		Unproject:       SimpleUnproject,
+		InvalidFeatures: mvt21.DropInvalidFeatures,
+		Limits:          mvt21.Limits{MaxAllocation: 1 << 20},
+	}
+
+	expected, expectedErr := opts.Unmarshal(data)
+	require.Len(t, expected, 20)
+
+	var expectedProblems mvt21.Problems
+	require.True(t, errors.As(expectedErr, &expectedProblems))
+	require.Len(t, expectedProblems, 7)
+
+	for _, concurrency := range []int{2, 8, 64, -1} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			opts := opts
+			opts.Concurrency = concurrency
+
+			for i := 0; i < 5; i++ {
+				layers, err := opts.Unmarshal(data)
+				require.Equal(t, expected, layers)
+
+				// Problems are reported in layer order.
+				var problems mvt21.Problems
+				require.True(t, errors.As(err, &problems))
+				require.Equal(t, expectedProblems, problems)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			_, err := mvt21.UnmarshalOptions{
+				Unproject:   SimpleUnproject,
+				Concurrency: 8,
+			}.Unmarshal(data)
+
+			var featureErr *mvt21.FeatureError
+			require.True(t, errors.As(err, &featureErr))
+			require.Equal(t, mvt21.LayerName("layer00"), featureErr.Layer)
+		}
+	})
+
+	t.Run("decoder", func(t *testing.T) {
+		dec := mvt21.NewDecoder(nil)
+		dec.SetOptions(mvt21.UnmarshalOptions{
+			Unproject:       SimpleUnproject,
+			InvalidFeatures: mvt21.DropInvalidFeatures,
+			Concurrency:     4,
+		})
+
+		for i := 0; i < 3; i++ {
+			dec.Reset(bytes.NewReader(data))
+
+			var layers mvt21.Layers
+			require.Error(t, dec.Decode(&layers))
+			require.Equal(t, expected, layers)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package mvt
+
+import (
+	"fmt"
+	"math"
+)
+
+// Reduction removes polygons and lines of a layer that are too small to be seen, in the manner of tippecanoe.
+// Only features with a TileGeometry are reduced, and thresholds are in the tile coordinates of the layer.
+// Features left without geometry are removed.
+type Reduction struct {
+	// MinArea is the area below which a polygon is tiny, including its holes. If zero, polygons are kept.
+	MinArea float64
+
+	// Accumulate tiny polygons rather than dropping them. Their areas are summed in the order of the layer,
+	// and once the sum reaches MinArea, the tiny polygon that reached it is replaced by a square of that area.
+	Accumulate bool
+
+	// MinLength is the length below which a line is dropped. If zero, lines are kept.
+	MinLength float64
+}
+
+// Validate the reduction.
+func (r Reduction) Validate() error {
+	if r.MinArea < 0 {
+		return fmt.Errorf("min area must not be negative")
+	} else if r.MinLength < 0 {
+		return fmt.Errorf("min length must not be negative")
+	}
+	return nil
+}
+
+// Layer returns the layer with its tiny polygons and short lines removed.
+// The supplied layer is not modified.
+func (r Reduction) Layer(layer Layer) Layer {
+	features := make([]Feature, 0, len(layer.Features))
+	var accumulated float64
+
+	for _, f := range layer.Features {
+		g, ok := f.Geometry.(*TileGeometry)
+		if !ok || g == nil {
+			features = append(features, f)
+			continue
+		}
+
+		var parts [][]TilePoint
+		switch {
+		case g.GeomType == TilePolygonType && r.MinArea > 0:
+			parts = r.polygons(g.Parts, &accumulated)
+		case g.GeomType == TileLineStringType && r.MinLength > 0:
+			parts = r.lines(g.Parts)
+		default:
+			features = append(features, f)
+			continue
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+		f.Geometry = &TileGeometry{GeomType: g.GeomType, Parts: parts}
+		features = append(features, f)
+	}
+	return MakeLayer(layer.Extent, features...)
+}
+
+// polygons returns the polygons that are not tiny, and the squares that replace accumulated tiny polygons.
+func (r Reduction) polygons(rings [][]TilePoint, accumulated *float64) [][]TilePoint {
+	var out [][]TilePoint
+	for start := 0; start < len(rings); {
+		// A polygon is an exterior ring followed by its holes.
+		end := start + 1
+		for end < len(rings) && RingArea(rings[end]) < 0 {
+			end++
+		}
+		polygon := rings[start:end]
+		start = end
+
+		var area float64
+		for _, ring := range polygon {
+			area += float64(RingArea(ring)) / 2
+		}
+
+		if area >= r.MinArea {
+			out = append(out, polygon...)
+			continue
+		} else if !r.Accumulate {
+			continue
+		}
+
+		if *accumulated += math.Max(area, 0); *accumulated >= r.MinArea {
+			out = append(out, square(polygon[0], *accumulated))
+			*accumulated = 0
+		}
+	}
+	return out
+}
+
+// lines returns the lines that are not short.
+func (r Reduction) lines(lines [][]TilePoint) [][]TilePoint {
+	var out [][]TilePoint
+	for _, line := range lines {
+		var length float64
+		for i := 1; i < len(line); i++ {
+			length += math.Hypot(float64(line[i].X-line[i-1].X), float64(line[i].Y-line[i-1].Y))
+		}
+		if length >= r.MinLength {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// square returns an exterior ring of the area, centred on the bounds of the ring.
+func square(ring []TilePoint, area float64) []TilePoint {
+	minX, minY := ring[0].X, ring[0].Y
+	maxX, maxY := minX, minY
+	for _, p := range ring[1:] {
+		minX, maxX = min(minX, p.X), max(maxX, p.X)
+		minY, maxY = min(minY, p.Y), max(maxY, p.Y)
+	}
+
+	side := max(int32(math.Round(math.Sqrt(area))), 1)
+	x := minX + (maxX-minX)/2 - side/2
+	y := minY + (maxY-minY)/2 - side/2
+	return []TilePoint{{X: x, Y: y}, {X: x + side, Y: y}, {X: x + side, Y: y + side}, {X: x, Y: y + side}}
+}
@@ -0,0 +1,81 @@
+package mvt_test
+
+import (
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReduction(t *testing.T) {
+	box := func(x0, y0, x1, y1 int32) []mvt21.TilePoint {
+		return []mvt21.TilePoint{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}
+	}
+	hole := func(x0, y0, x1, y1 int32) []mvt21.TilePoint {
+		return []mvt21.TilePoint{{X: x0, Y: y0}, {X: x0, Y: y1}, {X: x1, Y: y1}, {X: x1, Y: y0}}
+	}
+	polygon := func(rings ...[]mvt21.TilePoint) mvt21.Feature {
+		return mvt21.Feature{Geometry: &mvt21.TileGeometry{GeomType: mvt21.TilePolygonType, Parts: rings}}
+	}
+
+	layer := mvt21.MakeLayer(4096,
+		// The large polygon is kept, and the tiny one removed.
+		polygon(box(0, 0, 100, 100), box(200, 200, 202, 202)),
+		polygon(box(300, 300, 303, 303)),
+		// The hole leaves too little area.
+		polygon(box(400, 400, 420, 420), hole(401, 401, 419, 419)),
+		polygon(box(500, 500, 502, 503)),
+		mvt21.Feature{Geometry: &mvt21.TileGeometry{
+			GeomType: mvt21.TileLineStringType,
+			Parts: [][]mvt21.TilePoint{
+				{{X: 0, Y: 0}, {X: 3, Y: 4}, {X: 6, Y: 8}},
+				{{X: 0, Y: 0}, {X: 5, Y: 0}},
+			},
+		}},
+		mvt21.Feature{Geometry: &mvt21.TileGeometry{
+			GeomType: mvt21.TileLineStringType,
+			Parts:    [][]mvt21.TilePoint{{{X: 0, Y: 0}, {X: 0, Y: 9}}},
+		}},
+		mvt21.Feature{Geometry: geojson.NewPoint(1, 1).Geometry},
+	)
+
+	t.Run("drop", func(t *testing.T) {
+		reduced := mvt21.Reduction{MinArea: 100, MinLength: 10}.Layer(layer)
+		require.Equal(t, mvt21.MakeLayer(4096,
+			polygon(box(0, 0, 100, 100)),
+			mvt21.Feature{Geometry: &mvt21.TileGeometry{
+				GeomType: mvt21.TileLineStringType,
+				Parts:    [][]mvt21.TilePoint{{{X: 0, Y: 0}, {X: 3, Y: 4}, {X: 6, Y: 8}}},
+			}},
+			layer.Features[6],
+		), reduced)
+		require.NoError(t, reduced.Validate())
+	})
+
+	t.Run("accumulate", func(t *testing.T) {
+		// The areas of 4, 9, 76 and 6 reach 80 at the third tiny polygon, which is replaced by a square of their sum.
+		reduced := mvt21.Reduction{MinArea: 80, Accumulate: true}.Layer(layer)
+		require.Equal(t, mvt21.MakeLayer(4096,
+			polygon(box(0, 0, 100, 100)),
+			polygon(box(406, 406, 415, 415)),
+			layer.Features[4],
+			layer.Features[5],
+			layer.Features[6],
+		), reduced)
+	})
+
+	t.Run("layer config", func(t *testing.T) {
+		configs := mvt21.LayerConfigs{"buildings": {
+			Extents: []mvt21.ZoomExtent{{Extent: 1024}},
+			Reduce:  &mvt21.Reduction{MinArea: 16},
+		}}
+		configured := configs.Layers(mvt21.Layers{"buildings": layer}, 0)["buildings"]
+		require.Equal(t, uint32(1024), configured.Extent)
+		require.Len(t, configured.Features, 4)
+		require.Equal(t, polygon(box(0, 0, 25, 25)), configured.Features[0])
+
+		require.Error(t, mvt21.LayerConfig{Reduce: &mvt21.Reduction{MinArea: -1}}.Validate())
+		require.Error(t, mvt21.LayerConfig{Reduce: &mvt21.Reduction{MinLength: -1}}.Validate())
+	})
+}
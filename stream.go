@@ -0,0 +1,110 @@
+package mvt
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/everystreet/go-mvt/internal/wire"
+)
+
+// An Encoder writes encoded tiles to an output stream.
+// An Encoder reuses its internal buffers between calls to Encode,
+// so it should be reused when encoding many tiles.
+type Encoder struct {
+	w    io.Writer
+	opts MarshalOptions
+	buf  []byte
+	comp compressor
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetOptions sets the options used by subsequent calls to Encode.
+func (e *Encoder) SetOptions(opts MarshalOptions) {
+	e.opts = opts
+}
+
+// Reset sets the encoder to write to w.
+// Internal buffers are kept for reuse.
+func (e *Encoder) Reset(w io.Writer) {
+	e.w = w
+}
+
+// Encode writes the mvt encoding of layers to the stream.
+func (e *Encoder) Encode(layers Layers) error {
+	return e.EncodeContext(context.Background(), layers)
+}
+
+// EncodeContext writes the mvt encoding of layers to the stream.
+// Encoding stops between features if ctx is done, and ctx.Err() is returned without writing to the stream.
+func (e *Encoder) EncodeContext(ctx context.Context, layers Layers) error {
+	buf, err := e.opts.marshalAppend(ctx, e.buf[:0], layers)
+	if err != nil {
+		return err
+	}
+	e.buf = buf
+
+	return e.comp.compress(e.w, buf, e.opts.Compression)
+}
+
+// A Decoder reads and decodes tiles from an input stream.
+// A tile is not self-delimiting, so each call to Decode consumes the stream until EOF.
+// A Decoder reuses its internal buffers between calls to Decode,
+// so it should be reused when decoding many tiles.
+type Decoder struct {
+	r      io.Reader
+	opts   UnmarshalOptions
+	buf    bytes.Buffer
+	dec    decompressor
+	layers []wire.Layer
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// SetOptions sets the options used by subsequent calls to Decode.
+func (d *Decoder) SetOptions(opts UnmarshalOptions) {
+	d.opts = opts
+}
+
+// Reset discards any buffered data and sets the decoder to read from r.
+// Internal buffers are kept for reuse.
+func (d *Decoder) Reset(r io.Reader) {
+	d.r = r
+	d.buf.Reset()
+}
+
+// Decode reads the next tile from the stream and stores the decoded layers in the value pointed to by v.
+// Gzip and zstd compressed tiles are decompressed automatically.
+// If invalid features are skipped, v is set and a Problems error is returned.
+func (d *Decoder) Decode(v *Layers) error {
+	return d.DecodeContext(context.Background(), v)
+}
+
+// DecodeContext reads the next tile from the stream and stores the decoded layers in the value pointed to by v.
+// Reading the stream is not interrupted, but decoding stops between features if ctx is done,
+// and ctx.Err() is returned.
+func (d *Decoder) DecodeContext(ctx context.Context, v *Layers) error {
+	d.buf.Reset()
+	if _, err := d.buf.ReadFrom(d.r); err != nil {
+		return err
+	}
+
+	data, err := d.dec.decompress(d.buf.Bytes(), d.opts.MaxDecompressedSize)
+	if err != nil {
+		return err
+	}
+
+	layers, scratch, err := d.opts.unmarshalTile(ctx, data, d.layers)
+	d.layers = scratch
+	if layers != nil {
+		*v = layers
+	}
+	return err
+}
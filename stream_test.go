@@ -0,0 +1,142 @@
+package mvt_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoderDecoder(t *testing.T) {
+	layers := mvt21.Layers{
+		"points": {
+			Extent: 4096,
+			Features: []mvt21.Feature{
+				{
+					Geometry: geojson.NewPoint(34, 12).Geometry,
+					ID:       mvt21.NewOptionalUint64(1),
+					Tags: geojson.PropertyList{
+						{
+							Name:  "name",
+							Value: "value",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := mvt21.NewEncoder(&buf)
+	enc.SetOptions(mvt21.MarshalOptions{
+		Project:  SimpleProject,
+		Validate: true,
+	})
+	require.NoError(t, enc.Encode(layers))
+
+	dec := mvt21.NewDecoder(&buf)
+	dec.SetOptions(mvt21.UnmarshalOptions{
+		Unproject: SimpleUnproject,
+	})
+
+	var decoded mvt21.Layers
+	require.NoError(t, dec.Decode(&decoded))
+	require.Equal(t, layers, decoded)
+
+	t.Run("reuse", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			buf.Reset()
+			enc.Reset(&buf)
+			require.NoError(t, enc.Encode(layers))
+
+			dec.Reset(&buf)
+			require.NoError(t, dec.Decode(&decoded))
+			require.Equal(t, layers, decoded)
+		}
+	})
+}
+
+func TestEncoderRound(t *testing.T) {
+	layers := mvt21.Layers{
+		"points": {
+			Features: []mvt21.Feature{
+				{
+					Geometry: geojson.NewPoint(12.6, 34.6).Geometry,
+				},
+			},
+		},
+	}
+
+	for _, tt := range []struct {
+		Name   string
+		Round  func(float64) float64
+		Expect *geojson.Feature
+	}{
+		{
+			Name:   "truncate",
+			Expect: geojson.NewPoint(12, 34),
+		},
+		{
+			Name:   "nearest",
+			Round:  math.Round,
+			Expect: geojson.NewPoint(13, 35),
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := mvt21.NewEncoder(&buf)
+			enc.SetOptions(mvt21.MarshalOptions{
+				Project: SimpleProject,
+				Round:   tt.Round,
+			})
+			require.NoError(t, enc.Encode(layers))
+
+			decoded, err := mvt21.Unmarshal(buf.Bytes(), SimpleUnproject)
+			require.NoError(t, err)
+			require.Len(t, decoded["points"].Features, 1)
+
+			point := decoded["points"].Features[0].Geometry.(*geojson.Point)
+			expect := tt.Expect.Geometry.(*geojson.Point)
+			require.InDelta(t, expect.Lat.Degrees(), point.Lat.Degrees(), 1e-9)
+			require.InDelta(t, expect.Lng.Degrees(), point.Lng.Degrees(), 1e-9)
+		})
+	}
+}
+
+func TestEncoderValidate(t *testing.T) {
+	var buf bytes.Buffer
+	enc := mvt21.NewEncoder(&buf)
+	enc.SetOptions(mvt21.MarshalOptions{
+		Project:  SimpleProject,
+		Validate: true,
+	})
+
+	err := enc.Encode(mvt21.Layers{
+		"collection": {
+			Features: []mvt21.Feature{
+				{
+					Geometry: &geojson.GeometryCollection{},
+				},
+			},
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not allowed")
+	require.Zero(t, buf.Len())
+}
+
+var SimpleProject = func(ll s2.LatLng) r2.Point {
+	return r2.Point{
+		X: ll.Lng.Degrees(),
+		Y: ll.Lat.Degrees(),
+	}
+}
+
+var SimpleUnproject = func(p r2.Point) s2.LatLng {
+	return s2.LatLngFromDegrees(p.Y, p.X)
+}
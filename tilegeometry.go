@@ -0,0 +1,335 @@
+package mvt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/everystreet/go-geojson/v2"
+	"github.com/everystreet/go-mvt/internal/geometry"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+)
+
+// TilePoint is a position in tile coordinates, where the Y axis points down.
+type TilePoint struct {
+	X, Y int32
+}
+
+// TileGeometryType is the type of a TileGeometry.
+type TileGeometryType uint8
+
+// Types of TileGeometry, matching the geometry types of the specification.
+const (
+	TilePointType TileGeometryType = iota + 1
+	TileLineStringType
+	TilePolygonType
+)
+
+func (t TileGeometryType) String() string {
+	switch t {
+	case TilePointType:
+		return "Point"
+	case TileLineStringType:
+		return "LineString"
+	case TilePolygonType:
+		return "Polygon"
+	default:
+		return "unknown"
+	}
+}
+
+// TileGeometry is a geometry in tile coordinates, which is encoded without projection.
+// It implements the geojson.Geometry interface, so it can be used as the geometry of a Feature.
+//
+// Parts holds the points of a point geometry, the lines of a linestring geometry, or the rings of a polygon geometry.
+// Rings are implicitly closed, so the first point is not repeated at the end.
+// A ring with a positive area by the surveyor's formula, which appears clockwise as the Y axis points down,
+// is an exterior ring that starts a new polygon. Rings with a negative area are holes in the preceding exterior ring.
+type TileGeometry struct {
+	GeomType TileGeometryType
+	Parts    [][]TilePoint
+}
+
+// MarshalJSON returns the JSON encoding of g.
+func (g TileGeometry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tileGeometry{
+		Type:  g.GeomType.String(),
+		Parts: g.Parts,
+	})
+}
+
+// UnmarshalJSON sets g to the JSON decoding of data.
+func (g *TileGeometry) UnmarshalJSON(data []byte) error {
+	var v tileGeometry
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	for _, t := range []TileGeometryType{TilePointType, TileLineStringType, TilePolygonType} {
+		if v.Type == t.String() {
+			*g = TileGeometry{
+				GeomType: t,
+				Parts:    v.Parts,
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown type '%s'", v.Type)
+}
+
+// Type returns the geometry type.
+func (g TileGeometry) Type() geojson.GeometryType {
+	return "tile"
+}
+
+// Validate the TileGeometry.
+func (g TileGeometry) Validate() error {
+	if len(g.Parts) == 0 {
+		return fmt.Errorf("missing geometry")
+	}
+
+	switch g.GeomType {
+	case TilePointType:
+		for _, points := range g.Parts {
+			if len(points) > 0 {
+				return nil
+			}
+		}
+		return fmt.Errorf("missing geometry")
+	case TileLineStringType:
+		for i, line := range g.Parts {
+			if len(line) < 2 {
+				return fmt.Errorf("linestring '%d' must consist of at least 2 points", i)
+			} else if !distinctPoints(line, false) {
+				return fmt.Errorf("linestring '%d' has a zero-length segment", i)
+			}
+		}
+		return nil
+	case TilePolygonType:
+		for i, ring := range g.Parts {
+			if len(ring) < 3 {
+				return fmt.Errorf("ring '%d' must consist of at least 3 points", i)
+			} else if !distinctPoints(ring, true) {
+				return fmt.Errorf("ring '%d' has a zero-length segment", i)
+			}
+
+			if area := RingArea(ring); area == 0 {
+				return fmt.Errorf("ring '%d' has zero area", i)
+			} else if i == 0 && area < 0 {
+				return fmt.Errorf("first ring must be an exterior ring with clockwise winding")
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown type '%d'", g.GeomType)
+	}
+}
+
+// RingArea returns twice the signed area of the implicitly closed ring, calculated by the surveyor's formula.
+// It is positive for an exterior ring.
+func RingArea(ring []TilePoint) int64 {
+	var sum int64
+	for i := range ring {
+		p, q := ring[i], ring[(i+1)%len(ring)]
+		sum += int64(p.X)*int64(q.Y) - int64(q.X)*int64(p.Y)
+	}
+	return sum
+}
+
+// KeepRing returns true if a ring of a polygon geometry is kept once it has been transformed, by scaling, clipping
+// or simplifying it. part is the ring before it was transformed, and ring is the ring after.
+// A ring is kept if it still has 3 points and winds the same way, and holes are dropped along with their exterior ring.
+// exterior records whether the last exterior ring was kept, so it must be false before the first ring of the geometry.
+func KeepRing(part, ring []TilePoint, exterior *bool) bool {
+	wasExterior := RingArea(part) > 0
+	area := RingArea(ring)
+	kept := len(ring) >= 3 && area != 0 && (area > 0) == wasExterior
+	if wasExterior {
+		*exterior = kept
+	}
+	return *exterior && kept
+}
+
+// distinctPoints returns true if no two consecutive points are equal,
+// including the last and first points if closed is true.
+func distinctPoints(points []TilePoint, closed bool) bool {
+	for i := 1; i < len(points); i++ {
+		if points[i] == points[i-1] {
+			return false
+		}
+	}
+	return !closed || points[0] != points[len(points)-1]
+}
+
+// specType returns the geometry type of the specification.
+func (g TileGeometry) specType() spec.Tile_GeomType {
+	switch g.GeomType {
+	case TilePointType:
+		return spec.Tile_POINT
+	case TileLineStringType:
+		return spec.Tile_LINESTRING
+	case TilePolygonType:
+		return spec.Tile_POLYGON
+	default:
+		return spec.Tile_UNKNOWN
+	}
+}
+
+// appendCommands appends the encoded command sequence of g to b.
+// If an error is returned, b is returned unchanged apart from its capacity.
+func (g TileGeometry) appendCommands(b []uint32) ([]uint32, error) {
+	if err := g.Validate(); err != nil {
+		return b, err
+	}
+
+	n := len(b)
+	data, err := g.append(b)
+	if err != nil {
+		return b[:n], err
+	}
+	return data, nil
+}
+
+func (g TileGeometry) append(b []uint32) ([]uint32, error) {
+	var (
+		e   tileEncoder
+		err error
+	)
+
+	if g.GeomType == TilePointType {
+		var count int
+		for _, points := range g.Parts {
+			count += len(points)
+		}
+
+		if b, err = appendCommand(b, geometry.MoveTo, count); err != nil {
+			return nil, err
+		}
+		for _, points := range g.Parts {
+			if b, err = e.appendPoints(b, points); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	for _, part := range g.Parts {
+		if b, err = appendCommand(b, geometry.MoveTo, 1); err != nil {
+			return nil, err
+		} else if b, err = e.appendPoints(b, part[:1]); err != nil {
+			return nil, err
+		} else if b, err = appendCommand(b, geometry.LineTo, len(part)-1); err != nil {
+			return nil, err
+		} else if b, err = e.appendPoints(b, part[1:]); err != nil {
+			return nil, err
+		}
+
+		if g.GeomType == TilePolygonType {
+			if b, err = appendCommand(b, geometry.ClosePath, 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+func appendCommand(b []uint32, id geometry.CommandID, count int) ([]uint32, error) {
+	cmd, err := geometry.MakeCommandInteger(id, uint32(count))
+	if err != nil {
+		return nil, err
+	}
+	return append(b, uint32(cmd)), nil
+}
+
+// tileEncoder holds the cursor that points are encoded relative to.
+type tileEncoder struct {
+	cursor TilePoint
+}
+
+// appendPoints encodes each point relative to the previous one, starting from the cursor.
+// The cursor is left at the last point.
+func (e *tileEncoder) appendPoints(b []uint32, points []TilePoint) ([]uint32, error) {
+	for _, p := range points {
+		dx, dy := int64(p.X)-int64(e.cursor.X), int64(p.Y)-int64(e.cursor.Y)
+		if dx < math.MinInt32 || dx > math.MaxInt32 || dy < math.MinInt32 || dy > math.MaxInt32 {
+			return nil, fmt.Errorf("point (%d, %d) is too far from previous point", p.X, p.Y)
+		}
+
+		x, err := geometry.MakeParameterInteger(int32(dx))
+		if err != nil {
+			return nil, err
+		}
+		y, err := geometry.MakeParameterInteger(int32(dy))
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, uint32(x), uint32(y))
+		e.cursor = p
+	}
+	return b, nil
+}
+
+// unmarshalTileGeometry decodes the command sequence of a geometry of the type in tile coordinates.
+// The sequence must have the structure of the type, but the geometry is not otherwise validated.
+func unmarshalTileGeometry(data []uint32, typ spec.Tile_GeomType) (*TileGeometry, error) {
+	cmds, err := geometry.Commands(data)
+	if err != nil {
+		return nil, err
+	}
+
+	points := func(cmd geometry.Command) ([]TilePoint, error) {
+		out := make([]TilePoint, len(cmd.Points))
+		for i, p := range cmd.Points {
+			if p.X < math.MinInt32 || p.X > math.MaxInt32 || p.Y < math.MinInt32 || p.Y > math.MaxInt32 {
+				return nil, fmt.Errorf("point (%g, %g) is outside the range of tile coordinates", p.X, p.Y)
+			}
+			out[i] = TilePoint{X: int32(p.X), Y: int32(p.Y)}
+		}
+		return out, nil
+	}
+
+	var g TileGeometry
+	switch typ {
+	case spec.Tile_POINT:
+		g.GeomType = TilePointType
+		if len(cmds) != 1 || cmds[0].ID != geometry.MoveTo {
+			return nil, fmt.Errorf("point geometry must consist of a single MoveTo command")
+		}
+		part, err := points(cmds[0])
+		if err != nil {
+			return nil, err
+		}
+		g.Parts = [][]TilePoint{part}
+	case spec.Tile_LINESTRING, spec.Tile_POLYGON:
+		n := 2
+		g.GeomType = TileLineStringType
+		if typ == spec.Tile_POLYGON {
+			g.GeomType, n = TilePolygonType, 3
+		}
+
+		for i := 0; i < len(cmds); i += n {
+			if i+n > len(cmds) || cmds[i].ID != geometry.MoveTo || len(cmds[i].Points) != 1 || cmds[i+1].ID != geometry.LineTo ||
+				(n == 3 && cmds[i+2].ID != geometry.ClosePath) {
+				return nil, fmt.Errorf("%v geometry has an invalid command sequence at offset %d", g.GeomType, cmds[i].Offset)
+			}
+
+			part, err := points(geometry.Command{Points: append(cmds[i].Points, cmds[i+1].Points...)})
+			if err != nil {
+				return nil, err
+			}
+			g.Parts = append(g.Parts, part)
+		}
+	default:
+		return nil, fmt.Errorf("unknown geometry type '%d'", typ)
+	}
+
+	if len(g.Parts) == 0 {
+		return nil, fmt.Errorf("missing geometry")
+	}
+	return &g, nil
+}
+
+type tileGeometry struct {
+	Type  string        `json:"type"`
+	Parts [][]TilePoint `json:"parts"`
+}
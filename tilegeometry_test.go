@@ -0,0 +1,141 @@
+package mvt_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	mvt21 "github.com/everystreet/go-mvt"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalTileGeometry(t *testing.T) {
+	tests := []struct {
+		name     string
+		geometry mvt21.TileGeometry
+		typ      spec.Tile_GeomType
+		expected []uint32
+	}{
+		{
+			name: "points",
+			geometry: mvt21.TileGeometry{
+				GeomType: mvt21.TilePointType,
+				Parts:    [][]mvt21.TilePoint{{{X: 5, Y: 7}, {X: 3, Y: 2}}},
+			},
+			typ:      spec.Tile_POINT,
+			expected: []uint32{17, 10, 14, 3, 9},
+		},
+		{
+			name: "linestrings",
+			geometry: mvt21.TileGeometry{
+				GeomType: mvt21.TileLineStringType,
+				Parts: [][]mvt21.TilePoint{
+					{{X: 2, Y: 2}, {X: 2, Y: 10}, {X: 10, Y: 10}},
+					{{X: 1, Y: 1}, {X: 3, Y: 5}},
+				},
+			},
+			typ:      spec.Tile_LINESTRING,
+			expected: []uint32{9, 4, 4, 18, 0, 16, 16, 0, 9, 17, 17, 10, 4, 8},
+		},
+		{
+			name: "polygons",
+			geometry: mvt21.TileGeometry{
+				GeomType: mvt21.TilePolygonType,
+				Parts: [][]mvt21.TilePoint{
+					{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+					{{X: 11, Y: 11}, {X: 20, Y: 11}, {X: 20, Y: 20}, {X: 11, Y: 20}},
+					{{X: 13, Y: 13}, {X: 13, Y: 17}, {X: 17, Y: 17}, {X: 17, Y: 13}},
+				},
+			},
+			typ: spec.Tile_POLYGON,
+			expected: []uint32{
+				9, 0, 0, 26, 20, 0, 0, 20, 19, 0, 15,
+				9, 22, 2, 26, 18, 0, 0, 18, 17, 0, 15,
+				9, 4, 13, 26, 0, 8, 8, 0, 0, 7, 15,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geo := tt.geometry
+			require.NoError(t, geo.Validate())
+
+			data, err := mvt21.Marshal(mvt21.Layers{
+				"layer": mvt21.MakeLayer(4096, mvt21.Feature{Geometry: &geo}),
+			}, nil)
+			require.NoError(t, err)
+
+			var tile spec.Tile
+			require.NoError(t, proto.Unmarshal(data, &tile))
+			feature := tile.Layers[0].Features[0]
+			require.Equal(t, tt.typ, feature.GetType())
+			require.Equal(t, tt.expected, feature.Geometry)
+
+			report, err := mvt21.ValidateTile(data)
+			require.NoError(t, err)
+			require.Empty(t, report)
+
+			encoded, err := json.Marshal(geo)
+			require.NoError(t, err)
+
+			var decoded mvt21.TileGeometry
+			require.NoError(t, json.Unmarshal(encoded, &decoded))
+			require.Equal(t, geo, decoded)
+		})
+	}
+}
+
+func TestTileGeometryValidate(t *testing.T) {
+	square := []mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}
+	hole := []mvt21.TilePoint{{X: 2, Y: 2}, {X: 2, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 2}}
+
+	for name, geo := range map[string]mvt21.TileGeometry{
+		"no parts":     {GeomType: mvt21.TilePointType},
+		"no points":    {GeomType: mvt21.TilePointType, Parts: [][]mvt21.TilePoint{{}}},
+		"unknown type": {Parts: [][]mvt21.TilePoint{square}},
+		"short line":   {GeomType: mvt21.TileLineStringType, Parts: [][]mvt21.TilePoint{{{X: 1, Y: 1}}}},
+		"zero-length line segment": {
+			GeomType: mvt21.TileLineStringType,
+			Parts:    [][]mvt21.TilePoint{{{X: 1, Y: 1}, {X: 1, Y: 1}}},
+		},
+		"short ring": {GeomType: mvt21.TilePolygonType, Parts: [][]mvt21.TilePoint{square[:2]}},
+		"closed ring": {
+			GeomType: mvt21.TilePolygonType,
+			Parts:    [][]mvt21.TilePoint{append(square, square[0])},
+		},
+		"zero area": {
+			GeomType: mvt21.TilePolygonType,
+			Parts:    [][]mvt21.TilePoint{{{X: 0, Y: 0}, {X: 5, Y: 0}, {X: 10, Y: 0}}},
+		},
+		"hole first": {GeomType: mvt21.TilePolygonType, Parts: [][]mvt21.TilePoint{hole, square}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, geo.Validate())
+
+			_, err := mvt21.Marshal(mvt21.Layers{
+				"layer": mvt21.MakeLayer(4096, mvt21.Feature{Geometry: &geo}),
+			}, nil)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestKeepRing(t *testing.T) {
+	exterior := []mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}
+	hole := []mvt21.TilePoint{{X: 2, Y: 2}, {X: 2, Y: 8}, {X: 8, Y: 8}, {X: 8, Y: 2}}
+	collapsed := []mvt21.TilePoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 5, Y: 0}}
+
+	var kept bool
+	require.True(t, mvt21.KeepRing(exterior, exterior, &kept))
+	require.True(t, mvt21.KeepRing(hole, hole, &kept))
+
+	// A ring that winds the other way once transformed is dropped.
+	require.False(t, mvt21.KeepRing(hole, exterior, &kept))
+
+	// The holes of a collapsed exterior ring are dropped with it.
+	require.False(t, mvt21.KeepRing(exterior, collapsed, &kept))
+	require.False(t, mvt21.KeepRing(hole, hole, &kept))
+	require.True(t, mvt21.KeepRing(exterior, exterior, &kept))
+}
@@ -0,0 +1,206 @@
+package tiler
+
+import mvt "github.com/everystreet/go-mvt"
+
+// axis is the axis that features are clipped along.
+type axis uint8
+
+const (
+	xAxis axis = iota
+	yAxis
+)
+
+func (p point) coord(a axis) float64 {
+	if a == xAxis {
+		return p.x
+	}
+	return p.y
+}
+
+func (f *feature) bounds(a axis) (min, max float64) {
+	if a == xAxis {
+		return f.minX, f.maxX
+	}
+	return f.minY, f.maxY
+}
+
+// clip returns the parts of the features in [k1, k2) along the axis, where k1 and k2 are divided by scale.
+// minAll and maxAll bound every feature along the axis. It returns nil if no features remain.
+// Features entirely inside the range are returned as they are.
+func clip(features []*feature, scale, k1, k2 float64, a axis, minAll, maxAll float64) []*feature {
+	k1 /= scale
+	k2 /= scale
+
+	if minAll >= k1 && maxAll < k2 {
+		return features
+	} else if maxAll < k1 || minAll >= k2 {
+		return nil
+	}
+
+	var clipped []*feature
+	for _, f := range features {
+		min, max := f.bounds(a)
+		if min >= k1 && max < k2 {
+			clipped = append(clipped, f)
+			continue
+		} else if max < k1 || min >= k2 {
+			continue
+		}
+
+		var parts [][]path
+		switch f.typ {
+		case mvt.TilePointType:
+			if points := clipPoints(f.parts[0][0], k1, k2, a); len(points.points) > 0 {
+				parts = [][]path{{points}}
+			}
+		case mvt.TileLineStringType:
+			for _, part := range f.parts {
+				for _, line := range clipLine(part[0], k1, k2, a, false, nil) {
+					parts = append(parts, []path{line})
+				}
+			}
+		case mvt.TilePolygonType:
+			for _, part := range f.parts {
+				var rings []path
+				for i, ring := range part {
+					clippedRing := clipLine(ring, k1, k2, a, true, nil)
+					if len(clippedRing) == 0 {
+						if i == 0 {
+							break
+						}
+						continue
+					}
+					rings = append(rings, clippedRing[0])
+				}
+
+				if len(rings) > 0 {
+					parts = append(parts, rings)
+				}
+			}
+		}
+
+		if out := newFeature(f, parts); out != nil {
+			clipped = append(clipped, out)
+		}
+	}
+	return clipped
+}
+
+func clipPoints(p path, k1, k2 float64, a axis) path {
+	out := path{size: p.size}
+	for _, pt := range p.points {
+		if c := pt.coord(a); c >= k1 && c <= k2 {
+			out.points = append(out.points, pt)
+		}
+	}
+	return out
+}
+
+// clipLine appends the parts of the line in [k1, k2] along the axis to out.
+// A ring is clipped to a single ring, which is closed along the clip edges.
+func clipLine(p path, k1, k2 float64, a axis, ring bool, out []path) []path {
+	slice := path{size: p.size}
+
+	for i := 0; i+1 < len(p.points); i++ {
+		pa, pb := p.points[i], p.points[i+1]
+		ca, cb := pa.coord(a), pb.coord(a)
+		exited := false
+
+		if ca < k1 {
+			// The line enters the range across k1.
+			if cb > k1 {
+				slice.points = append(slice.points, intersect(pa, pb, k1, a))
+			}
+		} else if ca > k2 {
+			// The line enters the range across k2.
+			if cb < k2 {
+				slice.points = append(slice.points, intersect(pa, pb, k2, a))
+			}
+		} else {
+			slice.points = append(slice.points, pa)
+		}
+
+		if cb < k1 && ca >= k1 {
+			// The line leaves the range across k1.
+			slice.points = append(slice.points, intersect(pa, pb, k1, a))
+			exited = true
+		}
+		if cb > k2 && ca <= k2 {
+			// The line leaves the range across k2.
+			slice.points = append(slice.points, intersect(pa, pb, k2, a))
+			exited = true
+		}
+
+		if !ring && exited {
+			out = append(out, slice)
+			slice = path{size: p.size}
+		}
+	}
+
+	if n := len(p.points); n > 0 {
+		if last := p.points[n-1]; last.coord(a) >= k1 && last.coord(a) <= k2 {
+			slice.points = append(slice.points, last)
+		}
+	}
+
+	// Close the ring if its end points were clipped apart.
+	if n := len(slice.points); ring && n > 1 && slice.points[n-1] != slice.points[0] {
+		slice.points = append(slice.points, slice.points[0])
+	}
+
+	if len(slice.points) > 0 {
+		out = append(out, slice)
+	}
+	return out
+}
+
+// intersect returns the point where the segment a-b crosses k along the axis.
+// It is always kept by simplification.
+func intersect(a, b point, k float64, ax axis) point {
+	if ax == xAxis {
+		t := (k - a.x) / (b.x - a.x)
+		return point{x: k, y: a.y + (b.y-a.y)*t, importance: 1}
+	}
+	t := (k - a.y) / (b.y - a.y)
+	return point{x: a.x + (b.x-a.x)*t, y: k, importance: 1}
+}
+
+// wrap returns the features with copies of those within buffer of the antimeridian on the opposite side of the world,
+// and with parts beyond the antimeridian moved into the world.
+func wrap(features []*feature, buffer float64) []*feature {
+	left := clip(features, 1, -1-buffer, buffer, xAxis, -1, 2)
+	right := clip(features, 1, 1-buffer, 2+buffer, xAxis, -1, 2)
+	if left == nil && right == nil {
+		return features
+	}
+
+	merged := clip(features, 1, -buffer, 1+buffer, xAxis, -1, 2)
+	if left != nil {
+		merged = append(shift(left, 1), merged...)
+	}
+	if right != nil {
+		merged = append(merged, shift(right, -1)...)
+	}
+	return merged
+}
+
+// shift returns copies of the features moved along the X axis, which are marked as wrapped.
+func shift(features []*feature, offset float64) []*feature {
+	out := make([]*feature, len(features))
+	for i, f := range features {
+		parts := make([][]path, len(f.parts))
+		for j, part := range f.parts {
+			parts[j] = make([]path, len(part))
+			for k, p := range part {
+				points := make([]point, len(p.points))
+				for l, pt := range p.points {
+					points[l] = point{x: pt.x + offset, y: pt.y, importance: pt.importance}
+				}
+				parts[j][k] = path{points: points, size: p.size}
+			}
+		}
+		out[i] = newFeature(f, parts)
+		out[i].wrapped = true
+	}
+	return out
+}
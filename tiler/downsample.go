@@ -0,0 +1,279 @@
+package tiler
+
+import (
+	"fmt"
+	"slices"
+
+	mvt "github.com/everystreet/go-mvt"
+)
+
+// DownsampleOptions configures how a tile is built from its four children, to backfill a zoom from the zoom above it.
+type DownsampleOptions struct {
+	// Compression of the tile.
+	Compression mvt.Compression
+
+	// LayerConfigs configures each layer of the tile at its zoom, once the children are combined.
+	// Layers configured to simplify, reduce or coalesce have their features simplified, thinned and merged.
+	LayerConfigs mvt.LayerConfigs
+
+	// Simplify simplifies the lines and polygons of each layer that LayerConfigs doesn't configure to simplify,
+	// as detail is lost when the children are halved. If nil, DefaultDownsampleSimplification is used.
+	Simplify *mvt.Simplification
+
+	// Reduce removes the tiny polygons and short lines of each layer that LayerConfigs doesn't configure to reduce,
+	// so that features too small to see at the zoom are thinned out. If nil, DefaultDownsampleReduction is used.
+	Reduce *mvt.Reduction
+}
+
+// DefaultDownsampleSimplification simplifies lines and polygons by a unit of the extent of a layer.
+var DefaultDownsampleSimplification = mvt.Simplification{Tolerance: 1}
+
+// DefaultDownsampleReduction removes polygons smaller than a 2 by 2 square, and lines shorter than 2, in tile coordinates.
+var DefaultDownsampleReduction = mvt.Reduction{MinArea: 4, MinLength: 2}
+
+// Downsample decodes the encoded children of the tile id, in the order of TileID.Children,
+// and returns the tile encoded without compression. A missing child is nil.
+func Downsample(children [4][]byte, id TileID) ([]byte, error) {
+	return DownsampleOptions{}.Tile(children, id)
+}
+
+// Tile decodes the encoded children of the tile id, in the order of TileID.Children,
+// and returns the tile, encoded. A missing child is nil.
+func (o DownsampleOptions) Tile(children [4][]byte, id TileID) ([]byte, error) {
+	var decoded [4]mvt.Layers
+	for i, data := range children {
+		if data == nil {
+			continue
+		}
+
+		var err error
+		if decoded[i], err = (mvt.UnmarshalOptions{TileCoordinates: true}).Unmarshal(data); err != nil {
+			return nil, fmt.Errorf("child %v: %w", id.Children()[i], err)
+		}
+	}
+
+	layers, err := o.Layers(decoded, id)
+	if err != nil {
+		return nil, err
+	}
+	return mvt.MarshalOptions{Compression: o.Compression}.Marshal(layers)
+}
+
+// Layers returns the layers of the tile id, from the decoded layers of its children, in the order of TileID.Children.
+// The geometries of the layers must be TileGeometry, as decoded with UnmarshalOptions.TileCoordinates.
+// Layers are matched by name, and must have the same extent in each child.
+//
+// Geometries are clipped to the extent of their child, without its buffer, and scaled into the extent of the tile,
+// entirely in integer tile coordinates. Features with equal IDs, geometry types and tags are then merged
+// as Coalesce does with CoalesceByID, so lines cut by the edges of the children are joined,
+// and polygons cut by them are dissolved. Features without IDs are only merged if their tags are equal,
+// and they touch an edge that their child shares with another.
+// The layers are then configured for the zoom of the tile by LayerConfigs, and simplified and reduced
+// by Simplify and Reduce unless LayerConfigs configures otherwise.
+//
+// An UnknownGeometry can't be clipped, so its feature is removed, along with features and layers left empty.
+// The supplied layers are not modified.
+func (o DownsampleOptions) Layers(children [4]mvt.Layers, id TileID) (mvt.Layers, error) {
+	if !id.Valid() || id.Z == MaxZoom {
+		return nil, fmt.Errorf("tile %v has no children", id)
+	} else if err := o.LayerConfigs.Validate(); err != nil {
+		return nil, err
+	}
+
+	simplify, reduce := &DefaultDownsampleSimplification, &DefaultDownsampleReduction
+	if o.Simplify != nil {
+		if err := o.Simplify.Validate(); err != nil {
+			return nil, fmt.Errorf("simplification invalid: %w", err)
+		}
+		simplify = o.Simplify
+	}
+	if o.Reduce != nil {
+		if err := o.Reduce.Validate(); err != nil {
+			return nil, fmt.Errorf("reduction invalid: %w", err)
+		}
+		reduce = o.Reduce
+	}
+
+	out := make(mvt.Layers)
+	for i, layers := range children {
+		qx, qy := int64(i%2), int64(i/2)
+
+		for name, layer := range layers {
+			combined, ok := out[name]
+			if !ok {
+				combined = mvt.MakeLayer(layer.Extent)
+			} else if combined.Extent != layer.Extent {
+				return nil, fmt.Errorf("layer '%s' has extent %d in child %v, and %d in another child",
+					name, layer.Extent, id.Children()[i], combined.Extent)
+			}
+
+			extent := int64(layer.Extent)
+			clip := zoom{max: extent}
+
+			for j, f := range layer.Features {
+				var g *mvt.TileGeometry
+				switch geo := f.Geometry.(type) {
+				case *mvt.TileGeometry:
+					g = geo
+				case *mvt.UnknownGeometry:
+					continue
+				default:
+					return nil, &mvt.FeatureError{Layer: name, Index: j, ID: f.ID, Offset: -1,
+						Err: fmt.Errorf("geometry must be a TileGeometry, have %T", f.Geometry)}
+				}
+
+				if g == nil {
+					continue
+				} else if g.GeomType == mvt.TilePointType {
+					// Points on an edge shared with another child are only taken from the child after it.
+					g = sharedPoints(g, layer.Extent, qx == 1, qy == 1)
+				}
+
+				if g == nil {
+					continue
+				} else if g = clip.geometry(g); g == nil {
+					continue
+				}
+
+				seam := touchesSeam(g, layer.Extent, qx == 1, qy == 1)
+				if g = halve(g, qx*extent, qy*extent); g == nil {
+					continue
+				} else if !f.ID.IsSet() && !seam {
+					f.Geometry = unmerged{g}
+				} else {
+					f.Geometry = g
+				}
+				combined.Features = append(combined.Features, f)
+			}
+			out[name] = combined
+		}
+	}
+
+	configs := make(mvt.LayerConfigs, len(out))
+	for name, layer := range out {
+		layer = mvt.Coalesce{IDs: mvt.CoalesceByID, Dissolve: true}.Layer(layer)
+		for i, f := range layer.Features {
+			switch g := f.Geometry.(type) {
+			case unmerged:
+				layer.Features[i].Geometry = g.TileGeometry
+			case *mvt.TileGeometry:
+				// The points of a feature taken from several children are a single part, as they would be decoded.
+				if g.GeomType == mvt.TilePointType && len(g.Parts) > 1 {
+					layer.Features[i].Geometry = &mvt.TileGeometry{
+						GeomType: g.GeomType,
+						Parts:    [][]mvt.TilePoint{slices.Concat(g.Parts...)},
+					}
+				}
+			}
+		}
+		out[name] = layer
+
+		cfg := o.LayerConfigs[name]
+		if cfg.Simplify == nil {
+			cfg.Simplify = simplify
+		}
+		if cfg.Reduce == nil {
+			cfg.Reduce = reduce
+		}
+		configs[name] = cfg
+	}
+
+	out = configs.Layers(out, id.Z)
+	for name, layer := range out {
+		if len(layer.Features) == 0 {
+			delete(out, name)
+		}
+	}
+	return out, nil
+}
+
+// unmerged holds the geometry of a feature that is not merged with others, as Coalesce only merges a TileGeometry.
+type unmerged struct {
+	*mvt.TileGeometry
+}
+
+// touchesSeam returns true if a line or polygon clipped to the extent of a child has a point on an edge
+// that it shares with another child.
+func touchesSeam(g *mvt.TileGeometry, extent uint32, right, bottom bool) bool {
+	if g.GeomType == mvt.TilePointType {
+		return false
+	}
+
+	x, y := int32(extent), int32(extent)
+	if right {
+		x = 0
+	}
+	if bottom {
+		y = 0
+	}
+
+	for _, part := range g.Parts {
+		for _, p := range part {
+			if p.X == x || p.Y == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sharedPoints returns the point geometry without the points on the right and bottom edges of the extent,
+// unless they are the edges of the tile, or nil if no points remain.
+func sharedPoints(g *mvt.TileGeometry, extent uint32, right, bottom bool) *mvt.TileGeometry {
+	out := mvt.TileGeometry{GeomType: g.GeomType}
+	for _, part := range g.Parts {
+		var points []mvt.TilePoint
+		for _, p := range part {
+			if (right || p.X != int32(extent)) && (bottom || p.Y != int32(extent)) {
+				points = append(points, p)
+			}
+		}
+		if len(points) > 0 {
+			out.Parts = append(out.Parts, points)
+		}
+	}
+
+	if len(out.Parts) == 0 {
+		return nil
+	}
+	return &out
+}
+
+// halve returns the geometry of a child, offset by its position in the tile and scaled to half its size,
+// or nil if nothing remains. Coordinates are rounded half up.
+// Repeated points are removed, and lines and rings that collapse are removed, with the holes of an exterior ring.
+func halve(g *mvt.TileGeometry, x, y int64) *mvt.TileGeometry {
+	out := mvt.TileGeometry{GeomType: g.GeomType}
+	exterior := false
+
+	for _, part := range g.Parts {
+		points := make([]mvt.TilePoint, 0, len(part))
+		for _, p := range part {
+			hp := mvt.TilePoint{X: int32((int64(p.X) + x + 1) >> 1), Y: int32((int64(p.Y) + y + 1) >> 1)}
+			if g.GeomType == mvt.TilePointType || len(points) == 0 || points[len(points)-1] != hp {
+				points = append(points, hp)
+			}
+		}
+
+		switch g.GeomType {
+		case mvt.TilePointType:
+			out.Parts = append(out.Parts, points)
+		case mvt.TileLineStringType:
+			if len(points) >= 2 {
+				out.Parts = append(out.Parts, points)
+			}
+		case mvt.TilePolygonType:
+			if n := len(points); n > 1 && points[n-1] == points[0] {
+				points = points[:n-1]
+			}
+			if mvt.KeepRing(part, points, &exterior) {
+				out.Parts = append(out.Parts, points)
+			}
+		}
+	}
+
+	if len(out.Parts) == 0 {
+		return nil
+	}
+	return &out
+}
@@ -0,0 +1,148 @@
+package tiler_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt "github.com/everystreet/go-mvt"
+	"github.com/everystreet/go-mvt/tiler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownsample(t *testing.T) {
+	id := tiler.TileID{Z: 14, X: 8000, Y: 5000}
+	layers := mvt.Layers{
+		"places": mvt.MakeLayer(4096,
+			tileFeature(1, mvt.TilePointType, []mvt.TilePoint{{X: 2048, Y: 100}, {X: 3000, Y: 3000}}),
+		),
+		"roads": mvt.MakeLayer(4096,
+			tileFeature(2, mvt.TileLineStringType, []mvt.TilePoint{{X: 500, Y: 1000}, {X: 3000, Y: 1000}, {X: 3000, Y: 3000}}),
+		),
+		"parks": mvt.MakeLayer(4096,
+			tileFeature(3, mvt.TilePolygonType,
+				[]mvt.TilePoint{{X: 1000, Y: 1000}, {X: 3000, Y: 1000}, {X: 3000, Y: 3000}, {X: 1000, Y: 3000}},
+				[]mvt.TilePoint{{X: 1500, Y: 1500}, {X: 1500, Y: 2500}, {X: 2500, Y: 2500}, {X: 2500, Y: 1500}},
+			),
+		),
+	}
+
+	var children [4]mvt.Layers
+	for i, child := range id.Children() {
+		var err error
+		children[i], err = tiler.OverzoomOptions{Buffer: 64}.Layers(layers, id, child)
+		require.NoError(t, err)
+	}
+
+	// The points added where the children cut the road are simplified away.
+	out, err := tiler.DownsampleOptions{}.Layers(children, id)
+	require.NoError(t, err)
+	require.Equal(t, layers, out)
+
+	t.Run("unsimplified", func(t *testing.T) {
+		out, err := tiler.DownsampleOptions{
+			Simplify: &mvt.Simplification{},
+			Reduce:   &mvt.Reduction{},
+		}.Layers(children, id)
+		require.NoError(t, err)
+		require.Equal(t, mvt.Layers{
+			"places": layers["places"],
+			"roads": mvt.MakeLayer(4096,
+				tileFeature(2, mvt.TileLineStringType,
+					[]mvt.TilePoint{{X: 500, Y: 1000}, {X: 2048, Y: 1000}, {X: 3000, Y: 1000}, {X: 3000, Y: 2048}, {X: 3000, Y: 3000}}),
+			),
+			"parks": layers["parks"],
+		}, out)
+	})
+
+	t.Run("without ids", func(t *testing.T) {
+		square := func(x, y, size int32) mvt.Feature {
+			return mvt.Feature{
+				Tags: geojson.PropertyList{{Name: "kind", Value: "park"}},
+				Geometry: &mvt.TileGeometry{
+					GeomType: mvt.TilePolygonType,
+					Parts:    [][]mvt.TilePoint{{{X: x, Y: y}, {X: x + size, Y: y}, {X: x + size, Y: y + size}, {X: x, Y: y + size}}},
+				},
+			}
+		}
+
+		var children [4]mvt.Layers
+		for i, child := range id.Children() {
+			var err error
+			children[i], err = tiler.OverzoomOptions{}.Layers(mvt.Layers{"parks": mvt.MakeLayer(4096,
+				square(100, 100, 200),
+				square(500, 100, 200),
+				square(1000, 1000, 2000),
+				square(3900, 3900, 1),
+			)}, id, child)
+			require.NoError(t, err)
+		}
+
+		// Only the pieces of the park cut by the children are merged, and the tiny park is removed.
+		out, err := tiler.DownsampleOptions{}.Layers(children, id)
+		require.NoError(t, err)
+		require.Equal(t, mvt.Layers{"parks": mvt.MakeLayer(4096,
+			square(100, 100, 200),
+			square(500, 100, 200),
+			square(1000, 1000, 2000),
+		)}, out)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		out, err := tiler.DownsampleOptions{LayerConfigs: mvt.LayerConfigs{
+			"roads": {Simplify: &mvt.Simplification{Tolerance: 1}},
+			"parks": {Reduce: &mvt.Reduction{MinArea: 4000000}},
+		}}.Layers(children, id)
+		require.NoError(t, err)
+		require.Equal(t, mvt.Layers{
+			"places": layers["places"],
+			"roads":  layers["roads"],
+		}, out)
+	})
+
+	t.Run("encoded", func(t *testing.T) {
+		var encoded [4][]byte
+		for i, layers := range children[:3] {
+			var err error
+			encoded[i], err = mvt.MarshalOptions{Compression: mvt.Gzip}.Marshal(layers)
+			require.NoError(t, err)
+		}
+
+		data, err := tiler.Downsample(encoded, id)
+		require.NoError(t, err)
+
+		decoded, err := mvt.UnmarshalOptions{TileCoordinates: true}.Unmarshal(data)
+		require.NoError(t, err)
+
+		expected, err := tiler.DownsampleOptions{}.Layers([4]mvt.Layers{children[0], children[1], children[2]}, id)
+		require.NoError(t, err)
+		require.Equal(t, expected, decoded)
+		require.Equal(t, [][]mvt.TilePoint{{{X: 2048, Y: 100}}}, decoded["places"].Features[0].Geometry.(*mvt.TileGeometry).Parts)
+
+		report, err := mvt.ValidateTile(data)
+		require.NoError(t, err)
+		require.True(t, report.Valid(), report)
+	})
+}
+
+func TestDownsampleErrors(t *testing.T) {
+	id := tiler.TileID{Z: 14, X: 8000, Y: 5000}
+
+	_, err := tiler.DownsampleOptions{}.Layers([4]mvt.Layers{}, tiler.TileID{Z: tiler.MaxZoom})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "has no children")
+
+	_, err = tiler.DownsampleOptions{}.Layers([4]mvt.Layers{
+		{"places": mvt.MakeLayer(4096)},
+		{"places": mvt.MakeLayer(512)},
+	}, id)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "extent")
+
+	_, err = tiler.DownsampleOptions{}.Layers([4]mvt.Layers{
+		{"places": mvt.MakeLayer(4096, mvt.Feature{Geometry: &geojson.Point{}})},
+	}, id)
+	var featureErr *mvt.FeatureError
+	require.True(t, errors.As(err, &featureErr))
+	require.Equal(t, mvt.LayerName("places"), featureErr.Layer)
+}
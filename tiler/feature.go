@@ -0,0 +1,193 @@
+package tiler
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt "github.com/everystreet/go-mvt"
+)
+
+// point is a position in the Web Mercator world, which is the unit square with the Y axis pointing down.
+type point struct {
+	x, y float64
+	// importance is the squared distance the point moves a simplified line if it is removed.
+	// A point is kept by simplification with a squared tolerance below its importance.
+	importance float64
+}
+
+// path is a line, or a ring that is closed by repeating its first point.
+type path struct {
+	points []point
+	// size is the length of a line, or the area of a ring, before clipping.
+	size float64
+}
+
+// feature is a feature in world coordinates.
+type feature struct {
+	// layer is the index of the feature's layer name.
+	layer int
+	typ   mvt.TileGeometryType
+	id    mvt.OptionalUint64
+	tags  geojson.PropertyList
+	// wrapped is true for a copy of a feature on the opposite side of the antimeridian.
+	wrapped bool
+
+	// parts holds a single part of every point, a part for each line,
+	// or a part for each polygon, which consists of its exterior ring followed by its holes.
+	parts [][]path
+
+	minX, minY, maxX, maxY float64
+}
+
+// newFeature returns a feature with the geometry of f, or nil if parts is empty.
+func newFeature(f *feature, parts [][]path) *feature {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	out := &feature{
+		layer:   f.layer,
+		typ:     f.typ,
+		id:      f.id,
+		tags:    f.tags,
+		wrapped: f.wrapped,
+		parts:   parts,
+	}
+	out.bound()
+	return out
+}
+
+// bound sets the bounding box of the feature.
+func (f *feature) bound() {
+	f.minX, f.minY = math.Inf(1), math.Inf(1)
+	f.maxX, f.maxY = math.Inf(-1), math.Inf(-1)
+	for _, part := range f.parts {
+		for _, path := range part {
+			for _, p := range path.points {
+				f.minX, f.maxX = math.Min(f.minX, p.x), math.Max(f.maxX, p.x)
+				f.minY, f.maxY = math.Min(f.minY, p.y), math.Max(f.maxY, p.y)
+			}
+		}
+	}
+}
+
+// points returns the number of points in the feature.
+func (f *feature) points() int {
+	var n int
+	for _, part := range f.parts {
+		for _, path := range part {
+			n += len(path.points)
+		}
+	}
+	return n
+}
+
+// convert returns the feature projected to world coordinates,
+// with the importance of each point set for simplification down to sqTolerance.
+func convert(data mvt.Feature, layer int, sqTolerance float64) (*feature, error) {
+	f := feature{
+		layer: layer,
+		id:    data.ID,
+		tags:  data.Tags,
+	}
+
+	switch g := data.Geometry.(type) {
+	case *geojson.Point:
+		f.typ = mvt.TilePointType
+		f.parts = [][]path{{convertPoints(geojson.Position(*g))}}
+	case *geojson.MultiPoint:
+		f.typ = mvt.TilePointType
+		f.parts = [][]path{{convertPoints(*g...)}}
+	case *geojson.LineString:
+		f.typ = mvt.TileLineStringType
+		f.parts = [][]path{{convertLine(*g, sqTolerance)}}
+	case *geojson.MultiLineString:
+		f.typ = mvt.TileLineStringType
+		for _, line := range *g {
+			f.parts = append(f.parts, []path{convertLine(line, sqTolerance)})
+		}
+	case *geojson.Polygon:
+		f.typ = mvt.TilePolygonType
+		f.parts = [][]path{convertPolygon(*g, sqTolerance)}
+	case *geojson.MultiPolygon:
+		f.typ = mvt.TilePolygonType
+		for _, polygon := range *g {
+			f.parts = append(f.parts, convertPolygon(polygon, sqTolerance))
+		}
+	case nil:
+		return nil, fmt.Errorf("missing geometry")
+	default:
+		return nil, fmt.Errorf("'%T' is not allowed", g)
+	}
+
+	f.bound()
+	return &f, nil
+}
+
+func convertPoints(positions ...geojson.Position) path {
+	out := path{points: make([]point, len(positions))}
+	for i, pos := range positions {
+		out.points[i] = project(pos)
+	}
+	return out
+}
+
+func convertLine(positions []geojson.Position, sqTolerance float64) path {
+	out := convertPoints(positions...)
+	for i := 1; i < len(out.points); i++ {
+		a, b := out.points[i-1], out.points[i]
+		out.size += math.Hypot(b.x-a.x, b.y-a.y)
+	}
+
+	markImportance(out.points, sqTolerance)
+	return out
+}
+
+func convertPolygon(rings [][]geojson.Position, sqTolerance float64) []path {
+	out := make([]path, 0, len(rings))
+	for i, ring := range rings {
+		if len(ring) == 0 {
+			continue
+		}
+
+		p := convertPoints(ring...)
+		if first := p.points[0]; p.points[len(p.points)-1] != first {
+			p.points = append(p.points, first)
+		}
+
+		// Exterior rings have a positive area in world coordinates, and holes a negative area.
+		area := ringArea(p.points)
+		if (i == 0) != (area > 0) {
+			reverse(p.points)
+		}
+		p.size = math.Abs(area)
+
+		markImportance(p.points, sqTolerance)
+		out = append(out, p)
+	}
+	return out
+}
+
+func project(pos geojson.Position) point {
+	return point{
+		x: mercatorX(pos.Lng.Degrees()),
+		y: mercatorY(pos.Lat.Degrees()),
+	}
+}
+
+// ringArea returns the signed area of the closed ring.
+func ringArea(points []point) float64 {
+	var sum float64
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		sum += a.x*b.y - b.x*a.y
+	}
+	return sum / 2
+}
+
+func reverse[T any](s []T) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,244 @@
+package tiler
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// GenerateOptions configures how Generate produces the tiles of a Tiler.
+type GenerateOptions struct {
+	// MinZoom and MaxZoom are the lowest and highest zooms of the generated tiles.
+	// MaxZoom must be at most the MaxZoom of the Tiler.
+	MinZoom, MaxZoom uint32
+
+	// Concurrency is the maximum number of tiles generated at once.
+	// If zero or one, tiles are generated one at a time. If negative, GOMAXPROCS is used.
+	Concurrency int
+
+	// Progress, if not nil, is called after each tile with the progress so far.
+	// Calls are not concurrent, and generation waits for each to return.
+	Progress func(Progress)
+}
+
+// Progress counts the tiles handled by Generate.
+type Progress struct {
+	// Written is the number of tiles written to the sink.
+	Written int64
+	// Empty is the number of tiles skipped because they have no features.
+	Empty int64
+	// Existing is the number of tiles skipped because a ResumableSink already has them.
+	Existing int64
+	// Bytes is the total size of the tiles written to the sink.
+	Bytes int64
+}
+
+// Generate writes every tile between the minimum and maximum zoom that has features to the sink.
+// Only the tiles that cover the features are visited: if a tile has nothing in it, neither do its descendants.
+// Descendants of the index are cut as they are visited, without adding them to the index.
+//
+// If the sink is a ResumableSink, tiles that it already has are not generated again,
+// so a generation that was interrupted can be resumed by calling Generate with the same options.
+//
+// Generation stops at the first error, or if ctx is done, and the progress up to that point is returned.
+func (t *Tiler) Generate(ctx context.Context, sink Sink, opts GenerateOptions) (Progress, error) {
+	if opts.MaxZoom > t.opts.MaxZoom {
+		return Progress{}, fmt.Errorf("max zoom must be at most the tiler's max zoom of %d", t.opts.MaxZoom)
+	} else if opts.MinZoom > opts.MaxZoom {
+		return Progress{}, fmt.Errorf("min zoom must be at most max zoom")
+	}
+
+	t.mu.Lock()
+	root := t.tiles[TileID{}]
+	t.mu.Unlock()
+	if root == nil {
+		return Progress{}, nil
+	}
+
+	g := generator{
+		t:     t,
+		ctx:   ctx,
+		sink:  sink,
+		opts:  opts,
+		stack: []node{{root, TileID{}}},
+	}
+	g.resumable, _ = sink.(ResumableSink)
+	g.cond = sync.NewCond(&g.mu)
+
+	var wg sync.WaitGroup
+	for w := workers(opts.Concurrency); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.work()
+		}()
+	}
+	wg.Wait()
+
+	return g.progress, g.err
+}
+
+// workers returns the number of goroutines used with the configured concurrency.
+func workers(concurrency int) int {
+	if concurrency < 0 {
+		return runtime.GOMAXPROCS(0)
+	} else if concurrency < 1 {
+		return 1
+	}
+	return concurrency
+}
+
+// node is a tile waiting to be generated.
+type node struct {
+	tile *tile
+	id   TileID
+}
+
+// generator holds the state of a call to Generate, which is shared by its workers.
+// Tiles waiting to be generated are kept on a stack, so that the pyramid is visited depth first,
+// and the features of only a few tiles at each zoom are held at once.
+type generator struct {
+	t         *Tiler
+	ctx       context.Context
+	sink      Sink
+	resumable ResumableSink
+	opts      GenerateOptions
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	stack    []node
+	active   int
+	progress Progress
+	err      error
+}
+
+// work generates tiles from the stack until it is empty and no other worker can add to it, or an error occurs.
+func (g *generator) work() {
+	defer g.cond.Broadcast()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for {
+		for len(g.stack) == 0 && g.active > 0 && g.err == nil {
+			g.cond.Wait()
+		}
+		if len(g.stack) == 0 || g.err != nil {
+			return
+		}
+
+		n := g.stack[len(g.stack)-1]
+		g.stack = g.stack[:len(g.stack)-1]
+		g.active++
+		g.mu.Unlock()
+
+		var p Progress
+		children, err := g.generate(n, &p)
+
+		g.mu.Lock()
+		g.active--
+		if err != nil {
+			if g.err == nil {
+				g.err = err
+			}
+			return
+		}
+
+		// Children are pushed in reverse, so that they are visited in the order of TileID.Children.
+		for i := len(children) - 1; i >= 0; i-- {
+			if children[i].tile != nil {
+				g.stack = append(g.stack, children[i])
+			}
+		}
+
+		if p != (Progress{}) {
+			g.progress.Written += p.Written
+			g.progress.Empty += p.Empty
+			g.progress.Existing += p.Existing
+			g.progress.Bytes += p.Bytes
+			if g.opts.Progress != nil {
+				g.opts.Progress(g.progress)
+			}
+		}
+		g.cond.Broadcast()
+	}
+}
+
+// generate writes the tile to the sink, if it is in the range of zooms, and adds the outcome to p.
+// It returns the children of the tile that have features.
+func (g *generator) generate(n node, p *Progress) ([4]node, error) {
+	if err := g.ctx.Err(); err != nil {
+		return [4]node{}, err
+	}
+
+	if n.id.Z >= g.opts.MinZoom {
+		if err := g.write(n, p); err != nil {
+			return [4]node{}, fmt.Errorf("tile %v: %w", n.id, err)
+		}
+	}
+
+	if n.id.Z == g.opts.MaxZoom {
+		return [4]node{}, nil
+	}
+	return g.children(n), nil
+}
+
+func (g *generator) write(n node, p *Progress) error {
+	if g.resumable != nil {
+		if ok, err := g.resumable.HasTile(g.ctx, n.id); err != nil {
+			return err
+		} else if ok {
+			p.Existing++
+			return nil
+		}
+	}
+
+	layers, err := g.t.layers(g.ctx, n.tile, n.id)
+	if err != nil {
+		return err
+	}
+
+	data, err := g.t.encode(g.ctx, layers)
+	if err != nil {
+		return err
+	} else if data == nil {
+		p.Empty++
+		return nil
+	}
+
+	if err := g.sink.WriteTile(g.ctx, n.id, data); err != nil {
+		return err
+	}
+	p.Written++
+	p.Bytes += int64(len(data))
+	return nil
+}
+
+// children returns the children of the tile. Children without features have a nil tile.
+// Children in the index are used as they are, and the rest are cut from the tile.
+func (g *generator) children(n node) [4]node {
+	var children [4]node
+	ids := n.id.Children()
+	for i, id := range ids {
+		children[i].id = id
+	}
+
+	g.t.mu.Lock()
+	source := n.tile.source
+	if source == nil {
+		for i, id := range ids {
+			children[i].tile = g.t.tiles[id]
+		}
+	}
+	g.t.mu.Unlock()
+
+	if source != nil {
+		for i, features := range g.t.cut(n.tile, source, n.id) {
+			if features != nil {
+				children[i].tile = g.t.newTile(features, n.id.Z+1)
+			}
+		}
+	}
+	return children
+}
@@ -0,0 +1,208 @@
+package tiler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt "github.com/everystreet/go-mvt"
+	"github.com/everystreet/go-mvt/tiler"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink stores tiles in a map.
+type memorySink struct {
+	mu    sync.Mutex
+	tiles map[tiler.TileID][]byte
+}
+
+func (s *memorySink) WriteTile(_ context.Context, id tiler.TileID, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tiles == nil {
+		s.tiles = make(map[tiler.TileID][]byte)
+	}
+	if _, ok := s.tiles[id]; ok {
+		return fmt.Errorf("tile %v written twice", id)
+	}
+	s.tiles[id] = data
+	return nil
+}
+
+// failingSink fails once it has written a number of tiles.
+type failingSink struct {
+	tiler.DirSink
+
+	mu      sync.Mutex
+	written int
+	limit   int
+}
+
+var errSinkFull = errors.New("sink is full")
+
+func (s *failingSink) WriteTile(ctx context.Context, id tiler.TileID, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.written == s.limit {
+		return errSinkFull
+	}
+	s.written++
+	return s.DirSink.WriteTile(ctx, id, data)
+}
+
+func TestGenerate(t *testing.T) {
+	// A polygon too small to draw at any zoom makes the tiles around it empty.
+	layers := boxLayers()
+	layers["tiny"] = mvt.MakeLayer(4096, mvt.Feature{
+		Geometry: geojson.NewPolygon([]geojson.Position{
+			geojson.MakePosition(60.001, 100),
+			geojson.MakePosition(60.001, 100.001),
+			geojson.MakePosition(60, 100.001),
+			geojson.MakePosition(60, 100),
+			geojson.MakePosition(60.001, 100),
+		}).Geometry,
+	})
+
+	opts := tiler.DefaultOptions
+	opts.MaxZoom = 6
+	opts.IndexMaxZoom = 2
+	tl, err := tiler.New(layers, opts)
+	require.NoError(t, err)
+
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprintf("concurrency/%d", concurrency), func(t *testing.T) {
+			var sink memorySink
+			var calls []tiler.Progress
+			progress, err := tl.Generate(context.Background(), &sink, tiler.GenerateOptions{
+				MinZoom:     2,
+				MaxZoom:     6,
+				Concurrency: concurrency,
+				Progress: func(p tiler.Progress) {
+					calls = append(calls, p)
+				},
+			})
+			require.NoError(t, err)
+			require.Equal(t, progress, calls[len(calls)-1])
+			require.Equal(t, int64(len(sink.tiles)), progress.Written)
+			require.Zero(t, progress.Existing)
+
+			// Every tile in range with features was written, and matches the tile returned on demand.
+			var written, bytes int64
+			for z := uint32(0); z <= 6; z++ {
+				for x := uint32(0); x < 1<<z; x++ {
+					for y := uint32(0); y < 1<<z; y++ {
+						id := tiler.TileID{Z: z, X: x, Y: y}
+						expected, err := tl.Tile(id)
+						require.NoError(t, err)
+
+						if z < 2 {
+							require.NotContains(t, sink.tiles, id)
+							continue
+						}
+						require.Equal(t, expected, sink.tiles[id], id)
+						if expected != nil {
+							written++
+							bytes += int64(len(expected))
+						}
+					}
+				}
+			}
+			require.Equal(t, written, progress.Written)
+			require.Equal(t, bytes, progress.Bytes)
+
+			// Tiles around the tiny polygon are visited but empty, and tiles far away are not visited.
+			require.Equal(t, int64(6-2+1), progress.Empty)
+			require.Less(t, progress.Written+progress.Empty, int64(4*4+8*8+16*16+32*32+64*64))
+		})
+	}
+}
+
+func TestGenerateResume(t *testing.T) {
+	opts := tiler.DefaultOptions
+	opts.MaxZoom = 5
+	tl, err := tiler.New(boxLayers(), opts)
+	require.NoError(t, err)
+
+	genOpts := tiler.GenerateOptions{MaxZoom: 5, Concurrency: 4}
+	sink := tiler.DirSink{Dir: t.TempDir()}
+
+	// Generation is interrupted by a sink that fails.
+	partial, err := tl.Generate(context.Background(), &failingSink{DirSink: sink, limit: 10}, genOpts)
+	require.True(t, errors.Is(err, errSinkFull))
+	require.Equal(t, int64(10), partial.Written)
+
+	resumed, err := tl.Generate(context.Background(), sink, genOpts)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), resumed.Existing)
+	require.Greater(t, resumed.Written, int64(0))
+
+	complete, err := tl.Generate(context.Background(), sink, genOpts)
+	require.NoError(t, err)
+	require.Equal(t, tiler.Progress{
+		Existing: resumed.Existing + resumed.Written,
+		Empty:    resumed.Empty,
+	}, complete)
+
+	// Tiles can be read by everyone that the umask allows, as with os.WriteFile.
+	ref := filepath.Join(t.TempDir(), "ref")
+	require.NoError(t, os.WriteFile(ref, nil, 0o644))
+	refInfo, err := os.Stat(ref)
+	require.NoError(t, err)
+
+	var files int64
+	require.NoError(t, filepath.WalkDir(sink.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		require.Equal(t, ".mvt", filepath.Ext(path))
+		files++
+
+		info, err := d.Info()
+		require.NoError(t, err)
+		require.Equal(t, refInfo.Mode().Perm(), info.Mode().Perm(), path)
+
+		rel, err := filepath.Rel(sink.Dir, path)
+		require.NoError(t, err)
+		id, err := tiler.ParseTileID(filepath.ToSlash(rel[:len(rel)-len(".mvt")]))
+		require.NoError(t, err)
+
+		expected, err := tl.Tile(id)
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, expected, data)
+		return nil
+	}))
+	require.Equal(t, complete.Existing, files)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	opts := tiler.DefaultOptions
+	opts.MaxZoom = 5
+	tl, err := tiler.New(boxLayers(), opts)
+	require.NoError(t, err)
+
+	var sink memorySink
+	_, err = tl.Generate(context.Background(), &sink, tiler.GenerateOptions{MaxZoom: 6})
+	require.Error(t, err)
+
+	_, err = tl.Generate(context.Background(), &sink, tiler.GenerateOptions{MinZoom: 3, MaxZoom: 2})
+	require.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = tl.Generate(ctx, &sink, tiler.GenerateOptions{MaxZoom: 5, Concurrency: 4})
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Empty(t, sink.tiles)
+
+	sinkErr := errors.New("failed")
+	_, err = tl.Generate(context.Background(), tiler.SinkFunc(func(context.Context, tiler.TileID, []byte) error {
+		return sinkErr
+	}), tiler.GenerateOptions{MaxZoom: 5})
+	require.True(t, errors.Is(err, sinkErr))
+}
@@ -0,0 +1,285 @@
+package tiler
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+
+	mvt "github.com/everystreet/go-mvt"
+)
+
+// OverzoomOptions configures how a tile is overzoomed, to derive a descendant tile beyond the zoom of its source.
+type OverzoomOptions struct {
+	// Buffer around the descendant tile, in the tile coordinates of each layer, that geometries are clipped to.
+	// The buffered extent of each layer must be within the range of a TilePoint.
+	Buffer uint32
+
+	// Compression of the descendant tile.
+	Compression mvt.Compression
+}
+
+// Overzoom decodes an encoded tile and returns its descendant tile encoded without compression.
+func Overzoom(data []byte, id, descendant TileID) ([]byte, error) {
+	return OverzoomOptions{}.Tile(data, id, descendant)
+}
+
+// Tile decodes an encoded tile and returns its descendant tile, encoded.
+func (o OverzoomOptions) Tile(data []byte, id, descendant TileID) ([]byte, error) {
+	layers, err := mvt.UnmarshalOptions{TileCoordinates: true}.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err = o.Layers(layers, id, descendant)
+	if err != nil {
+		return nil, err
+	}
+	return mvt.MarshalOptions{Compression: o.Compression}.Marshal(layers)
+}
+
+// Layers returns the layers of the descendant tile, from the decoded layers of the tile id.
+// The geometries of the layers must be TileGeometry, as decoded with UnmarshalOptions.TileCoordinates.
+// Geometries are scaled into the tile coordinates of the descendant, and clipped to its buffered extent,
+// entirely in integer tile coordinates. Tags and IDs are unchanged.
+// An UnknownGeometry can't be clipped, so its feature is removed, along with features and layers left empty.
+// The supplied layers are not modified.
+func (o OverzoomOptions) Layers(layers mvt.Layers, id, descendant TileID) (mvt.Layers, error) {
+	if !id.Valid() {
+		return nil, fmt.Errorf("tile %v is out of range", id)
+	} else if !descendant.Valid() {
+		return nil, fmt.Errorf("tile %v is out of range", descendant)
+	} else if !id.Contains(descendant) {
+		return nil, fmt.Errorf("tile %v is not a descendant of %v", descendant, id)
+	}
+
+	dz := descendant.Z - id.Z
+	out := make(mvt.Layers, len(layers))
+
+	for name, layer := range layers {
+		extent := int64(layer.Extent)
+		if extent+int64(o.Buffer) > math.MaxInt32 {
+			return nil, fmt.Errorf("layer '%s' extent %d with buffer %d is out of range of tile coordinates",
+				name, layer.Extent, o.Buffer)
+		}
+
+		z := zoom{
+			shift: dz,
+			x:     int64(descendant.X-id.X<<dz) * extent,
+			y:     int64(descendant.Y-id.Y<<dz) * extent,
+			min:   -int64(o.Buffer),
+			max:   extent + int64(o.Buffer),
+		}
+
+		features := make([]mvt.Feature, 0, len(layer.Features))
+		for i, f := range layer.Features {
+			var g *mvt.TileGeometry
+			switch geo := f.Geometry.(type) {
+			case *mvt.TileGeometry:
+				g = geo
+			case *mvt.UnknownGeometry:
+				continue
+			default:
+				return nil, &mvt.FeatureError{Layer: name, Index: i, ID: f.ID, Offset: -1,
+					Err: fmt.Errorf("geometry must be a TileGeometry, have %T", f.Geometry)}
+			}
+
+			if g == nil {
+				continue
+			} else if g = z.geometry(g); g == nil {
+				continue
+			}
+			f.Geometry = g
+			features = append(features, f)
+		}
+
+		if len(features) > 0 {
+			out[name] = mvt.MakeLayer(layer.Extent, features...)
+		}
+	}
+	return out, nil
+}
+
+// zoom scales the geometries of a layer into a descendant tile, and clips them to its buffered extent.
+type zoom struct {
+	// shift is the number of zooms to the descendant.
+	shift uint32
+	// x and y are the offset of the descendant, in the scaled tile coordinates of the tile.
+	x, y int64
+	// min and max bound the buffered extent of the descendant.
+	min, max int64
+}
+
+// tilePoint is a point in the tile coordinates of a descendant, which may be beyond the range of a TilePoint until clipped.
+type tilePoint [2]int64
+
+// geometry returns the geometry scaled and clipped, or nil if nothing remains.
+func (z zoom) geometry(g *mvt.TileGeometry) *mvt.TileGeometry {
+	out := mvt.TileGeometry{GeomType: g.GeomType}
+	exterior := false
+
+	for _, part := range g.Parts {
+		points := make([]tilePoint, len(part))
+		for i, p := range part {
+			points[i] = tilePoint{int64(p.X)<<z.shift - z.x, int64(p.Y)<<z.shift - z.y}
+		}
+
+		switch g.GeomType {
+		case mvt.TilePointType:
+			var kept []mvt.TilePoint
+			for _, p := range points {
+				if z.inside(p, 0, false) && z.inside(p, 0, true) && z.inside(p, 1, false) && z.inside(p, 1, true) {
+					kept = append(kept, z.point(p))
+				}
+			}
+			if len(kept) > 0 {
+				out.Parts = append(out.Parts, kept)
+			}
+		case mvt.TileLineStringType:
+			lines := [][]tilePoint{points}
+			for _, edge := range edges {
+				var clipped [][]tilePoint
+				for _, line := range lines {
+					clipped = z.clipLine(line, edge.axis, edge.upper, clipped)
+				}
+				lines = clipped
+			}
+
+			for _, line := range lines {
+				if len(line) >= 2 {
+					out.Parts = append(out.Parts, z.points(line))
+				}
+			}
+		case mvt.TilePolygonType:
+			for _, edge := range edges {
+				points = z.clipRing(points, edge.axis, edge.upper)
+			}
+			if ring := z.points(points); mvt.KeepRing(part, ring, &exterior) {
+				out.Parts = append(out.Parts, ring)
+			}
+		}
+	}
+
+	if len(out.Parts) == 0 {
+		return nil
+	}
+	return &out
+}
+
+// edges of the buffered extent, in the order that geometries are clipped to them.
+var edges = []struct {
+	axis  int
+	upper bool
+}{{0, false}, {0, true}, {1, false}, {1, true}}
+
+// inside returns true if p is on the inner side of the edge.
+func (z zoom) inside(p tilePoint, axis int, upper bool) bool {
+	if upper {
+		return p[axis] <= z.max
+	}
+	return p[axis] >= z.min
+}
+
+// clipLine appends the pieces of the line on the inner side of the edge to out.
+func (z zoom) clipLine(line []tilePoint, axis int, upper bool, out [][]tilePoint) [][]tilePoint {
+	var piece []tilePoint
+	for i, p := range line {
+		in := z.inside(p, axis, upper)
+		if i > 0 && in != z.inside(line[i-1], axis, upper) {
+			piece = appendPoint(piece, z.intersect(line[i-1], p, axis, upper))
+			if !in {
+				out = append(out, piece)
+				piece = nil
+			}
+		}
+		if in {
+			piece = appendPoint(piece, p)
+		}
+	}
+	if len(piece) > 0 {
+		out = append(out, piece)
+	}
+	return out
+}
+
+// clipRing returns the implicitly closed ring clipped to the inner side of the edge.
+func (z zoom) clipRing(ring []tilePoint, axis int, upper bool) []tilePoint {
+	var out []tilePoint
+	for i, p := range ring {
+		prev := ring[(i+len(ring)-1)%len(ring)]
+		in := z.inside(p, axis, upper)
+		if in != z.inside(prev, axis, upper) {
+			out = appendPoint(out, z.intersect(prev, p, axis, upper))
+		}
+		if in {
+			out = appendPoint(out, p)
+		}
+	}
+	if n := len(out); n > 1 && out[n-1] == out[0] {
+		out = out[:n-1]
+	}
+	return out
+}
+
+// intersect returns the point where the segment a-b crosses the edge, rounded to tile coordinates.
+func (z zoom) intersect(a, b tilePoint, axis int, upper bool) tilePoint {
+	k := z.min
+	if upper {
+		k = z.max
+	}
+
+	other := 1 - axis
+	var p tilePoint
+	p[axis] = k
+	p[other] = a[other] + mulDiv(k-a[axis], b[other]-a[other], b[axis]-a[axis])
+	return p
+}
+
+// points returns the clipped points as TilePoints, without repeated points.
+func (z zoom) points(points []tilePoint) []mvt.TilePoint {
+	out := make([]mvt.TilePoint, 0, len(points))
+	for _, p := range points {
+		if tp := z.point(p); len(out) == 0 || out[len(out)-1] != tp {
+			out = append(out, tp)
+		}
+	}
+	return out
+}
+
+// point returns the clipped point as a TilePoint.
+func (z zoom) point(p tilePoint) mvt.TilePoint {
+	return mvt.TilePoint{X: int32(p[0]), Y: int32(p[1])}
+}
+
+// appendPoint appends p to the points unless it repeats the last.
+func appendPoint(points []tilePoint, p tilePoint) []tilePoint {
+	if len(points) > 0 && points[len(points)-1] == p {
+		return points
+	}
+	return append(points, p)
+}
+
+// mulDiv returns a*b/c rounded to the nearest integer, with halves rounded away from zero.
+// The magnitude of a must be at most that of c, which must not be zero, so that the result is at most the magnitude of b.
+func mulDiv(a, b, c int64) int64 {
+	ua, ub, uc := abs64(a), abs64(b), abs64(c)
+	hi, lo := bits.Mul64(ua, ub)
+	q, r := bits.Div64(hi, lo, uc)
+	if r >= uc-r {
+		q++
+	}
+
+	if q > math.MaxInt64 {
+		q = math.MaxInt64
+	}
+	if (a < 0) != (b < 0) != (c < 0) {
+		return -int64(q)
+	}
+	return int64(q)
+}
+
+func abs64(v int64) uint64 {
+	if v < 0 {
+		return uint64(-v)
+	}
+	return uint64(v)
+}
@@ -0,0 +1,116 @@
+package tiler_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt "github.com/everystreet/go-mvt"
+	"github.com/everystreet/go-mvt/tiler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverzoom(t *testing.T) {
+	id := tiler.TileID{Z: 14, X: 8000, Y: 5000}
+	descendant := tiler.TileID{Z: 15, X: 16001, Y: 10000}
+
+	layers := mvt.Layers{
+		"places": mvt.MakeLayer(4096,
+			tileFeature(1, mvt.TilePointType, []mvt.TilePoint{{X: 3000, Y: 1000}, {X: 1000, Y: 1000}}),
+			tileFeature(2, mvt.TilePointType, []mvt.TilePoint{{X: 1000, Y: 3000}}),
+		),
+		"roads": mvt.MakeLayer(4096,
+			tileFeature(3, mvt.TileLineStringType, []mvt.TilePoint{{X: 0, Y: 100}, {X: 4096, Y: 100}}),
+			tileFeature(4, mvt.TileLineStringType, []mvt.TilePoint{{X: 2000, Y: 0}, {X: 2100, Y: 3}}),
+		),
+		"parks": mvt.MakeLayer(4096,
+			tileFeature(5, mvt.TilePolygonType,
+				[]mvt.TilePoint{{X: 1024, Y: 1024}, {X: 3072, Y: 1024}, {X: 3072, Y: 3072}, {X: 1024, Y: 3072}},
+				[]mvt.TilePoint{{X: 1100, Y: 1100}, {X: 1100, Y: 1200}, {X: 1200, Y: 1200}, {X: 1200, Y: 1100}},
+				[]mvt.TilePoint{{X: 2600, Y: 1100}, {X: 2600, Y: 1200}, {X: 2700, Y: 1200}, {X: 2700, Y: 1100}},
+			),
+		),
+		"water": mvt.MakeLayer(4096,
+			tileFeature(6, mvt.TilePolygonType, []mvt.TilePoint{{X: 0, Y: 3000}, {X: 100, Y: 3000}, {X: 100, Y: 3100}}),
+		),
+	}
+
+	out, err := tiler.OverzoomOptions{Buffer: 64}.Layers(layers, id, descendant)
+	require.NoError(t, err)
+	require.Equal(t, mvt.Layers{
+		"places": mvt.MakeLayer(4096,
+			tileFeature(1, mvt.TilePointType, []mvt.TilePoint{{X: 1904, Y: 2000}}),
+		),
+		"roads": mvt.MakeLayer(4096,
+			tileFeature(3, mvt.TileLineStringType, []mvt.TilePoint{{X: -64, Y: 200}, {X: 4096, Y: 200}}),
+			tileFeature(4, mvt.TileLineStringType, []mvt.TilePoint{{X: -64, Y: 1}, {X: 104, Y: 6}}),
+		),
+		"parks": mvt.MakeLayer(4096,
+			tileFeature(5, mvt.TilePolygonType,
+				[]mvt.TilePoint{{X: -64, Y: 4160}, {X: -64, Y: 2048}, {X: 2048, Y: 2048}, {X: 2048, Y: 4160}},
+				[]mvt.TilePoint{{X: 1104, Y: 2200}, {X: 1104, Y: 2400}, {X: 1304, Y: 2400}, {X: 1304, Y: 2200}},
+			),
+		),
+	}, out)
+
+	t.Run("encoded", func(t *testing.T) {
+		data, err := mvt.MarshalOptions{Compression: mvt.Gzip}.Marshal(layers)
+		require.NoError(t, err)
+
+		data, err = tiler.Overzoom(data, id, descendant)
+		require.NoError(t, err)
+
+		decoded, err := mvt.UnmarshalOptions{TileCoordinates: true}.Unmarshal(data)
+		require.NoError(t, err)
+
+		expected, err := tiler.OverzoomOptions{}.Layers(layers, id, descendant)
+		require.NoError(t, err)
+		require.Equal(t, expected, decoded)
+	})
+
+	t.Run("deep", func(t *testing.T) {
+		descendant := tiler.TileID{Z: 18, X: 128007, Y: 80010}
+		out, err := tiler.OverzoomOptions{}.Layers(layers, id, descendant)
+		require.NoError(t, err)
+		require.Equal(t, mvt.Layers{
+			"parks": mvt.MakeLayer(4096,
+				tileFeature(5, mvt.TilePolygonType,
+					[]mvt.TilePoint{{X: 0, Y: 4096}, {X: 0, Y: 0}, {X: 4096, Y: 0}, {X: 4096, Y: 4096}},
+				),
+			),
+		}, out)
+	})
+}
+
+func TestOverzoomErrors(t *testing.T) {
+	id := tiler.TileID{Z: 14, X: 8000, Y: 5000}
+
+	_, err := tiler.OverzoomOptions{}.Layers(nil, id, tiler.TileID{Z: 15, X: 1, Y: 1})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not a descendant of")
+
+	_, err = tiler.OverzoomOptions{}.Layers(nil, id, tiler.TileID{Z: 31, X: 1, Y: 1})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of range")
+
+	layers := mvt.Layers{
+		"places": mvt.MakeLayer(4096, mvt.Feature{Geometry: &geojson.Point{}}),
+	}
+	_, err = tiler.OverzoomOptions{Buffer: math.MaxInt32}.Layers(layers, id, tiler.TileID{Z: 15, X: 16000, Y: 10000})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of range of tile coordinates")
+
+	_, err = tiler.OverzoomOptions{}.Layers(layers, id, tiler.TileID{Z: 15, X: 16000, Y: 10000})
+	var featureErr *mvt.FeatureError
+	require.True(t, errors.As(err, &featureErr))
+	require.Equal(t, mvt.LayerName("places"), featureErr.Layer)
+}
+
+func tileFeature(id uint64, typ mvt.TileGeometryType, parts ...[]mvt.TilePoint) mvt.Feature {
+	return mvt.Feature{
+		ID:       mvt.NewOptionalUint64(id),
+		Tags:     geojson.PropertyList{{Name: "id", Value: id}},
+		Geometry: &mvt.TileGeometry{GeomType: typ, Parts: parts},
+	}
+}
@@ -0,0 +1,60 @@
+package tiler
+
+import (
+	"math"
+
+	mvt "github.com/everystreet/go-mvt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+// Project returns a function that projects geographic coordinates to the coordinates of the tile,
+// using the Web Mercator projection.
+func Project(id TileID, extent uint32) mvt.Project {
+	scale, x, y := tileScale(id)
+	return func(ll s2.LatLng) r2.Point {
+		return r2.Point{
+			X: (mercatorX(ll.Lng.Degrees())*scale - x) * float64(extent),
+			Y: (mercatorY(ll.Lat.Degrees())*scale - y) * float64(extent),
+		}
+	}
+}
+
+// Unproject returns a function that converts the coordinates of the tile to geographic coordinates,
+// using the Web Mercator projection.
+func Unproject(id TileID, extent uint32) mvt.Unproject {
+	scale, x, y := tileScale(id)
+	return func(p r2.Point) s2.LatLng {
+		return s2.LatLng{
+			Lat: s1.Angle(latitude((p.Y/float64(extent)+y)/scale)) * s1.Degree,
+			Lng: s1.Angle(longitude((p.X/float64(extent)+x)/scale)) * s1.Degree,
+		}
+	}
+}
+
+// tileScale returns the number of tiles across the world at the zoom of id, and the position of id.
+func tileScale(id TileID) (scale, x, y float64) {
+	return math.Ldexp(1, int(id.Z)), float64(id.X), float64(id.Y)
+}
+
+// mercatorX returns the position of the longitude in the unit square of the Web Mercator world.
+func mercatorX(lng float64) float64 {
+	return lng/360 + 0.5
+}
+
+// mercatorY returns the position of the latitude in the unit square of the Web Mercator world,
+// where 0 is the north edge. Latitudes beyond the edges are clamped.
+func mercatorY(lat float64) float64 {
+	sin := math.Sin(lat * math.Pi / 180)
+	y := 0.5 - 0.25*math.Log((1+sin)/(1-sin))/math.Pi
+	return math.Max(0, math.Min(1, y))
+}
+
+func longitude(x float64) float64 {
+	return (x - 0.5) * 360
+}
+
+func latitude(y float64) float64 {
+	return 360/math.Pi*math.Atan(math.Exp((1-2*y)*math.Pi)) - 90
+}
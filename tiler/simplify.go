@@ -0,0 +1,113 @@
+package tiler
+
+import mvt "github.com/everystreet/go-mvt"
+
+// markImportance sets the importance of each point using the Douglas-Peucker algorithm,
+// so that the line can be simplified to any tolerance above sqTolerance by keeping the points that are more important.
+// The end points are always kept.
+func markImportance(points []point, sqTolerance float64) {
+	if len(points) == 0 {
+		return
+	}
+
+	points[0].importance = 1
+	points[len(points)-1].importance = 1
+	if len(points) > 2 {
+		simplify(points, 0, len(points)-1, sqTolerance)
+	}
+}
+
+func simplify(points []point, first, last int, sqTolerance float64) {
+	maxSqDist := sqTolerance
+	mid := first + (last-first)/2
+	minPosToMid := last - first
+	index := -1
+
+	a, b := points[first], points[last]
+	for i := first + 1; i < last; i++ {
+		d := sqSegmentDistance(points[i], a, b)
+		if d > maxSqDist {
+			index, maxSqDist = i, d
+		} else if d == maxSqDist {
+			// Prefer a pivot close to the middle, so that a run of equally distant points is split evenly.
+			if posToMid := abs(i - mid); posToMid < minPosToMid {
+				index, minPosToMid = i, posToMid
+			}
+		}
+	}
+
+	if maxSqDist > sqTolerance {
+		if index-first > 1 {
+			simplify(points, first, index, sqTolerance)
+		}
+		points[index].importance = maxSqDist
+		if last-index > 1 {
+			simplify(points, index, last, sqTolerance)
+		}
+	}
+}
+
+// sqSegmentDistance returns the squared distance from p to the segment a-b.
+func sqSegmentDistance(p, a, b point) float64 {
+	x, y := a.x, a.y
+	dx, dy := b.x-x, b.y-y
+
+	if dx != 0 || dy != 0 {
+		t := ((p.x-x)*dx + (p.y-y)*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			x, y = b.x, b.y
+		} else if t > 0 {
+			x += dx * t
+			y += dy * t
+		}
+	}
+
+	dx, dy = p.x-x, p.y-y
+	return dx*dx + dy*dy
+}
+
+// simplified returns the feature with the points that are less important than the squared tolerance removed,
+// and lines and rings smaller than the tolerance dropped. It returns nil if nothing remains.
+// A zero tolerance keeps every point.
+func simplified(f *feature, tolerance float64) *feature {
+	if tolerance == 0 || f.typ == mvt.TilePointType {
+		return f
+	}
+
+	sqTolerance := tolerance * tolerance
+	parts := make([][]path, 0, len(f.parts))
+	for _, part := range f.parts {
+		out := make([]path, 0, len(part))
+		for i, p := range part {
+			if f.typ == mvt.TilePolygonType && p.size < sqTolerance {
+				if i == 0 {
+					// Without its exterior ring, a polygon's holes are dropped too.
+					break
+				}
+				continue
+			} else if f.typ == mvt.TileLineStringType && p.size < tolerance {
+				continue
+			}
+
+			points := make([]point, 0, len(p.points))
+			for _, pt := range p.points {
+				if pt.importance > sqTolerance {
+					points = append(points, pt)
+				}
+			}
+			out = append(out, path{points: points, size: p.size})
+		}
+
+		if len(out) > 0 {
+			parts = append(parts, out)
+		}
+	}
+	return newFeature(f, parts)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package tiler
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Sink stores the tiles produced by Generate.
+type Sink interface {
+	// WriteTile stores the encoded tile.
+	// It is called concurrently when Generate uses more than one worker.
+	WriteTile(ctx context.Context, id TileID, data []byte) error
+}
+
+// ResumableSink is a Sink that reports the tiles it already stores, so that Generate can resume after an interruption.
+type ResumableSink interface {
+	Sink
+
+	// HasTile returns true if the tile was stored completely by an earlier call to WriteTile.
+	HasTile(ctx context.Context, id TileID) (bool, error)
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, id TileID, data []byte) error
+
+// WriteTile calls fn.
+func (fn SinkFunc) WriteTile(ctx context.Context, id TileID, data []byte) error {
+	return fn(ctx, id, data)
+}
+
+// DirSink stores each tile in a file named z/x/y with an extension, below a directory.
+// Files are written to a temporary name and renamed once complete, so an interrupted write is never mistaken for a tile.
+type DirSink struct {
+	// Dir is the directory that contains the tiles.
+	Dir string
+
+	// Extension of the tile files. If empty, ".mvt" is used.
+	Extension string
+}
+
+// WriteTile writes the tile to its file, creating directories as needed.
+func (s DirSink) WriteTile(_ context.Context, id TileID, data []byte) error {
+	path := s.path(id)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := createTemp(dir)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	} else if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// HasTile returns true if the file of the tile exists.
+func (s DirSink) HasTile(_ context.Context, id TileID) (bool, error) {
+	_, err := os.Stat(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// createTemp creates a new temporary file in dir, which can be read by everyone unless the umask denies it.
+// os.CreateTemp is not used, as its files can only be read by their owner.
+func createTemp(dir string) (*os.File, error) {
+	for i := 0; ; i++ {
+		path := filepath.Join(dir, ".tile-"+strconv.FormatUint(rand.Uint64(), 36))
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) && i < 100 {
+			continue
+		}
+		return f, err
+	}
+}
+
+func (s DirSink) path(id TileID) string {
+	ext := s.Extension
+	if ext == "" {
+		ext = ".mvt"
+	}
+	return filepath.Join(s.Dir,
+		strconv.FormatUint(uint64(id.Z), 10),
+		strconv.FormatUint(uint64(id.X), 10),
+		strconv.FormatUint(uint64(id.Y), 10)+ext)
+}
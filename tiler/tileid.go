@@ -0,0 +1,77 @@
+package tiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxZoom is the highest zoom of a valid TileID.
+const MaxZoom = 30
+
+// TileID identifies a tile in the Web Mercator tile pyramid, where tile (0, 0) is in the north west.
+type TileID struct {
+	Z, X, Y uint32
+}
+
+// ParseTileID parses a tile ID in the form "z/x/y".
+func ParseTileID(s string) (TileID, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return TileID{}, fmt.Errorf("tile ID '%s' must be in the form z/x/y", s)
+	}
+
+	var values [3]uint32
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return TileID{}, fmt.Errorf("tile ID '%s': %w", s, err)
+		}
+		values[i] = uint32(v)
+	}
+
+	id := TileID{Z: values[0], X: values[1], Y: values[2]}
+	if !id.Valid() {
+		return TileID{}, fmt.Errorf("tile ID '%s' is out of range", s)
+	}
+	return id, nil
+}
+
+func (id TileID) String() string {
+	return fmt.Sprintf("%d/%d/%d", id.Z, id.X, id.Y)
+}
+
+// Valid returns true if the zoom is at most MaxZoom, and the tile is inside the pyramid at that zoom.
+func (id TileID) Valid() bool {
+	return id.Z <= MaxZoom && id.X < 1<<id.Z && id.Y < 1<<id.Z
+}
+
+// Parent returns the tile at the previous zoom that contains id.
+// The parent of the tile at zoom 0 is itself.
+func (id TileID) Parent() TileID {
+	if id.Z == 0 {
+		return id
+	}
+	return TileID{Z: id.Z - 1, X: id.X >> 1, Y: id.Y >> 1}
+}
+
+// Children returns the four tiles at the next zoom that id contains,
+// in the order north west, north east, south west, south east.
+func (id TileID) Children() [4]TileID {
+	z, x, y := id.Z+1, id.X*2, id.Y*2
+	return [4]TileID{
+		{Z: z, X: x, Y: y},
+		{Z: z, X: x + 1, Y: y},
+		{Z: z, X: x, Y: y + 1},
+		{Z: z, X: x + 1, Y: y + 1},
+	}
+}
+
+// Contains returns true if other is id, or one of its descendants.
+func (id TileID) Contains(other TileID) bool {
+	if other.Z < id.Z {
+		return false
+	}
+	shift := other.Z - id.Z
+	return other.X>>shift == id.X && other.Y>>shift == id.Y
+}
@@ -0,0 +1,414 @@
+// Package tiler cuts layers of geographic features into vector tiles on demand, in the manner of geojson-vt,
+// and generates the tiles of whole pyramids.
+package tiler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	mvt "github.com/everystreet/go-mvt"
+)
+
+// Options configures how a Tiler cuts features into tiles.
+// The zero value indexes only the tile at zoom 0, and keeps every point.
+type Options struct {
+	// MaxZoom is the highest zoom at which tiles are generated. Tiles beyond it are empty.
+	// Features are simplified for display at MaxZoom, so it bounds the detail that is preserved.
+	MaxZoom uint32
+
+	// IndexMaxZoom is the highest zoom of the index built by New.
+	// Deeper tiles are cut from the index when they are first requested.
+	IndexMaxZoom uint32
+
+	// IndexMaxPoints is the number of points at or below which a tile is not split further while building the index.
+	IndexMaxPoints int
+
+	// Tolerance is the distance, in tile coordinates, that simplification may move a line.
+	// If zero, features are not simplified. Each feature is simplified on its own, so polygons that share edges
+	// may part; such layers are better simplified by configuring LayerConfig.Simplify, with a zero Tolerance.
+	Tolerance float64
+
+	// Extent of the generated tiles. If zero, 4096 is used.
+	Extent uint32
+
+	// Buffer is the distance, in tile coordinates, that features extend beyond the edges of each tile,
+	// so that lines and polygons are drawn seamlessly across tiles.
+	Buffer uint32
+
+	// Compression applied to encoded tiles.
+	Compression mvt.Compression
+
+	// Layers configures the zooms at which each layer appears in tiles, and the tags and extent it has at each zoom.
+	// The extent of a layer at a zoom replaces Extent for the coordinates of its features.
+	// Layers configured to simplify, reduce or coalesce have their features simplified, reduced and merged in each tile.
+	Layers mvt.LayerConfigs
+
+	// Labels adds a layer of label points for the polygons of each configured layer to encoded tiles.
+	Labels mvt.LabelLayers
+}
+
+// DefaultOptions are the defaults of geojson-vt.
+var DefaultOptions = Options{
+	MaxZoom:        14,
+	IndexMaxZoom:   5,
+	IndexMaxPoints: 100000,
+	Tolerance:      3,
+	Extent:         4096,
+	Buffer:         64,
+}
+
+// maxTilerZoom is the highest supported MaxZoom.
+const maxTilerZoom = 24
+
+// Tiler holds an index of tiles cut from a set of layers.
+// It is safe for concurrent use.
+type Tiler struct {
+	opts  Options
+	names []mvt.LayerName
+
+	mu    sync.Mutex
+	tiles map[TileID]*tile
+}
+
+// tile is a tile in the index.
+type tile struct {
+	// features are the tile's features, simplified for its zoom.
+	features []*feature
+	// source holds the features that are clipped to make the tile's children.
+	// It is nil once the tile has been split.
+	source []*feature
+	// points is the number of points in source.
+	points int
+
+	minX, minY, maxX, maxY float64
+}
+
+// New returns a Tiler for the supplied layers, whose features must have geographic geometries.
+// The extent of the layers is ignored, as every tile uses the configured extent.
+func New(layers mvt.Layers, opts Options) (*Tiler, error) {
+	return NewContext(context.Background(), layers, opts)
+}
+
+// NewContext returns a Tiler for the supplied layers, whose features must have geographic geometries.
+// Building the index stops between features and tiles if ctx is done, and ctx.Err() is returned.
+func NewContext(ctx context.Context, layers mvt.Layers, opts Options) (*Tiler, error) {
+	if opts.Extent == 0 {
+		opts.Extent = 4096
+	}
+
+	if opts.MaxZoom > maxTilerZoom {
+		return nil, fmt.Errorf("max zoom must be at most %d", maxTilerZoom)
+	} else if opts.IndexMaxZoom > opts.MaxZoom {
+		return nil, fmt.Errorf("index max zoom must be at most max zoom")
+	} else if opts.Tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must not be negative")
+	} else if err := opts.Layers.Validate(); err != nil {
+		return nil, err
+	} else if err := opts.Labels.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, cfg := range opts.Labels {
+		if _, ok := layers[cfg.Layer]; ok {
+			return nil, fmt.Errorf("label layer '%s' is already a layer", cfg.Layer)
+		}
+	}
+
+	t := Tiler{
+		opts:  opts,
+		tiles: make(map[TileID]*tile),
+	}
+
+	for name, layer := range layers {
+		if len(layer.Features) > 0 {
+			t.names = append(t.names, name)
+		}
+	}
+	slices.Sort(t.names)
+
+	sqTolerance := square(opts.Tolerance / (math.Ldexp(1, int(opts.MaxZoom)) * float64(opts.Extent)))
+
+	var features []*feature
+	for i, name := range t.names {
+		for j, data := range layers[name].Features {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			f, err := convert(data, i, sqTolerance)
+			if err != nil {
+				return nil, &mvt.FeatureError{Layer: name, Index: j, ID: data.ID, Offset: -1, Err: err}
+			}
+			features = append(features, f)
+		}
+	}
+
+	features = wrap(features, float64(opts.Buffer)/float64(opts.Extent))
+	if len(features) > 0 {
+		root := t.newTile(features, 0)
+		t.tiles[TileID{}] = root
+		if err := t.split(ctx, root, TileID{}, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// Tile returns the encoded tile, or nil if the tile has no features.
+func (t *Tiler) Tile(id TileID) ([]byte, error) {
+	return t.TileContext(context.Background(), id)
+}
+
+// TileContext returns the encoded tile, or nil if the tile has no features.
+// Tiling and encoding stop between features if ctx is done, and ctx.Err() is returned.
+func (t *Tiler) TileContext(ctx context.Context, id TileID) ([]byte, error) {
+	layers, err := t.LayersContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return t.encode(ctx, layers)
+}
+
+// Layers returns the layers of the tile, whose features have a TileGeometry.
+// Layers without features in the tile are omitted. The tags of the features are shared with the tiler,
+// and must not be modified.
+func (t *Tiler) Layers(id TileID) (mvt.Layers, error) {
+	return t.LayersContext(context.Background(), id)
+}
+
+// LayersContext returns the layers of the tile, whose features have a TileGeometry.
+// Tiling stops between features if ctx is done, and ctx.Err() is returned.
+func (t *Tiler) LayersContext(ctx context.Context, id TileID) (mvt.Layers, error) {
+	if !id.Valid() {
+		return nil, fmt.Errorf("invalid tile '%v'", id)
+	} else if id.Z > t.opts.MaxZoom {
+		return nil, nil
+	}
+
+	tl, err := t.tile(ctx, id)
+	if err != nil || tl == nil {
+		return nil, err
+	}
+	return t.layers(ctx, tl, id)
+}
+
+// tile returns the tile from the index, cutting it from its nearest ancestor if needed.
+// It returns nil if the tile is empty.
+func (t *Tiler) tile(ctx context.Context, id TileID) (*tile, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tl, ok := t.tiles[id]; ok {
+		return tl, nil
+	}
+
+	// An ancestor that has been split, but has no child towards id, has no features there.
+	ancestorID := id
+	var ancestor *tile
+	for ancestor == nil && ancestorID.Z > 0 {
+		ancestorID = ancestorID.Parent()
+		ancestor = t.tiles[ancestorID]
+	}
+	if ancestor == nil || ancestor.source == nil {
+		return nil, nil
+	}
+
+	if err := t.split(ctx, ancestor, ancestorID, &id); err != nil {
+		return nil, err
+	}
+	return t.tiles[id], nil
+}
+
+// split cuts the tile into its descendants.
+// If target is nil, the index is built down to IndexMaxZoom, stopping at tiles with at most IndexMaxPoints points.
+// Otherwise, only the ancestors of target are split, down to target or MaxZoom.
+// The children of a tile are added to the index as it is split, so the index remains consistent if ctx is done.
+func (t *Tiler) split(ctx context.Context, root *tile, rootID TileID, target *TileID) error {
+	type item struct {
+		tile *tile
+		id   TileID
+	}
+
+	stack := []item{{root, rootID}}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		tl, id := it.tile, it.id
+
+		if target == nil {
+			if id.Z == t.opts.IndexMaxZoom || tl.points <= t.opts.IndexMaxPoints {
+				continue
+			}
+		} else if id.Z == t.opts.MaxZoom || id.Z == target.Z || !id.Contains(*target) {
+			continue
+		}
+
+		quadrants := t.cut(tl, tl.source, id)
+		for i, childID := range id.Children() {
+			if quadrants[i] == nil {
+				continue
+			}
+
+			child, ok := t.tiles[childID]
+			if !ok {
+				child = t.newTile(quadrants[i], childID.Z)
+				t.tiles[childID] = child
+			}
+			stack = append(stack, item{child, childID})
+		}
+
+		// The source is only needed to cut the children, which are now in the index.
+		tl.source = nil
+	}
+	return nil
+}
+
+// cut returns the source features of the tile clipped to each of its children, in the order of TileID.Children.
+// The features of a child are nil if none are inside it.
+func (t *Tiler) cut(tl *tile, source []*feature, id TileID) [4][]*feature {
+	k1 := 0.5 * float64(t.opts.Buffer) / float64(t.opts.Extent)
+	k2, k3, k4 := 0.5-k1, 0.5+k1, 1+k1
+
+	scale := math.Ldexp(1, int(id.Z))
+	x, y := float64(id.X), float64(id.Y)
+
+	var quadrants [4][]*feature
+	if left := clip(source, scale, x-k1, x+k3, xAxis, tl.minX, tl.maxX); left != nil {
+		quadrants[0] = clip(left, scale, y-k1, y+k3, yAxis, tl.minY, tl.maxY)
+		quadrants[2] = clip(left, scale, y+k2, y+k4, yAxis, tl.minY, tl.maxY)
+	}
+	if right := clip(source, scale, x+k2, x+k4, xAxis, tl.minX, tl.maxX); right != nil {
+		quadrants[1] = clip(right, scale, y-k1, y+k3, yAxis, tl.minY, tl.maxY)
+		quadrants[3] = clip(right, scale, y+k2, y+k4, yAxis, tl.minY, tl.maxY)
+	}
+	return quadrants
+}
+
+// newTile returns a tile with the features, simplified for the zoom.
+func (t *Tiler) newTile(features []*feature, z uint32) *tile {
+	tl := tile{
+		source: features,
+		minX:   math.Inf(1),
+		minY:   math.Inf(1),
+		maxX:   math.Inf(-1),
+		maxY:   math.Inf(-1),
+	}
+
+	tolerance := t.opts.Tolerance / (math.Ldexp(1, int(z)) * float64(t.opts.Extent))
+	for _, f := range features {
+		tl.minX, tl.maxX = math.Min(tl.minX, f.minX), math.Max(tl.maxX, f.maxX)
+		tl.minY, tl.maxY = math.Min(tl.minY, f.minY), math.Max(tl.maxY, f.maxY)
+		tl.points += f.points()
+
+		if s := simplified(f, tolerance); s != nil {
+			tl.features = append(tl.features, s)
+		}
+	}
+	return &tl
+}
+
+// encode returns the encoded layers, or nil if there are none.
+func (t *Tiler) encode(ctx context.Context, layers mvt.Layers) ([]byte, error) {
+	if len(layers) == 0 {
+		return nil, nil
+	}
+	return mvt.MarshalOptions{
+		Compression: t.opts.Compression,
+		Labels:      t.opts.Labels,
+	}.MarshalContext(ctx, layers)
+}
+
+// layers returns the features of the tile in tile coordinates, grouped by layer.
+func (t *Tiler) layers(ctx context.Context, tl *tile, id TileID) (mvt.Layers, error) {
+	var tr transform
+	tr.scale, tr.x, tr.y = tileScale(id)
+
+	// IDs must be unique within a layer, so a wrapped copy loses its ID in a tile that also holds the feature it copies.
+	type layerID struct {
+		layer int
+		id    uint64
+	}
+	var ids map[layerID]struct{}
+	for _, f := range tl.features {
+		if id, ok := f.id.Get(); ok && !f.wrapped {
+			if ids == nil {
+				ids = make(map[layerID]struct{})
+			}
+			ids[layerID{f.layer, id}] = struct{}{}
+		}
+	}
+
+	layers := make(mvt.Layers)
+	for _, f := range tl.features {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		name := t.names[f.layer]
+		cfg, configured := t.opts.Layers[name]
+		if configured && !cfg.Visible(id.Z) {
+			continue
+		}
+
+		extent := t.opts.Extent
+		if configured {
+			extent = cfg.Extent(id.Z, extent)
+		}
+
+		tr.extent = float64(extent)
+		geo := tr.geometry(f)
+		if geo == nil {
+			continue
+		}
+
+		tags := f.tags
+		if configured {
+			tags = cfg.Tags(id.Z, tags)
+		}
+
+		fid := f.id
+		if id, ok := fid.Get(); ok && f.wrapped {
+			if _, ok := ids[layerID{f.layer, id}]; ok {
+				fid = mvt.OptionalUint64{}
+			}
+		}
+
+		layer := layers[name]
+		layer.Extent = extent
+		layer.Features = append(layer.Features, mvt.Feature{
+			ID:       fid,
+			Tags:     tags,
+			Geometry: geo,
+		})
+		layers[name] = layer
+	}
+
+	for name, layer := range layers {
+		cfg := t.opts.Layers[name]
+		if cfg.Simplify != nil {
+			layer = cfg.Simplify.Layer(layer)
+		}
+		if cfg.Reduce != nil {
+			if layer = cfg.Reduce.Layer(layer); len(layer.Features) == 0 {
+				delete(layers, name)
+				continue
+			}
+		}
+		if cfg.Coalesce != nil {
+			layer = cfg.Coalesce.Layer(layer)
+		}
+		layers[name] = layer
+	}
+	return layers, nil
+}
+
+func square(v float64) float64 {
+	return v * v
+}
@@ -0,0 +1,531 @@
+package tiler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt "github.com/everystreet/go-mvt"
+	"github.com/everystreet/go-mvt/tiler"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/s2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTileID(t *testing.T) {
+	id, err := tiler.ParseTileID("3/5/2")
+	require.NoError(t, err)
+	require.Equal(t, tiler.TileID{Z: 3, X: 5, Y: 2}, id)
+	require.Equal(t, "3/5/2", id.String())
+	require.True(t, id.Valid())
+
+	require.Equal(t, tiler.TileID{Z: 2, X: 2, Y: 1}, id.Parent())
+	require.Equal(t, tiler.TileID{}, tiler.TileID{}.Parent())
+	require.Equal(t, [4]tiler.TileID{
+		{Z: 4, X: 10, Y: 4},
+		{Z: 4, X: 11, Y: 4},
+		{Z: 4, X: 10, Y: 5},
+		{Z: 4, X: 11, Y: 5},
+	}, id.Children())
+
+	require.True(t, id.Contains(id))
+	require.True(t, id.Parent().Contains(id))
+	require.True(t, id.Contains(tiler.TileID{Z: 6, X: 47, Y: 16}))
+	require.False(t, id.Contains(tiler.TileID{Z: 6, X: 48, Y: 16}))
+	require.False(t, id.Contains(id.Parent()))
+
+	for _, s := range []string{"", "1/2", "a/0/0", "1/2/0", "31/0/0", "-1/0/0"} {
+		_, err := tiler.ParseTileID(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestProjection(t *testing.T) {
+	require.Equal(t, r2.Point{X: 2048, Y: 2048}, tiler.Project(tiler.TileID{}, 4096)(s2.LatLngFromDegrees(0, 0)))
+
+	id := tiler.TileID{Z: 10, X: 511, Y: 340}
+	project, unproject := tiler.Project(id, 4096), tiler.Unproject(id, 4096)
+	for _, p := range []r2.Point{{X: 0, Y: 0}, {X: 4096, Y: 4096}, {X: 100.5, Y: -20}} {
+		actual := project(unproject(p))
+		require.InDelta(t, p.X, actual.X, 1e-6)
+		require.InDelta(t, p.Y, actual.Y, 1e-6)
+	}
+}
+
+// tileContaining returns the tile at zoom z that contains the position.
+func tileContaining(pos geojson.Position, z uint32) tiler.TileID {
+	p := tiler.Project(tiler.TileID{}, 1)(pos.LatLng)
+	scale := math.Ldexp(1, int(z))
+	return tiler.TileID{Z: z, X: uint32(p.X * scale), Y: uint32(p.Y * scale)}
+}
+
+func TestTilerPoints(t *testing.T) {
+	london := geojson.MakePosition(51.5, -0.12)
+	tl, err := tiler.New(mvt.Layers{
+		"places": mvt.MakeLayer(4096, mvt.Feature{
+			ID:       mvt.NewOptionalUint64(1),
+			Geometry: &geojson.Point{LatLng: london.LatLng},
+			Tags:     geojson.PropertyList{{Name: "name", Value: "London"}},
+		}),
+	}, tiler.DefaultOptions)
+	require.NoError(t, err)
+
+	for z := uint32(0); z <= tiler.DefaultOptions.MaxZoom; z++ {
+		id := tileContaining(london, z)
+		layers, err := tl.Layers(id)
+		require.NoError(t, err)
+		require.Len(t, layers, 1)
+
+		p := tiler.Project(id, 4096)(london.LatLng)
+		require.Equal(t, mvt.Layer{
+			Extent: 4096,
+			Features: []mvt.Feature{{
+				ID: mvt.NewOptionalUint64(1),
+				Geometry: &mvt.TileGeometry{
+					GeomType: mvt.TilePointType,
+					Parts:    [][]mvt.TilePoint{{{X: int32(math.Round(p.X)), Y: int32(math.Round(p.Y))}}},
+				},
+				Tags: geojson.PropertyList{{Name: "name", Value: "London"}},
+			}},
+		}, layers["places"], id)
+
+		data, err := tl.Tile(id)
+		require.NoError(t, err)
+
+		decoded, err := mvt.Unmarshal(data, mvt.Unproject(tiler.Unproject(id, 4096)))
+		require.NoError(t, err)
+		point := decoded["places"].Features[0].Geometry.(*geojson.Point)
+		require.InDelta(t, 51.5, point.Lat.Degrees(), 1)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		data, err := tl.Tile(tiler.TileID{Z: 8, X: 1, Y: 1})
+		require.NoError(t, err)
+		require.Nil(t, data)
+	})
+
+	t.Run("beyond max zoom", func(t *testing.T) {
+		data, err := tl.Tile(tileContaining(london, 15))
+		require.NoError(t, err)
+		require.Nil(t, data)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := tl.Tile(tiler.TileID{Z: 1, X: 2, Y: 0})
+		require.Error(t, err)
+	})
+}
+
+func TestTilerLayerConfig(t *testing.T) {
+	london := geojson.MakePosition(51.5, -0.12)
+	opts := tiler.DefaultOptions
+	opts.Layers = mvt.LayerConfigs{
+		"places": {
+			Attributes: []mvt.ZoomAttributes{{Keys: []string{"name"}}, {MinZoom: 6}},
+			Extents:    []mvt.ZoomExtent{{Extent: 256}, {MinZoom: 6, Extent: 4096}},
+		},
+		"stations": {MinZoom: 4, MaxZoom: 8},
+	}
+
+	tl, err := tiler.New(mvt.Layers{
+		"places": mvt.MakeLayer(4096, mvt.Feature{
+			Geometry: &geojson.Point{LatLng: london.LatLng},
+			Tags: geojson.PropertyList{
+				{Name: "name", Value: "London"},
+				{Name: "population", Value: int64(8900000)},
+			},
+		}),
+		"stations": mvt.MakeLayer(4096, mvt.Feature{
+			Geometry: &geojson.Point{LatLng: london.LatLng},
+		}),
+	}, opts)
+	require.NoError(t, err)
+
+	for z := uint32(0); z <= 10; z++ {
+		id := tileContaining(london, z)
+		layers, err := tl.Layers(id)
+		require.NoError(t, err)
+
+		_, ok := layers["stations"]
+		require.Equal(t, z >= 4 && z <= 8, ok, id)
+
+		places := layers["places"]
+		extent := uint32(256)
+		tags := 1
+		if z >= 6 {
+			extent, tags = 4096, 2
+		}
+		require.Equal(t, extent, places.Extent, id)
+		require.Len(t, places.Features[0].Tags, tags, id)
+
+		p := tiler.Project(id, extent)(london.LatLng)
+		require.Equal(t, [][]mvt.TilePoint{{{X: int32(math.Round(p.X)), Y: int32(math.Round(p.Y))}}},
+			places.Features[0].Geometry.(*mvt.TileGeometry).Parts, id)
+	}
+
+	opts.Layers = mvt.LayerConfigs{"places": {MinZoom: 3, MaxZoom: 2}}
+	_, err = tiler.New(mvt.Layers{}, opts)
+	require.Error(t, err)
+}
+
+func TestTilerLabels(t *testing.T) {
+	layers := mvt.Layers{"parks": mvt.MakeLayer(4096, mvt.Feature{
+		ID: mvt.NewOptionalUint64(3),
+		Geometry: geojson.NewPolygon([]geojson.Position{
+			geojson.MakePosition(51, 1),
+			geojson.MakePosition(51, 3),
+			geojson.MakePosition(52, 3),
+			geojson.MakePosition(52, 1),
+			geojson.MakePosition(51, 1),
+		}).Geometry,
+	})}
+
+	opts := tiler.DefaultOptions
+	opts.Labels = mvt.LabelLayers{"parks": {Layer: "park_labels"}}
+	tl, err := tiler.New(layers, opts)
+	require.NoError(t, err)
+
+	// The park is inside the tile, so it is labelled as a whole.
+	id := tileContaining(geojson.MakePosition(51.5, 2), 5)
+	data, err := tl.Tile(id)
+	require.NoError(t, err)
+
+	decoded, err := mvt.Unmarshal(data, tiler.Unproject(id, 4096))
+	require.NoError(t, err)
+	require.Len(t, decoded["park_labels"].Features, 1)
+
+	label := decoded["park_labels"].Features[0]
+	require.Equal(t, mvt.NewOptionalUint64(3), label.ID)
+	p := label.Geometry.(*geojson.Point)
+	require.InDelta(t, 51.5, p.Lat.Degrees(), 0.01)
+	require.Greater(t, p.Lng.Degrees(), 1.5)
+	require.Less(t, p.Lng.Degrees(), 2.5)
+
+	opts.Labels = mvt.LabelLayers{"parks": {Layer: "parks"}}
+	_, err = tiler.New(layers, opts)
+	require.Error(t, err)
+}
+
+func TestTilerWrap(t *testing.T) {
+	tl, err := tiler.New(mvt.Layers{
+		"places": mvt.MakeLayer(4096, mvt.Feature{
+			Geometry: geojson.NewPoint(0, 179.9).Geometry,
+		}),
+	}, tiler.DefaultOptions)
+	require.NoError(t, err)
+
+	// The point is in the buffer of the tile across the antimeridian.
+	layers, err := tl.Layers(tiler.TileID{Z: 1, X: 0, Y: 0})
+	require.NoError(t, err)
+	require.Equal(t, &mvt.TileGeometry{
+		GeomType: mvt.TilePointType,
+		Parts:    [][]mvt.TilePoint{{{X: -2, Y: 4096}}},
+	}, layers["places"].Features[0].Geometry)
+
+	t.Run("ids", func(t *testing.T) {
+		tl, err := tiler.New(mvt.Layers{
+			"places": mvt.MakeLayer(4096,
+				mvt.Feature{ID: mvt.NewOptionalUint64(7), Geometry: geojson.NewPoint(0, 179.9).Geometry},
+				mvt.Feature{ID: mvt.NewOptionalUint64(8), Geometry: geojson.NewPoint(0, -179.9).Geometry},
+			),
+		}, tiler.DefaultOptions)
+		require.NoError(t, err)
+
+		// Each point and its copy are in the buffered world tile, where only the point keeps its ID.
+		layers, err := tl.Layers(tiler.TileID{})
+		require.NoError(t, err)
+		var ids []uint64
+		for _, f := range layers["places"].Features {
+			if id, ok := f.ID.Get(); ok {
+				ids = append(ids, id)
+			}
+		}
+		require.Len(t, layers["places"].Features, 4)
+		require.ElementsMatch(t, []uint64{7, 8}, ids)
+
+		_, err = tl.Tile(tiler.TileID{})
+		require.NoError(t, err)
+
+		// A copy keeps its ID across the antimeridian, away from the point it copies.
+		layers, err = tl.Layers(tiler.TileID{Z: 1, X: 0, Y: 0})
+		require.NoError(t, err)
+		require.Len(t, layers["places"].Features, 2)
+		for _, f := range layers["places"].Features {
+			require.True(t, f.ID.IsSet())
+		}
+
+		var sink memorySink
+		_, err = tl.Generate(context.Background(), &sink, tiler.GenerateOptions{MaxZoom: 2})
+		require.NoError(t, err)
+		require.Contains(t, sink.tiles, tiler.TileID{})
+	})
+}
+
+func TestTilerLines(t *testing.T) {
+	tl, err := tiler.New(mvt.Layers{
+		"roads": mvt.MakeLayer(4096, mvt.Feature{
+			Geometry: geojson.NewLineString(geojson.MakePosition(0, -90), geojson.MakePosition(0, 90)).Geometry,
+		}),
+	}, tiler.DefaultOptions)
+	require.NoError(t, err)
+
+	// The line is clipped to each tile and its buffer.
+	for id, expected := range map[tiler.TileID][]mvt.TilePoint{
+		{Z: 2, X: 0, Y: 1}: {{X: 4096, Y: 4096}, {X: 4160, Y: 4096}},
+		{Z: 2, X: 1, Y: 1}: {{X: 0, Y: 4096}, {X: 4160, Y: 4096}},
+		{Z: 2, X: 2, Y: 1}: {{X: -64, Y: 4096}, {X: 4096, Y: 4096}},
+		{Z: 2, X: 3, Y: 1}: {{X: -64, Y: 4096}, {X: 0, Y: 4096}},
+		{Z: 2, X: 1, Y: 2}: {{X: 0, Y: 0}, {X: 4160, Y: 0}},
+	} {
+		layers, err := tl.Layers(id)
+		require.NoError(t, err)
+		require.Equal(t, [][]mvt.TilePoint{expected}, layers["roads"].Features[0].Geometry.(*mvt.TileGeometry).Parts, id)
+	}
+
+	layers, err := tl.Layers(tiler.TileID{Z: 2, X: 1, Y: 0})
+	require.NoError(t, err)
+	require.Empty(t, layers)
+}
+
+func TestTilerPolygons(t *testing.T) {
+	layers := mvt.Layers{
+		"water": mvt.MakeLayer(4096, mvt.Feature{
+			// Covers the world, which is larger than a hemisphere.
+			Geometry: geojson.NewPolygon([]geojson.Position{
+				geojson.MakePosition(-85, -180),
+				geojson.MakePosition(85, -180),
+				geojson.MakePosition(85, 180),
+				geojson.MakePosition(-85, 180),
+				geojson.MakePosition(-85, -180),
+			}).Geometry,
+		}),
+		"buildings": mvt.MakeLayer(4096, mvt.Feature{
+			Geometry: geojson.NewPolygon(
+				[]geojson.Position{
+					geojson.MakePosition(10, 10),
+					geojson.MakePosition(10, 20),
+					geojson.MakePosition(0, 20),
+					geojson.MakePosition(0, 10),
+					geojson.MakePosition(10, 10),
+				},
+				[]geojson.Position{
+					geojson.MakePosition(4, 14),
+					geojson.MakePosition(6, 14),
+					geojson.MakePosition(6, 16),
+					geojson.MakePosition(4, 16),
+					geojson.MakePosition(4, 14),
+				},
+			).Geometry,
+		}),
+	}
+
+	opts := tiler.DefaultOptions
+	opts.MaxZoom = 6
+	tl, err := tiler.New(layers, opts)
+	require.NoError(t, err)
+
+	// Tiles inside the world polygon are covered by a square, including the buffer.
+	layer, err := tl.Layers(tiler.TileID{Z: 3, X: 1, Y: 1})
+	require.NoError(t, err)
+	geo := layer["water"].Features[0].Geometry.(*mvt.TileGeometry)
+	require.Len(t, geo.Parts, 1)
+	require.Equal(t, int64(2*4224*4224), mvt.RingArea(geo.Parts[0]))
+
+	for z := uint32(0); z <= opts.MaxZoom; z++ {
+		for x := uint32(0); x < 1<<z; x++ {
+			for y := uint32(0); y < 1<<z; y++ {
+				id := tiler.TileID{Z: z, X: x, Y: y}
+				data, err := tl.Tile(id)
+				require.NoError(t, err, id)
+
+				report, err := mvt.ValidateTile(data)
+				require.NoError(t, err)
+				require.True(t, report.Valid(), "%v: %v", id, report)
+			}
+		}
+	}
+
+	// The building and its hole are both in the same tile.
+	id := tileContaining(geojson.MakePosition(5, 15), 6)
+	layer, err = tl.Layers(id)
+	require.NoError(t, err)
+	geo = layer["buildings"].Features[0].Geometry.(*mvt.TileGeometry)
+	require.Len(t, geo.Parts, 2)
+	require.Greater(t, mvt.RingArea(geo.Parts[0]), int64(0))
+	require.Less(t, mvt.RingArea(geo.Parts[1]), int64(0))
+}
+
+func TestTilerSimplification(t *testing.T) {
+	line := make(geojson.LineString, 1000)
+	for i := range line {
+		lng := -10 + float64(i)*0.02
+		line[i] = geojson.MakePosition(10+math.Sin(float64(i))*0.01, lng)
+	}
+
+	tl, err := tiler.New(mvt.Layers{
+		"roads": mvt.MakeLayer(4096, mvt.Feature{Geometry: &line}),
+	}, tiler.DefaultOptions)
+	require.NoError(t, err)
+
+	count := func(id tiler.TileID) int {
+		layers, err := tl.Layers(id)
+		require.NoError(t, err)
+
+		var n int
+		for _, part := range layers["roads"].Features[0].Geometry.(*mvt.TileGeometry).Parts {
+			n += len(part)
+		}
+		return n
+	}
+
+	low := count(tiler.TileID{})
+	high := count(tileContaining(line[500], 7))
+	require.Less(t, low, 10)
+	require.Greater(t, high, 50)
+}
+
+// boxLayers returns a layer with a grid of 200 boxes.
+func boxLayers() mvt.Layers {
+	var features []mvt.Feature
+	for i := 0; i < 200; i++ {
+		lat, lng := float64(i%20)*4-40, float64(i/20)*8-40
+		features = append(features, mvt.Feature{
+			ID: mvt.NewOptionalUint64(uint64(i)),
+			Geometry: geojson.NewPolygon([]geojson.Position{
+				geojson.MakePosition(lat+3, lng),
+				geojson.MakePosition(lat+3, lng+5),
+				geojson.MakePosition(lat, lng+5),
+				geojson.MakePosition(lat, lng),
+				geojson.MakePosition(lat+3, lng),
+			}).Geometry,
+		})
+	}
+	return mvt.Layers{"boxes": mvt.MakeLayer(4096, features...)}
+}
+
+// TestTilerIndex checks that tiles cut on demand match tiles cut while building the index.
+func TestTilerIndex(t *testing.T) {
+	layers := boxLayers()
+
+	opts := tiler.DefaultOptions
+	opts.MaxZoom = 5
+	opts.IndexMaxZoom = 0
+	onDemand, err := tiler.New(layers, opts)
+	require.NoError(t, err)
+
+	opts.IndexMaxZoom = opts.MaxZoom
+	opts.IndexMaxPoints = 0
+	indexed, err := tiler.New(layers, opts)
+	require.NoError(t, err)
+
+	var ids []tiler.TileID
+	for z := opts.MaxZoom; ; z-- {
+		for x := uint32(0); x < 1<<z; x++ {
+			for y := uint32(0); y < 1<<z; y++ {
+				ids = append(ids, tiler.TileID{Z: z, X: x, Y: y})
+			}
+		}
+		if z == 0 {
+			break
+		}
+	}
+
+	// Tiles are requested concurrently, from the deepest zoom first.
+	var wg sync.WaitGroup
+	results := make([]mvt.Layers, len(ids))
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(ids); i += 4 {
+				layers, err := onDemand.Layers(ids[i])
+				require.NoError(t, err)
+				results[i] = layers
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for i, id := range ids {
+		expected, err := indexed.Layers(id)
+		require.NoError(t, err)
+		require.Equal(t, expected, results[i], id)
+	}
+}
+
+func TestTilerErrors(t *testing.T) {
+	for name, opts := range map[string]tiler.Options{
+		"max zoom":   {MaxZoom: 25},
+		"index zoom": {MaxZoom: 4, IndexMaxZoom: 5},
+		"tolerance":  {Tolerance: -1},
+		"labels":     {Labels: mvt.LabelLayers{"a": {}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := tiler.New(nil, opts)
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("geometry", func(t *testing.T) {
+		_, err := tiler.New(mvt.Layers{
+			"a": mvt.MakeLayer(4096,
+				mvt.Feature{Geometry: geojson.NewPoint(1, 2).Geometry},
+				mvt.Feature{Geometry: &mvt.UnknownGeometry{RawShape: []uint32{9, 0, 0}}},
+			),
+		}, tiler.DefaultOptions)
+
+		var featureErr *mvt.FeatureError
+		require.True(t, errors.As(err, &featureErr))
+		require.Equal(t, mvt.LayerName("a"), featureErr.Layer)
+		require.Equal(t, 1, featureErr.Index)
+	})
+}
+
+func TestTilerContext(t *testing.T) {
+	var features []mvt.Feature
+	for i := 0; i < 100000; i++ {
+		features = append(features, mvt.Feature{
+			Geometry: geojson.NewPoint(float64(i%170)-85, float64(i%360)-180).Geometry,
+		})
+	}
+	layers := mvt.Layers{"points": mvt.MakeLayer(4096, features...)}
+
+	t.Run("new", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := tiler.NewContext(ctx, layers, tiler.DefaultOptions)
+		require.Equal(t, context.Canceled, err)
+	})
+
+	opts := tiler.DefaultOptions
+	opts.IndexMaxZoom = 0
+	tl, err := tiler.New(layers, opts)
+	require.NoError(t, err)
+
+	t.Run("tile", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		id := tiler.TileID{Z: 3, X: 4, Y: 3}
+		_, err := tl.TileContext(ctx, id)
+		require.Equal(t, context.Canceled, err)
+
+		// A canceled request leaves the index usable.
+		data, err := tl.Tile(id)
+		require.NoError(t, err)
+		require.NotNil(t, data)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+
+		_, err := tl.TileContext(ctx, tiler.TileID{})
+		require.True(t, errors.Is(err, context.DeadlineExceeded), fmt.Sprint(err))
+	})
+}
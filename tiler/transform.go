@@ -0,0 +1,87 @@
+package tiler
+
+import (
+	"math"
+
+	mvt "github.com/everystreet/go-mvt"
+)
+
+// transform converts world coordinates to the coordinates of a tile.
+type transform struct {
+	extent, scale, x, y float64
+}
+
+func (tr transform) point(p point) mvt.TilePoint {
+	return mvt.TilePoint{
+		X: int32(math.Round(tr.extent * (p.x*tr.scale - tr.x))),
+		Y: int32(math.Round(tr.extent * (p.y*tr.scale - tr.y))),
+	}
+}
+
+// geometry returns the feature in tile coordinates, or nil if nothing remains once the points are rounded.
+// Repeated points are removed, along with lines and rings that collapse,
+// and rings are wound as exterior rings or holes according to their position in the polygon.
+func (tr transform) geometry(f *feature) *mvt.TileGeometry {
+	geo := mvt.TileGeometry{GeomType: f.typ}
+
+	switch f.typ {
+	case mvt.TilePointType:
+		if points := f.parts[0][0].points; len(points) > 0 {
+			geo.Parts = [][]mvt.TilePoint{make([]mvt.TilePoint, len(points))}
+			for i, p := range points {
+				geo.Parts[0][i] = tr.point(p)
+			}
+		}
+	case mvt.TileLineStringType:
+		for _, part := range f.parts {
+			if line := tr.path(part[0], false); len(line) >= 2 {
+				geo.Parts = append(geo.Parts, line)
+			}
+		}
+	case mvt.TilePolygonType:
+		for _, part := range f.parts {
+			for i, p := range part {
+				ring := tr.path(p, true)
+				if len(ring) < 3 {
+					if i == 0 {
+						break
+					}
+					continue
+				}
+
+				area := mvt.RingArea(ring)
+				if area == 0 {
+					if i == 0 {
+						break
+					}
+					continue
+				} else if (i == 0) != (area > 0) {
+					reverse(ring)
+				}
+				geo.Parts = append(geo.Parts, ring)
+			}
+		}
+	}
+
+	if len(geo.Parts) == 0 {
+		return nil
+	}
+	return &geo
+}
+
+// path returns the points of the path in tile coordinates, without consecutive repeated points.
+// The closing point of a ring is removed.
+func (tr transform) path(p path, ring bool) []mvt.TilePoint {
+	out := make([]mvt.TilePoint, 0, len(p.points))
+	for _, pt := range p.points {
+		tp := tr.point(pt)
+		if n := len(out); n == 0 || out[n-1] != tp {
+			out = append(out, tp)
+		}
+	}
+
+	if n := len(out); ring && n > 1 && out[n-1] == out[0] {
+		out = out[:n-1]
+	}
+	return out
+}
@@ -0,0 +1,344 @@
+package mvt
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Simplification simplifies the lines and polygons of a layer together, so that the edges they share stay shared,
+// in the manner of TopoJSON. Rings and lines are cut into arcs at the junctions where they meet or part,
+// each distinct arc is simplified once with the Douglas-Peucker algorithm, and the rings and lines are rebuilt from them.
+// Adjacent polygons therefore stay watertight, without slivers or gaps between them.
+// Only features with a TileGeometry are simplified, and the tolerance is in the tile coordinates of the layer.
+type Simplification struct {
+	// Tolerance is the distance that simplification may move an arc. If zero, nothing is simplified.
+	Tolerance float64
+}
+
+// Validate the simplification.
+func (s Simplification) Validate() error {
+	if s.Tolerance < 0 {
+		return fmt.Errorf("tolerance must not be negative")
+	}
+	return nil
+}
+
+// Layer returns the layer with its lines and polygons simplified.
+// Lines and rings that collapse are removed, and holes are removed along with their exterior ring.
+// Features left without geometry are removed. The supplied layer is not modified.
+func (s Simplification) Layer(layer Layer) Layer {
+	if s.Tolerance == 0 {
+		return layer
+	}
+
+	t := topology{
+		neighbours:  make(map[TilePoint]neighbours),
+		arcs:        make(map[string][]TilePoint),
+		sqTolerance: s.Tolerance * s.Tolerance,
+	}
+	for _, f := range layer.Features {
+		if g, ok := f.Geometry.(*TileGeometry); ok && g != nil {
+			t.add(g)
+		}
+	}
+
+	features := make([]Feature, 0, len(layer.Features))
+	for _, f := range layer.Features {
+		g, ok := f.Geometry.(*TileGeometry)
+		if !ok || g == nil || g.GeomType == TilePointType {
+			features = append(features, f)
+			continue
+		}
+
+		if g = t.simplify(g); g == nil {
+			continue
+		}
+		f.Geometry = g
+		features = append(features, f)
+	}
+	return MakeLayer(layer.Extent, features...)
+}
+
+// topology holds the junctions of the lines and rings of a layer, and the arcs between them once simplified.
+type topology struct {
+	neighbours  map[TilePoint]neighbours
+	arcs        map[string][]TilePoint
+	sqTolerance float64
+}
+
+// neighbours of a point, which is a junction if they differ between the lines and rings it is on.
+type neighbours struct {
+	a, b     TilePoint
+	junction bool
+}
+
+// add the points of the geometry to the topology.
+func (t *topology) add(g *TileGeometry) {
+	switch g.GeomType {
+	case TileLineStringType:
+		for _, line := range g.Parts {
+			for i, p := range line {
+				if i == 0 || i == len(line)-1 {
+					t.neighbours[p] = neighbours{junction: true}
+					continue
+				}
+				t.visit(p, line[i-1], line[i+1])
+			}
+		}
+	case TilePolygonType:
+		for _, ring := range g.Parts {
+			for i, p := range ring {
+				t.visit(p, ring[(i+len(ring)-1)%len(ring)], ring[(i+1)%len(ring)])
+			}
+		}
+	}
+}
+
+// visit records the neighbours of a point, and marks it as a junction if they differ from those already recorded.
+func (t *topology) visit(p, a, b TilePoint) {
+	n, ok := t.neighbours[p]
+	switch {
+	case !ok:
+		t.neighbours[p] = neighbours{a: a, b: b}
+	case n.junction:
+	case (n.a != a || n.b != b) && (n.a != b || n.b != a):
+		t.neighbours[p] = neighbours{junction: true}
+	}
+}
+
+func (t *topology) junction(p TilePoint) bool {
+	return t.neighbours[p].junction
+}
+
+// simplify returns the geometry rebuilt from its simplified arcs, or nil if nothing remains.
+func (t *topology) simplify(g *TileGeometry) *TileGeometry {
+	out := TileGeometry{GeomType: g.GeomType}
+	exterior := false
+
+	for _, part := range g.Parts {
+		if g.GeomType == TileLineStringType {
+			if line := t.line(part); len(line) >= 2 {
+				out.Parts = append(out.Parts, line)
+			}
+			continue
+		}
+
+		if ring := t.ring(part); KeepRing(part, ring, &exterior) {
+			out.Parts = append(out.Parts, ring)
+		}
+	}
+
+	if len(out.Parts) == 0 {
+		return nil
+	}
+	return &out
+}
+
+// line returns the line rebuilt from its simplified arcs.
+func (t *topology) line(line []TilePoint) []TilePoint {
+	var out []TilePoint
+	start := 0
+	for i := 1; i < len(line); i++ {
+		if i == len(line)-1 || t.junction(line[i]) {
+			out = appendArc(out, t.arc(line[start:i+1]))
+			start = i
+		}
+	}
+	return out
+}
+
+// ring returns the implicitly closed ring rebuilt from its simplified arcs.
+func (t *topology) ring(ring []TilePoint) []TilePoint {
+	first := slices.IndexFunc(ring, t.junction)
+	if first < 0 {
+		return t.closedArc(ring)
+	}
+
+	// The ring is rotated to start at a junction, and closed, so that it can be cut into arcs.
+	rotated := make([]TilePoint, 0, len(ring)+1)
+	rotated = append(rotated, ring[first:]...)
+	rotated = append(rotated, ring[:first+1]...)
+
+	out := t.line(rotated)
+	if n := len(out); n > 1 && out[n-1] == out[0] {
+		out = out[:n-1]
+	}
+	return out
+}
+
+// appendArc appends the arc to the line, which ends where the arc starts.
+func appendArc(line, arc []TilePoint) []TilePoint {
+	if len(line) > 0 {
+		arc = arc[1:]
+	}
+	for _, p := range arc {
+		if len(line) == 0 || line[len(line)-1] != p {
+			line = append(line, p)
+		}
+	}
+	return line
+}
+
+// arc returns the simplified arc. An arc and its reverse are simplified once, and the same way.
+func (t *topology) arc(arc []TilePoint) []TilePoint {
+	reversed := slices.Clone(arc)
+	slices.Reverse(reversed)
+
+	canonical, reverse := arc, false
+	if comparePoints(reversed, arc) < 0 {
+		canonical, reverse = reversed, true
+	}
+
+	key := arcKey(canonical, false)
+	simplified, ok := t.arcs[key]
+	if !ok {
+		simplified = douglasPeucker(canonical, t.sqTolerance)
+		t.arcs[key] = simplified
+	}
+
+	if reverse {
+		simplified = slices.Clone(simplified)
+		slices.Reverse(simplified)
+	}
+	return simplified
+}
+
+// closedArc returns the simplified ring, which has no junctions. A ring and its reverse, from any start,
+// are simplified once, and the same way.
+func (t *topology) closedArc(ring []TilePoint) []TilePoint {
+	if len(ring) == 0 {
+		return nil
+	}
+
+	// The ring is rotated to start at its least point, in the direction that compares least.
+	least := 0
+	for i, p := range ring {
+		if comparePoint(p, ring[least]) < 0 {
+			least = i
+		}
+	}
+
+	forward := make([]TilePoint, 0, len(ring))
+	forward = append(forward, ring[least:]...)
+	forward = append(forward, ring[:least]...)
+
+	backward := slices.Clone(forward)
+	slices.Reverse(backward[1:])
+
+	canonical, reverse := forward, false
+	if comparePoints(backward, forward) < 0 {
+		canonical, reverse = backward, true
+	}
+
+	key := arcKey(canonical, true)
+	simplified, ok := t.arcs[key]
+	if !ok {
+		// The ring is split at the point farthest from its start, and each half simplified as a line.
+		far := len(canonical) - 1
+		var farthest int64
+		for i, p := range canonical {
+			dx, dy := int64(p.X)-int64(canonical[0].X), int64(p.Y)-int64(canonical[0].Y)
+			if d := dx*dx + dy*dy; d > farthest {
+				far, farthest = i, d
+			}
+		}
+
+		closed := append(slices.Clone(canonical), canonical[0])
+		simplified = douglasPeucker(closed[:far+1], t.sqTolerance)
+		simplified = appendArc(simplified, douglasPeucker(closed[far:], t.sqTolerance))
+		if n := len(simplified); n > 1 && simplified[n-1] == simplified[0] {
+			simplified = simplified[:n-1]
+		}
+		t.arcs[key] = simplified
+	}
+
+	if reverse {
+		simplified = slices.Clone(simplified)
+		slices.Reverse(simplified[1:])
+	}
+	return simplified
+}
+
+// arcKey returns a key that identifies the points of an arc.
+func arcKey(arc []TilePoint, closed bool) string {
+	b := make([]byte, 0, 1+8*len(arc))
+	if closed {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	for _, p := range arc {
+		b = append(b, byte(p.X), byte(p.X>>8), byte(p.X>>16), byte(p.X>>24))
+		b = append(b, byte(p.Y), byte(p.Y>>8), byte(p.Y>>16), byte(p.Y>>24))
+	}
+	return string(b)
+}
+
+func comparePoints(a, b []TilePoint) int {
+	return slices.CompareFunc(a, b, comparePoint)
+}
+
+func comparePoint(a, b TilePoint) int {
+	if c := cmp.Compare(a.X, b.X); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Y, b.Y)
+}
+
+// douglasPeucker returns the points of the line that are farther than the tolerance from the simplified line.
+// The end points are always kept.
+func douglasPeucker(line []TilePoint, sqTolerance float64) []TilePoint {
+	if len(line) <= 2 {
+		return slices.Clone(line)
+	}
+
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	type span struct{ first, last int }
+	stack := []span{{0, len(line) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		index, maxSqDist := -1, sqTolerance
+		for i := s.first + 1; i < s.last; i++ {
+			if d := sqSegmentDistance(line[i], line[s.first], line[s.last]); d > maxSqDist {
+				index, maxSqDist = i, d
+			}
+		}
+
+		if index >= 0 {
+			keep[index] = true
+			stack = append(stack, span{s.first, index}, span{index, s.last})
+		}
+	}
+
+	out := make([]TilePoint, 0, len(line))
+	for i, p := range line {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// sqSegmentDistance returns the squared distance from p to the segment a-b.
+func sqSegmentDistance(p, a, b TilePoint) float64 {
+	x, y := float64(a.X), float64(a.Y)
+	dx, dy := float64(b.X)-x, float64(b.Y)-y
+
+	if dx != 0 || dy != 0 {
+		t := ((float64(p.X)-x)*dx + (float64(p.Y)-y)*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			x, y = float64(b.X), float64(b.Y)
+		} else if t > 0 {
+			x += dx * t
+			y += dy * t
+		}
+	}
+
+	dx, dy = float64(p.X)-x, float64(p.Y)-y
+	return dx*dx + dy*dy
+}
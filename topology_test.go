@@ -0,0 +1,97 @@
+package mvt_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/everystreet/go-geojson/v2"
+	mvt21 "github.com/everystreet/go-mvt"
+	"github.com/stretchr/testify/require"
+)
+
+// wigglyLine returns a jagged line between the points, whose deviations are within the amplitude.
+func wigglyLine(r *rand.Rand, from, to mvt21.TilePoint, n int, amplitude int32) []mvt21.TilePoint {
+	line := make([]mvt21.TilePoint, n+1)
+	for i := range line {
+		line[i] = mvt21.TilePoint{
+			X: from.X + (to.X-from.X)*int32(i)/int32(n),
+			Y: from.Y + (to.Y-from.Y)*int32(i)/int32(n),
+		}
+		if i > 0 && i < n {
+			line[i].X += r.Int31n(2*amplitude+1) - amplitude
+			line[i].Y += r.Int31n(2*amplitude+1) - amplitude
+		}
+	}
+	return line
+}
+
+func reversed(points []mvt21.TilePoint) []mvt21.TilePoint {
+	out := make([]mvt21.TilePoint, len(points))
+	for i, p := range points {
+		out[len(points)-1-i] = p
+	}
+	return out
+}
+
+func TestSimplification(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	a, b := mvt21.TilePoint{X: 1000, Y: 0}, mvt21.TilePoint{X: 1000, Y: 2000}
+	border := wigglyLine(r, a, b, 200, 5)
+	west := wigglyLine(r, b, mvt21.TilePoint{X: 0, Y: 1000}, 100, 5)
+	west = append(west, wigglyLine(r, mvt21.TilePoint{X: 0, Y: 1000}, a, 100, 5)[1:]...)
+	east := wigglyLine(r, b, mvt21.TilePoint{X: 2000, Y: 1000}, 100, 5)
+	east = append(east, wigglyLine(r, mvt21.TilePoint{X: 2000, Y: 1000}, a, 100, 5)[1:]...)
+
+	// Two polygons share a jagged border, and an island sits in a lake in the eastern one.
+	island := []mvt21.TilePoint{{X: 1500, Y: 900}, {X: 1600, Y: 900}, {X: 1603, Y: 950}, {X: 1600, Y: 1000}, {X: 1500, Y: 1000}}
+	westRing := append(append([]mvt21.TilePoint{}, border...), west[1:len(west)-1]...)
+	eastRing := append(append([]mvt21.TilePoint{}, reversed(border)...), reversed(east)[1:len(east)-1]...)
+	polygon := func(id uint64, rings ...[]mvt21.TilePoint) mvt21.Feature {
+		return mvt21.Feature{
+			ID:       mvt21.NewOptionalUint64(id),
+			Geometry: &mvt21.TileGeometry{GeomType: mvt21.TilePolygonType, Parts: rings},
+		}
+	}
+
+	layer := mvt21.MakeLayer(4096,
+		polygon(1, westRing),
+		polygon(2, eastRing, reversed(island)),
+		polygon(3, island),
+		mvt21.Feature{Geometry: geojson.NewPoint(1, 1).Geometry},
+	)
+	require.NoError(t, layer.Validate())
+
+	simplified := mvt21.Simplification{Tolerance: 10}.Layer(layer)
+	require.NoError(t, simplified.Validate())
+	require.Len(t, simplified.Features, 4)
+	require.Equal(t, layer.Features[3], simplified.Features[3])
+
+	parts := func(i int) [][]mvt21.TilePoint {
+		return simplified.Features[i].Geometry.(*mvt21.TileGeometry).Parts
+	}
+	require.Less(t, len(parts(0)[0]), len(westRing)/4)
+
+	// Every edge of the simplified border is an edge of both polygons, in opposite directions.
+	edges := func(ring []mvt21.TilePoint) map[[2]mvt21.TilePoint]bool {
+		out := make(map[[2]mvt21.TilePoint]bool)
+		for i, p := range ring {
+			out[[2]mvt21.TilePoint{p, ring[(i+1)%len(ring)]}] = true
+		}
+		return out
+	}
+	westEdges, eastEdges := edges(parts(0)[0]), edges(parts(1)[0])
+	var shared int
+	for e := range westEdges {
+		if e[0].X > 900 && e[0].X < 1100 && e[1].X > 900 && e[1].X < 1100 {
+			require.True(t, eastEdges[[2]mvt21.TilePoint{e[1], e[0]}], e)
+			shared++
+		}
+	}
+	require.Greater(t, shared, 0)
+
+	// The island fills its lake exactly.
+	require.Equal(t, edges(reversed(parts(1)[1])), edges(parts(2)[0]))
+
+	require.Equal(t, layer, mvt21.Simplification{}.Layer(layer))
+	require.Error(t, mvt21.LayerConfig{Simplify: &mvt21.Simplification{Tolerance: -1}}.Validate())
+}
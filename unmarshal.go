@@ -1,86 +1,305 @@
 package mvt
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/everystreet/go-geojson/v2"
 	"github.com/everystreet/go-mvt/internal/geometry"
 	spec "github.com/everystreet/go-mvt/internal/spec"
-	"github.com/golang/protobuf/proto"
+	"github.com/everystreet/go-mvt/internal/wire"
 )
 
 // Unproject a projected coordinate to a geographic CRS.
 type Unproject geometry.Unproject
 
+// UnmarshalOptions configures how tiles are decoded.
+type UnmarshalOptions struct {
+	// Unproject converts tile coordinates to geographic coordinates.
+	Unproject Unproject
+
+	// TileCoordinates decodes geometries as TileGeometry, in the tile coordinates of their layer, without Unproject.
+	TileCoordinates bool
+
+	// MaxDecompressedSize is the maximum size, in bytes, that a compressed tile may expand to.
+	// If zero, DefaultMaxDecompressedSize is used.
+	MaxDecompressedSize int64
+
+	// Limits bounds the resources used to decode the tile.
+	Limits Limits
+
+	// InvalidFeatures determines what happens to features that can't be decoded.
+	// Unless it is FailInvalidFeatures, Unmarshal returns the decoded layers
+	// together with a Problems error that lists each invalid feature.
+	InvalidFeatures InvalidFeatureAction
+
+	// Concurrency is the maximum number of layers decoded at once.
+	// If zero or one, layers are decoded one at a time. If negative, GOMAXPROCS is used.
+	// Problems are reported in layer order regardless of concurrency.
+	Concurrency int
+}
+
+// InvalidFeatureAction determines what happens to features that can't be decoded.
+type InvalidFeatureAction uint8
+
+const (
+	// FailInvalidFeatures stops decoding at the first invalid feature and returns the error.
+	FailInvalidFeatures InvalidFeatureAction = iota
+	// DropInvalidFeatures omits invalid features from the decoded layer.
+	DropInvalidFeatures
+	// KeepInvalidFeatures keeps invalid features in the decoded layer.
+	// A geometry that can't be decoded is kept as an UnknownGeometry holding the raw command sequence,
+	// tags that can't be decoded are omitted, and a duplicate ID is unset.
+	KeepInvalidFeatures
+)
+
+// Problems lists the invalid features that were dropped or kept while decoding a tile.
+type Problems []*FeatureError
+
+func (p Problems) Error() string {
+	if len(p) == 1 {
+		return p[0].Error()
+	}
+	return fmt.Sprintf("%d invalid features, first: %v", len(p), p[0])
+}
+
 // Unmarshal parses the supplied mvt data and returns a set of layers.
 func Unmarshal(data []byte, unproject Unproject) (Layers, error) {
-	tile := spec.Tile{}
-	if err := proto.Unmarshal(data, &tile); err != nil {
+	return UnmarshalOptions{Unproject: unproject}.Unmarshal(data)
+}
+
+// Unmarshal parses the supplied mvt data and returns a set of layers.
+// Gzip and zstd compressed data is decompressed automatically.
+func (o UnmarshalOptions) Unmarshal(data []byte) (Layers, error) {
+	return o.UnmarshalContext(context.Background(), data)
+}
+
+// UnmarshalContext parses the supplied mvt data and returns a set of layers.
+// Decoding stops between features if ctx is done, and ctx.Err() is returned.
+func (o UnmarshalOptions) UnmarshalContext(ctx context.Context, data []byte) (Layers, error) {
+	var d decompressor
+	defer d.close()
+
+	data, err := d.decompress(data, o.MaxDecompressedSize)
+	if err != nil {
 		return nil, err
 	}
 
-	layers := make(Layers, len(tile.Layers))
-	for _, data := range tile.Layers {
-		name := LayerName(data.GetName())
-		if _, ok := layers[name]; ok {
-			return nil, fmt.Errorf("layer with name '%s' already exists", name)
+	layers, _, err := o.unmarshalTile(ctx, data, nil)
+	return layers, err
+}
+
+// unmarshalTile decodes the tile in data.
+// Each worker decodes layers into an element of scratch, which is grown as needed and returned for reuse.
+func (o UnmarshalOptions) unmarshalTile(ctx context.Context, data []byte, scratch []wire.Layer) (Layers, []wire.Layer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, scratch, err
+	}
+
+	lim := &limiter{Limits: o.Limits}
+
+	var encoded [][]byte
+	if err := wire.Layers(data, func(data []byte) error {
+		encoded = append(encoded, data)
+		return nil
+	}); err != nil {
+		return nil, scratch, err
+	} else if err := lim.check(LayersLimit, lim.MaxLayers, len(encoded)); err != nil {
+		return nil, scratch, err
+	}
+
+	w := workers(o.Concurrency, len(encoded))
+	if len(scratch) < w {
+		scratch = append(scratch, make([]wire.Layer, w-len(scratch))...)
+	}
+
+	type result struct {
+		name     LayerName
+		layer    *Layer
+		problems Problems
+	}
+
+	// Layer names are recorded as each layer is decoded, so that a duplicate is reported as soon as it is seen.
+	var mu sync.Mutex
+	names := make(map[LayerName]struct{}, len(encoded))
+
+	results := make([]result, len(encoded))
+	err := forEachLayer(ctx, len(encoded), w, func(ctx context.Context, worker, i int) error {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		layer, err := unmarshalLayer(*data, geometry.Unproject(unproject))
+		l := &scratch[worker]
+		if err := wire.DecodeLayerLimits(encoded[i], l, lim.wire()); err != nil {
+			var limErr *wire.LimitError
+			if errors.As(err, &limErr) {
+				return &LayerError{
+					Layer: LayerName(l.Name),
+					Err:   wireLimitError(limErr),
+				}
+			}
+			return err
+		}
+
+		name := LayerName(l.Name)
+		mu.Lock()
+		_, duplicate := names[name]
+		names[name] = struct{}{}
+		mu.Unlock()
+		if duplicate {
+			return &LayerError{
+				Layer:   name,
+				Section: SectionLayers,
+				Err:     fmt.Errorf("layer with name '%s' already exists", name),
+			}
+		}
+
+		u := unmarshaler{
+			ctx:       ctx,
+			unproject: geometry.Unproject(o.Unproject),
+			tile:      o.TileCoordinates,
+			invalid:   o.InvalidFeatures,
+			lim:       lim,
+		}
+
+		layer, err := u.unmarshalLayer(name, l)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		layers[name] = *layer
+
+		results[i] = result{name, layer, u.problems}
+		return nil
+	})
+	if err != nil {
+		return nil, scratch, err
+	}
+
+	var problems Problems
+	layers := make(Layers, len(results))
+	for _, r := range results {
+		layers[r.name] = *r.layer
+		problems = append(problems, r.problems...)
 	}
 
-	return layers, nil
+	if len(problems) != 0 {
+		return layers, scratch, problems
+	}
+	return layers, scratch, nil
+}
+
+// unmarshaler holds the state of a single layer decode.
+type unmarshaler struct {
+	ctx       context.Context
+	unproject geometry.Unproject
+	tile      bool
+	invalid   InvalidFeatureAction
+	lim       *limiter
+	problems  Problems
 }
 
-func unmarshalLayer(data spec.Tile_Layer, unproject geometry.Unproject) (*Layer, error) {
-	if v := data.GetVersion(); v != 2 {
-		return nil, fmt.Errorf("unsupported version '%d'", v)
+func (u *unmarshaler) unmarshalLayer(name LayerName, data *wire.Layer) (*Layer, error) {
+	if !data.HasName {
+		return nil, &LayerError{
+			Layer:   name,
+			Section: SectionLayers,
+			Err:     fmt.Errorf("missing name"),
+		}
+	} else if v := data.Version; v != 2 {
+		return nil, &LayerError{
+			Layer:   name,
+			Section: SectionLayers,
+			Err:     fmt.Errorf("unsupported version '%d'", v),
+		}
+	}
+
+	// The numbers of features, keys and values are limited while the layer is decoded.
+	if err := u.lim.alloc(int64(len(data.Features)) * featureSize); err != nil {
+		return nil, &LayerError{
+			Layer: name,
+			Err:   err,
+		}
 	}
 
 	layer := Layer{
-		Extent: data.GetExtent(),
+		Extent: data.Extent,
 	}
 
-	if err := unmarshalFeatures(data, unproject, &layer); err != nil {
+	if err := u.unmarshalFeatures(name, data, &layer); err != nil {
 		return nil, err
 	}
 	return &layer, nil
 }
 
-func unmarshalFeatures(layerData spec.Tile_Layer, unproject geometry.Unproject, layer *Layer) error {
-	layer.Features = make([]Feature, len(layerData.Features))
+func (u *unmarshaler) unmarshalFeatures(name LayerName, layerData *wire.Layer, layer *Layer) error {
+	layer.Features = make([]Feature, 0, len(layerData.Features))
 
 	ids := make(map[uint64]struct{})
 	for i, data := range layerData.Features {
+		if err := u.ctx.Err(); err != nil {
+			return err
+		}
+
 		feature := Feature{}
 
-		if id := data.Id; id != nil {
-			if _, ok := ids[*id]; ok {
-				return fmt.Errorf("layer with ID '%d' already exists", id)
+		if id := data.ID; data.HasID {
+			feature.ID = NewOptionalUint64(id)
+			if _, ok := ids[id]; ok {
+				err := newFeatureError(name, i, feature.ID, SectionFeatures,
+					fmt.Errorf("feature with ID '%d' already exists", id))
+				if !u.skip(err) {
+					return err
+				} else if u.invalid == DropInvalidFeatures {
+					continue
+				}
+				feature.ID = OptionalUint64{}
+			} else {
+				ids[id] = struct{}{}
 			}
-			feature.ID = NewOptionalUint64(*id)
-			ids[*id] = struct{}{}
 		}
 
-		if err := unmarshalTags(*data, layerData, &feature); err != nil {
-			return err
+		if err := unmarshalTags(data, layerData, u.lim, &feature); err != nil {
+			err := newFeatureError(name, i, feature.ID, SectionAttributes, err)
+			if !u.skip(err) {
+				return err
+			} else if u.invalid == DropInvalidFeatures {
+				continue
+			}
+			feature.Tags = nil
 		}
 
-		if err := unmarshalGeometry(*data, unproject, &feature); err != nil {
-			return err
+		if err := u.unmarshalGeometry(data, &feature); err != nil {
+			err := newFeatureError(name, i, feature.ID, SectionGeometry, err)
+			if !u.skip(err) {
+				return err
+			} else if u.invalid == DropInvalidFeatures {
+				continue
+			}
+			feature.Geometry = newUnknownGeometry(data.Geometry)
 		}
-		layer.Features[i] = feature
+		layer.Features = append(layer.Features, feature)
 	}
 	return nil
 }
 
-func unmarshalTags(data spec.Tile_Feature, layer spec.Tile_Layer, feature *Feature) error {
+// skip returns true if decoding should continue past the invalid feature that caused err.
+// The error is recorded as a problem.
+func (u *unmarshaler) skip(err *FeatureError) bool {
+	var limitErr *LimitError
+	if u.invalid == FailInvalidFeatures || errors.As(err, &limitErr) {
+		return false
+	}
+
+	u.problems = append(u.problems, err)
+	return true
+}
+
+func unmarshalTags(data wire.Feature, layer *wire.Layer, lim *limiter, feature *Feature) error {
 	if len(data.Tags)%2 != 0 {
 		return fmt.Errorf("expecting even number of tags")
+	} else if err := lim.alloc(int64(len(data.Tags)/2) * propertySize); err != nil {
+		return err
 	}
 
 	props := make([]geojson.Property, len(data.Tags)/2)
@@ -94,7 +313,7 @@ func unmarshalTags(data spec.Tile_Feature, layer spec.Tile_Layer, feature *Featu
 			return fmt.Errorf("tag value '%d' does not exist in layer", value)
 		}
 
-		v, err := unmarshalValue(*layer.Values[value])
+		v, err := unmarshalValue(layer.Values[value])
 		if err != nil {
 			return fmt.Errorf("failed to unmarshal value '%d': %w", value, err)
 		}
@@ -109,30 +328,66 @@ func unmarshalTags(data spec.Tile_Feature, layer spec.Tile_Layer, feature *Featu
 	return nil
 }
 
-func unmarshalValue(v spec.Tile_Value) (interface{}, error) {
+func unmarshalValue(v wire.Value) (interface{}, error) {
 	switch {
-	case v.StringValue != nil:
-		return v.GetStringValue(), nil
-	case v.FloatValue != nil:
-		return v.GetFloatValue(), nil
-	case v.DoubleValue != nil:
-		return v.GetDoubleValue(), nil
-	case v.IntValue != nil:
-		return v.GetIntValue(), nil
-	case v.UintValue != nil:
-		return v.GetUintValue(), nil
-	case v.SintValue != nil:
-		return v.GetSintValue(), nil
-	case v.BoolValue != nil:
-		return v.GetBoolValue(), nil
+	case v.Has(wire.StringField):
+		return v.String, nil
+	case v.Has(wire.FloatField):
+		return v.Float, nil
+	case v.Has(wire.DoubleField):
+		return v.Double, nil
+	case v.Has(wire.IntField):
+		return v.Int, nil
+	case v.Has(wire.UintField):
+		return v.Uint, nil
+	case v.Has(wire.SintField):
+		return v.Sint, nil
+	case v.Has(wire.BoolField):
+		return v.Bool, nil
 	default:
 		return nil, fmt.Errorf("missing value")
 	}
 }
 
-func unmarshalGeometry(data spec.Tile_Feature, unproject geometry.Unproject, feature *Feature) error {
-	if data.Type == nil {
+func (u *unmarshaler) unmarshalGeometry(data wire.Feature, feature *Feature) error {
+	if !data.HasType {
 		return fmt.Errorf("missing geometry type")
 	}
-	return geometry.Unmarshal(data.Geometry, *data.Type, unproject, &feature.Geometry)
+
+	if data.Type != spec.Tile_UNKNOWN {
+		n, err := geometry.CountVertices(data.Geometry)
+		if err != nil {
+			return err
+		} else if err := u.lim.check(VerticesLimit, u.lim.MaxVertices, n); err != nil {
+			return err
+		} else if err := u.lim.alloc(int64(n) * positionSize); err != nil {
+			return err
+		}
+	}
+
+	if u.tile && data.Type != spec.Tile_UNKNOWN {
+		g, err := unmarshalTileGeometry(data.Geometry, data.Type)
+		if err != nil {
+			return err
+		}
+		feature.Geometry = g
+		return nil
+	}
+
+	if err := geometry.Unmarshal(data.Geometry, data.Type, u.unproject, &feature.Geometry); err != nil {
+		return err
+	}
+
+	if raw, ok := feature.Geometry.(*geometry.RawShape); ok {
+		feature.Geometry = newUnknownGeometry(*raw)
+	}
+	return nil
+}
+
+// newUnknownGeometry returns an UnknownGeometry holding a copy of the decoded command sequence,
+// which is only valid until the next layer is decoded.
+func newUnknownGeometry(data []uint32) *UnknownGeometry {
+	return &UnknownGeometry{
+		RawShape: append(geometry.RawShape(nil), data...),
+	}
 }
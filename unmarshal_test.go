@@ -290,3 +290,86 @@ func newLayer(name string, version, extent uint32) *spec.Tile_Layer {
 		Extent:  &extent,
 	}
 }
+
+func TestUnmarshalMissingUnproject(t *testing.T) {
+	data, err := proto.Marshal(&spec.Tile{
+		Layers: []*spec.Tile_Layer{
+			{
+				Version: proto.Uint32(2),
+				Name:    proto.String("my_layer"),
+				Features: []*spec.Tile_Feature{
+					{
+						Type:     spec.Tile_POINT.Enum(),
+						Geometry: []uint32{9, 50, 34},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = mvt21.Unmarshal(data, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing unproject function")
+}
+
+func TestUnmarshalTileCoordinates(t *testing.T) {
+	layers := mvt21.Layers{
+		"my_layer": mvt21.MakeLayer(4096,
+			mvt21.Feature{
+				ID:   mvt21.NewOptionalUint64(1),
+				Tags: geojson.PropertyList{{Name: "name", Value: "stop"}},
+				Geometry: &mvt21.TileGeometry{
+					GeomType: mvt21.TilePointType,
+					Parts:    [][]mvt21.TilePoint{{{X: 25, Y: 17}, {X: -3, Y: 4100}}},
+				},
+			},
+			mvt21.Feature{
+				Tags: geojson.PropertyList{},
+				Geometry: &mvt21.TileGeometry{
+					GeomType: mvt21.TileLineStringType,
+					Parts:    [][]mvt21.TilePoint{{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}, {{X: 20, Y: 20}, {X: 30, Y: 25}}},
+				},
+			},
+			mvt21.Feature{
+				Tags: geojson.PropertyList{},
+				Geometry: &mvt21.TileGeometry{
+					GeomType: mvt21.TilePolygonType,
+					Parts: [][]mvt21.TilePoint{
+						{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}, {X: 0, Y: 100}},
+						{{X: 10, Y: 10}, {X: 10, Y: 20}, {X: 20, Y: 20}, {X: 20, Y: 10}},
+					},
+				},
+			},
+		),
+	}
+
+	data, err := mvt21.Marshal(layers, nil)
+	require.NoError(t, err)
+
+	decoded, err := mvt21.UnmarshalOptions{TileCoordinates: true}.Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, layers, decoded)
+
+	t.Run("invalid sequence", func(t *testing.T) {
+		data, err := proto.Marshal(&spec.Tile{
+			Layers: []*spec.Tile_Layer{
+				{
+					Version: proto.Uint32(2),
+					Name:    proto.String("my_layer"),
+					Features: []*spec.Tile_Feature{
+						{
+							Type:     spec.Tile_POLYGON.Enum(),
+							Geometry: []uint32{9, 0, 0, 18, 20, 0, 0, 20},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = mvt21.UnmarshalOptions{TileCoordinates: true}.Unmarshal(data)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid command sequence")
+	})
+}
@@ -0,0 +1,377 @@
+package mvt
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/everystreet/go-mvt/internal/geometry"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/everystreet/go-mvt/internal/wire"
+	"github.com/golang/geo/r2"
+)
+
+// Severity of a validation finding.
+type Severity uint8
+
+const (
+	// SeverityWarning is a finding that is discouraged by the specification, or likely to be a mistake.
+	SeverityWarning Severity = iota
+	// SeverityError is a violation of the specification.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Finding is a single issue found while validating a tile.
+type Finding struct {
+	Severity Severity
+	Layer    LayerName
+	// Feature is the index of the feature in the layer, or -1 if the finding is not about a feature.
+	Feature int
+	// Offset into the encoded geometry command sequence, or -1 if the finding is not about the geometry.
+	Offset int
+	// Section of the vector tile specification, if known.
+	Section string
+	Message string
+}
+
+func (f Finding) String() string {
+	loc := fmt.Sprintf("layer '%s'", f.Layer)
+	if f.Feature >= 0 {
+		loc += fmt.Sprintf(": feature %d", f.Feature)
+	}
+	if f.Offset >= 0 {
+		loc += fmt.Sprintf(": geometry offset %d", f.Offset)
+	}
+	return fmt.Sprintf("%v: %s: %s%s", f.Severity, loc, f.Message, sectionSuffix(f.Section))
+}
+
+// Report lists every finding in a tile.
+type Report []Finding
+
+// Valid returns true if the report has no findings with SeverityError.
+func (r Report) Valid() bool {
+	for _, f := range r {
+		if f.Severity == SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateTile checks the encoded tile against the vector tile specification,
+// and reports every violation rather than stopping at the first.
+// Gzip and zstd compressed data is decompressed automatically.
+// An error is returned only if data can't be parsed as a tile at all.
+func ValidateTile(data []byte) (Report, error) {
+	var d decompressor
+	defer d.close()
+
+	data, err := d.decompress(data, DefaultMaxDecompressedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var v validator
+	if err := v.validateTile(data); err != nil {
+		return nil, err
+	}
+	return v.report, nil
+}
+
+// maxSelfIntersectionVertices bounds the size of rings checked for self-intersection,
+// as the check takes quadratic time.
+const maxSelfIntersectionVertices = 1 << 14
+
+// validator accumulates findings while validating a tile.
+type validator struct {
+	report Report
+	layer  LayerName
+}
+
+func (v *validator) add(severity Severity, feature, offset int, section, format string, args ...interface{}) {
+	v.report = append(v.report, Finding{
+		Severity: severity,
+		Layer:    v.layer,
+		Feature:  feature,
+		Offset:   offset,
+		Section:  section,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validateTile(data []byte) error {
+	var layer wire.Layer
+	names := make(map[string]struct{})
+	return wire.Layers(data, func(data []byte) error {
+		if err := wire.DecodeLayer(data, &layer); err != nil {
+			return err
+		}
+		v.layer = LayerName(layer.Name)
+
+		if !layer.HasName || layer.Name == "" {
+			v.add(SeverityError, -1, -1, SectionLayers, "missing name")
+		} else if _, ok := names[layer.Name]; ok {
+			v.add(SeverityError, -1, -1, SectionLayers, "duplicate layer name")
+		}
+		names[layer.Name] = struct{}{}
+
+		v.validateLayer(&layer)
+		return nil
+	})
+}
+
+func (v *validator) validateLayer(layer *wire.Layer) {
+	if !layer.HasVersion {
+		v.add(SeverityError, -1, -1, SectionLayers, "missing version")
+	} else if layer.Version != 2 {
+		v.add(SeverityError, -1, -1, SectionLayers, "unsupported version '%d'", layer.Version)
+	}
+
+	if !layer.HasExtent {
+		v.add(SeverityError, -1, -1, SectionLayers, "missing extent")
+	} else if layer.Extent == 0 {
+		v.add(SeverityError, -1, -1, SectionLayers, "extent must be greater than 0")
+	}
+
+	v.validateKeyValues(layer)
+
+	ids := make(map[uint64]int)
+	for i, feature := range layer.Features {
+		if feature.HasID {
+			if first, ok := ids[feature.ID]; ok {
+				v.add(SeverityWarning, i, -1, SectionFeatures, "ID '%d' is not unique, first used by feature %d",
+					feature.ID, first)
+			} else {
+				ids[feature.ID] = i
+			}
+		}
+
+		v.validateTags(i, feature, layer)
+		v.validateGeometry(i, feature, layer.Extent)
+	}
+}
+
+func (v *validator) validateKeyValues(layer *wire.Layer) {
+	usedKeys := make([]bool, len(layer.Keys))
+	usedValues := make([]bool, len(layer.Values))
+	for _, feature := range layer.Features {
+		for i := 0; i+1 < len(feature.Tags); i += 2 {
+			if k := feature.Tags[i]; int(k) < len(usedKeys) {
+				usedKeys[k] = true
+			}
+			if val := feature.Tags[i+1]; int(val) < len(usedValues) {
+				usedValues[val] = true
+			}
+		}
+	}
+
+	keys := make(map[string]int, len(layer.Keys))
+	for i, key := range layer.Keys {
+		if first, ok := keys[key]; ok {
+			v.add(SeverityWarning, -1, -1, SectionAttributes, "key %d duplicates key %d '%s'", i, first, key)
+		} else {
+			keys[key] = i
+		}
+
+		if !usedKeys[i] {
+			v.add(SeverityWarning, -1, -1, SectionAttributes, "key %d '%s' is not used by any feature", i, key)
+		}
+	}
+
+	values := make(map[interface{}]int, len(layer.Values))
+	for i, value := range layer.Values {
+		if n := bits.OnesCount8(value.Fields); n != 1 {
+			v.add(SeverityError, -1, -1, SectionAttributes, "value %d must have exactly one field set, has %d", i, n)
+		} else if val, err := unmarshalValue(value); err == nil {
+			if first, ok := values[val]; ok {
+				v.add(SeverityWarning, -1, -1, SectionAttributes, "value %d duplicates value %d", i, first)
+			} else {
+				values[val] = i
+			}
+		}
+
+		if !usedValues[i] {
+			v.add(SeverityWarning, -1, -1, SectionAttributes, "value %d is not used by any feature", i)
+		}
+	}
+}
+
+func (v *validator) validateTags(index int, feature wire.Feature, layer *wire.Layer) {
+	if len(feature.Tags)%2 != 0 {
+		v.add(SeverityError, index, -1, SectionAttributes, "expecting even number of tags, have %d", len(feature.Tags))
+	}
+
+	for i := 0; i+1 < len(feature.Tags); i += 2 {
+		if k := feature.Tags[i]; int(k) >= len(layer.Keys) {
+			v.add(SeverityError, index, -1, SectionAttributes, "tag key '%d' does not exist in layer", k)
+		}
+		if val := feature.Tags[i+1]; int(val) >= len(layer.Values) {
+			v.add(SeverityError, index, -1, SectionAttributes, "tag value '%d' does not exist in layer", val)
+		}
+	}
+}
+
+func (v *validator) validateGeometry(index int, feature wire.Feature, extent uint32) {
+	typ := feature.Type
+	switch typ {
+	case spec.Tile_UNKNOWN:
+		v.add(SeverityWarning, index, -1, SectionGeometry, "unknown geometry type")
+		return
+	case spec.Tile_POINT, spec.Tile_LINESTRING, spec.Tile_POLYGON:
+	default:
+		v.add(SeverityError, index, -1, SectionGeometry, "invalid geometry type '%d'", typ)
+		return
+	}
+
+	cmds, err := geometry.Commands(feature.Geometry)
+	if err != nil {
+		var geoErr *geometry.Error
+		if errors.As(err, &geoErr) {
+			v.add(SeverityError, index, geoErr.Offset, geoErr.Section, "%v", geoErr.Err)
+		}
+		return
+	} else if len(cmds) == 0 {
+		v.add(SeverityError, index, -1, SectionGeometry, "missing geometry")
+		return
+	}
+
+	v.validateCoordinates(index, cmds, extent)
+
+	switch typ {
+	case spec.Tile_POINT:
+		v.validatePoints(index, cmds)
+	case spec.Tile_LINESTRING:
+		v.validateLineStrings(index, cmds)
+	case spec.Tile_POLYGON:
+		v.validatePolygons(index, cmds)
+	}
+}
+
+// validateCoordinates reports coordinates that are further outside the extent than the extent itself.
+func (v *validator) validateCoordinates(index int, cmds []geometry.Command, extent uint32) {
+	if extent == 0 {
+		extent = 4096
+	}
+
+	min, max := -float64(extent), 2*float64(extent)
+	for _, cmd := range cmds {
+		for _, p := range cmd.Points {
+			if p.X < min || p.X > max || p.Y < min || p.Y > max {
+				v.add(SeverityWarning, index, cmd.Offset, geometry.SectionParameters,
+					"coordinate (%g, %g) is far outside the extent", p.X, p.Y)
+				return
+			}
+		}
+	}
+}
+
+func (v *validator) validatePoints(index int, cmds []geometry.Command) {
+	if cmd := cmds[0]; cmd.ID != geometry.MoveTo || len(cmds) != 1 {
+		v.add(SeverityError, index, cmd.Offset, geometry.SectionPoint, "must consist of a single MoveTo command")
+	} else if len(cmd.Points) == 0 {
+		v.add(SeverityError, index, cmd.Offset, geometry.SectionPoint, "MoveTo command count must be greater than 0")
+	}
+}
+
+func (v *validator) validateLineStrings(index int, cmds []geometry.Command) {
+	for i := 0; i < len(cmds); i += 2 {
+		if !v.validateMoveTo(index, cmds[i], geometry.SectionLineString) {
+			return
+		} else if i+1 == len(cmds) {
+			v.add(SeverityError, index, cmds[i].Offset, geometry.SectionLineString, "MoveTo must be followed by LineTo")
+			return
+		}
+
+		lineTo := cmds[i+1]
+		if !v.validateLineTo(index, cmds[i], lineTo, 1, geometry.SectionLineString) {
+			return
+		}
+	}
+}
+
+func (v *validator) validatePolygons(index int, cmds []geometry.Command) {
+	var exterior bool
+	for i := 0; i < len(cmds); i += 3 {
+		if !v.validateMoveTo(index, cmds[i], geometry.SectionPolygon) {
+			return
+		} else if i+2 >= len(cmds) {
+			v.add(SeverityError, index, cmds[i].Offset, geometry.SectionPolygon,
+				"MoveTo must be followed by LineTo and ClosePath")
+			return
+		}
+
+		lineTo := cmds[i+1]
+		if !v.validateLineTo(index, cmds[i], lineTo, 2, geometry.SectionPolygon) {
+			return
+		} else if closePath := cmds[i+2]; closePath.ID != geometry.ClosePath {
+			v.add(SeverityError, index, closePath.Offset, geometry.SectionPolygon,
+				"expecting 'ClosePath' command, received '%v'", closePath.ID)
+			return
+		}
+
+		ring := append([]r2.Point{cmds[i].Points[0]}, lineTo.Points...)
+		area := geometry.Area(ring)
+		switch {
+		case area == 0:
+			v.add(SeverityError, index, cmds[i].Offset, geometry.SectionPolygon, "ring has zero area")
+		case area < 0 && !exterior:
+			v.add(SeverityError, index, cmds[i].Offset, geometry.SectionPolygon,
+				"first ring must be an exterior ring with clockwise winding")
+		case area > 0:
+			exterior = true
+		}
+
+		if len(ring) > maxSelfIntersectionVertices {
+			v.add(SeverityWarning, index, cmds[i].Offset, geometry.SectionPolygon,
+				"ring has too many vertices to check for self-intersection (%d > %d)",
+				len(ring), maxSelfIntersectionVertices)
+		} else if geometry.SelfIntersects(ring) {
+			v.add(SeverityError, index, cmds[i].Offset, geometry.SectionPolygon, "ring is self-intersecting")
+		}
+	}
+}
+
+func (v *validator) validateMoveTo(index int, cmd geometry.Command, section string) bool {
+	if cmd.ID != geometry.MoveTo {
+		v.add(SeverityError, index, cmd.Offset, section, "expecting 'MoveTo' command, received '%v'", cmd.ID)
+		return false
+	} else if n := len(cmd.Points); n != 1 {
+		v.add(SeverityError, index, cmd.Offset, section, "expecting MoveTo command count of 1, received '%d'", n)
+		return false
+	}
+	return true
+}
+
+func (v *validator) validateLineTo(index int, moveTo, cmd geometry.Command, min int, section string) bool {
+	if cmd.ID != geometry.LineTo {
+		v.add(SeverityError, index, cmd.Offset, section, "expecting 'LineTo' command, received '%v'", cmd.ID)
+		return false
+	} else if n := len(cmd.Points); n == 0 {
+		v.add(SeverityError, index, cmd.Offset, section, "zero-length LineTo command")
+		return false
+	} else if n < min {
+		v.add(SeverityError, index, cmd.Offset, section, "expecting LineTo command count >= %d, received '%d'", min, n)
+		return false
+	}
+
+	prev := moveTo.Points[0]
+	for _, p := range cmd.Points {
+		if p == prev {
+			v.add(SeverityError, index, cmd.Offset, geometry.SectionCommands, "LineTo has zero-length segment")
+			break
+		}
+		prev = p
+	}
+	return true
+}
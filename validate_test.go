@@ -0,0 +1,194 @@
+package mvt_test
+
+import (
+	"testing"
+
+	mvt21 "github.com/everystreet/go-mvt"
+	spec "github.com/everystreet/go-mvt/internal/spec"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTile(t *testing.T) {
+	type finding struct {
+		Severity mvt21.Severity
+		Feature  int
+		Message  string
+	}
+
+	validLayer := func() *spec.Tile_Layer {
+		return &spec.Tile_Layer{
+			Version: proto.Uint32(2),
+			Name:    proto.String("my_layer"),
+			Extent:  proto.Uint32(4096),
+			Keys:    []string{"key"},
+			Values:  []*spec.Tile_Value{newStringValue("value")},
+			Features: []*spec.Tile_Feature{
+				{
+					Id:       proto.Uint64(1),
+					Type:     spec.Tile_POLYGON.Enum(),
+					Tags:     []uint32{0, 0},
+					Geometry: []uint32{9, 0, 0, 26, 20, 0, 0, 20, 19, 0, 15},
+				},
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		Name     string
+		Layers   func() []*spec.Tile_Layer
+		Findings []finding
+	}{
+		{
+			Name: "valid",
+			Layers: func() []*spec.Tile_Layer {
+				return []*spec.Tile_Layer{validLayer()}
+			},
+		},
+		{
+			Name: "duplicate layer name",
+			Layers: func() []*spec.Tile_Layer {
+				return []*spec.Tile_Layer{validLayer(), validLayer()}
+			},
+			Findings: []finding{{mvt21.SeverityError, -1, "duplicate layer name"}},
+		},
+		{
+			Name: "missing extent",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Extent = nil
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{{mvt21.SeverityError, -1, "missing extent"}},
+		},
+		{
+			Name: "missing name and version",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Name = nil
+				layer.Version = nil
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{
+				{mvt21.SeverityError, -1, "missing name"},
+				{mvt21.SeverityError, -1, "missing version"},
+			},
+		},
+		{
+			Name: "unused and duplicate dictionary entries",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Keys = append(layer.Keys, "key")
+				layer.Values = append(layer.Values, newStringValue("value"))
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{
+				{mvt21.SeverityWarning, -1, "key 1 duplicates key 0 'key'"},
+				{mvt21.SeverityWarning, -1, "key 1 'key' is not used by any feature"},
+				{mvt21.SeverityWarning, -1, "value 1 duplicates value 0"},
+				{mvt21.SeverityWarning, -1, "value 1 is not used by any feature"},
+			},
+		},
+		{
+			Name: "value fields",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Values[0].IntValue = proto.Int64(1)
+				layer.Values = append(layer.Values, &spec.Tile_Value{})
+				layer.Features[0].Tags = append(layer.Features[0].Tags, 0, 1)
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{
+				{mvt21.SeverityError, -1, "value 0 must have exactly one field set, has 2"},
+				{mvt21.SeverityError, -1, "value 1 must have exactly one field set, has 0"},
+			},
+		},
+		{
+			Name: "out of range tags",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Features[0].Tags = []uint32{0, 0, 1, 2}
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{
+				{mvt21.SeverityError, 0, "tag key '1' does not exist in layer"},
+				{mvt21.SeverityError, 0, "tag value '2' does not exist in layer"},
+			},
+		},
+		{
+			Name: "duplicate feature ID",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Features = append(layer.Features, layer.Features[0])
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{{mvt21.SeverityWarning, 1, "ID '1' is not unique, first used by feature 0"}},
+		},
+		{
+			Name: "zero-length LineTo",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Features[0].Type = spec.Tile_LINESTRING.Enum()
+				layer.Features[0].Geometry = []uint32{9, 0, 0, 2}
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{{mvt21.SeverityError, 0, "zero-length LineTo command"}},
+		},
+		{
+			Name: "counter-clockwise exterior ring",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Features[0].Geometry = []uint32{9, 0, 0, 26, 0, 20, 20, 0, 0, 19, 15}
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{{mvt21.SeverityError, 0, "first ring must be an exterior ring with clockwise winding"}},
+		},
+		{
+			Name: "self-intersecting ring",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Features[0].Geometry = []uint32{9, 0, 0, 26, 20, 20, 19, 0, 20, 19, 15}
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{
+				{mvt21.SeverityError, 0, "ring has zero area"},
+				{mvt21.SeverityError, 0, "ring is self-intersecting"},
+			},
+		},
+		{
+			Name: "far outside extent",
+			Layers: func() []*spec.Tile_Layer {
+				layer := validLayer()
+				layer.Features[0].Type = spec.Tile_POINT.Enum()
+				layer.Features[0].Geometry = []uint32{9, 50000, 0}
+				return []*spec.Tile_Layer{layer}
+			},
+			Findings: []finding{{mvt21.SeverityWarning, 0, "coordinate (25000, 0) is far outside the extent"}},
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			// The generated code encodes a message with missing required fields, but reports them.
+			data, err := proto.Marshal(&spec.Tile{
+				Layers: tt.Layers(),
+			})
+			if _, ok := err.(*proto.RequiredNotSetError); !ok {
+				require.NoError(t, err)
+			}
+
+			report, err := mvt21.ValidateTile(data)
+			require.NoError(t, err)
+
+			var findings []finding
+			for _, f := range report {
+				findings = append(findings, finding{f.Severity, f.Feature, f.Message})
+			}
+			require.Equal(t, tt.Findings, findings)
+
+			valid := true
+			for _, f := range tt.Findings {
+				valid = valid && f.Severity != mvt21.SeverityError
+			}
+			require.Equal(t, valid, report.Valid())
+		})
+	}
+}